@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+)
+
+// KeyReloadChannel is the pub/sub channel instances announce signing key changes on
+const KeyReloadChannel = "keys:reload"
+
+// KeyReloadBroadcaster announces signing key changes to the other instances through Redis pub/sub
+type KeyReloadBroadcaster struct{}
+
+// NewKeyReloadBroadcaster creates a new KeyReloadBroadcaster instance
+func NewKeyReloadBroadcaster() *KeyReloadBroadcaster {
+	return &KeyReloadBroadcaster{}
+}
+
+// Publish announces a key change made by origin
+func (b *KeyReloadBroadcaster) Publish(ctx context.Context, origin string) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+
+	return RedisClient.Publish(ctx, KeyReloadChannel, origin).Err()
+}
+
+// Subscribe delivers the origins of announced key changes until ctx is done.
+// Announcements made while the connection is down are lost; the key files are polled as well.
+func (b *KeyReloadBroadcaster) Subscribe(ctx context.Context) (<-chan string, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+
+	pubsub := RedisClient.Subscribe(ctx, KeyReloadChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", KeyReloadChannel, err)
+	}
+
+	origins := make(chan string)
+	go func() {
+		defer close(origins)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case origins <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return origins, nil
+}
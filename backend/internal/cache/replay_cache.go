@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ReplayCachePrefix is the prefix for single-use identifier cache keys (e.g. JWT "jti" values)
+const ReplayCachePrefix = "replay:"
+
+// ReplayCache detects reuse of identifiers that must only ever be presented once
+type ReplayCache struct{}
+
+// NewReplayCache creates a new ReplayCache instance
+func NewReplayCache() *ReplayCache {
+	return &ReplayCache{}
+}
+
+// Claim records id as used within namespace for ttl and reports whether this was its first use.
+// Callers should reject the request when Claim returns false or an error, so that an unavailable
+// Redis never silently disables replay protection.
+func (c *ReplayCache) Claim(ctx context.Context, namespace, id string, ttl time.Duration) (bool, error) {
+	if RedisClient == nil {
+		return false, fmt.Errorf("redis client not initialized")
+	}
+
+	cacheKey := ReplayCachePrefix + namespace + ":" + id
+	return RedisClient.SetNX(ctx, cacheKey, "1", ttl).Result()
+}
@@ -46,3 +46,28 @@ func (c *TokenRevocationCache) RevokeSession(ctx context.Context, sessionID stri
 	cacheKey := SessionRevocationPrefix + sessionID
 	return RedisClient.Set(ctx, cacheKey, "1", ttl).Err()
 }
+
+// IsTokenRevoked checks if a single access token, identified by its jti, is revoked
+func (c *TokenRevocationCache) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	if RedisClient == nil {
+		return false, fmt.Errorf("redis client not initialized")
+	}
+
+	cacheKey := TokenRevocationPrefix + jti
+	exists, err := RedisClient.Exists(ctx, cacheKey).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return exists > 0, nil
+}
+
+// RevokeToken marks a single access token, identified by its jti, as revoked in Redis cache
+func (c *TokenRevocationCache) RevokeToken(ctx context.Context, jti string, ttl time.Duration) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+
+	cacheKey := TokenRevocationPrefix + jti
+	return RedisClient.Set(ctx, cacheKey, "1", ttl).Err()
+}
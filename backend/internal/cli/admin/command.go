@@ -59,6 +59,7 @@ func (c *Command) runInitRoot(args []string) error {
 	username := fs.String("username", "admin", "Root user username")
 	domain := fs.String("domain", "", "Service domain (e.g., localhost:3000)")
 	redirectURIs := fs.String("redirect-uris", "", "Comma-separated redirect URIs")
+	postLogoutRedirectURIs := fs.String("post-logout-redirect-uris", "", "Comma-separated post-logout redirect URIs")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -96,6 +97,14 @@ func (c *Command) runInitRoot(args []string) error {
 		}
 	}
 
+	var logoutURIs pq.StringArray
+	if *postLogoutRedirectURIs != "" {
+		logoutURIs = strings.Split(*postLogoutRedirectURIs, ",")
+		for i := range logoutURIs {
+			logoutURIs[i] = strings.TrimSpace(logoutURIs[i])
+		}
+	}
+
 	systemService, err := serviceRepo.FindByID(svc.DefaultAuthlyServiceID)
 	if err != nil {
 		slog.Info("Creating system service...")
@@ -104,15 +113,18 @@ func (c *Command) runInitRoot(args []string) error {
 			Description:  "System management dashboard",
 			ClientID:     svc.DefaultAuthlyClientID,
 			ClientSecret: "",
+			// The dashboard is a browser app and authenticates as a public client
+			TokenEndpointAuthMethod: svc.AuthMethodNone,
 			AllowedScopes: []string{
 				"openid",
 				"profile",
 				"email",
 			},
-			Active:       true,
-			IsSystem:     true,
-			Domain:       *domain,
-			RedirectURIs: uris,
+			Active:                 true,
+			IsSystem:               true,
+			Domain:                 *domain,
+			RedirectURIs:           uris,
+			PostLogoutRedirectURIs: logoutURIs,
 		}
 		systemService.ID = systemServiceID
 		if err := serviceRepo.Create(systemService); err != nil {
@@ -130,12 +142,17 @@ func (c *Command) runInitRoot(args []string) error {
 			systemService.RedirectURIs = uris
 			updates = true
 		}
+		if len(logoutURIs) > 0 {
+			systemService.PostLogoutRedirectURIs = logoutURIs
+			updates = true
+		}
 
 		if updates {
 			if err := database.DB.Model(systemService).Updates(map[string]any{
-				"domain":         systemService.Domain,
-				"redirect_uris":  systemService.RedirectURIs,
-				"allowed_scopes": systemService.AllowedScopes,
+				"domain":                    systemService.Domain,
+				"redirect_uris":             systemService.RedirectURIs,
+				"post_logout_redirect_uris": systemService.PostLogoutRedirectURIs,
+				"allowed_scopes":            systemService.AllowedScopes,
 			}).Error; err != nil {
 				return fmt.Errorf("failed to update system service: %w", err)
 			}
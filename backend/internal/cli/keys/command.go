@@ -1,18 +1,21 @@
 package keys
 
 import (
-	"crypto/rand"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/Anvoria/authly/internal/config"
 	"github.com/Anvoria/authly/internal/domain/auth"
+	"github.com/Anvoria/authly/internal/transit"
 	"github.com/lestrrat-go/jwx/v3/jwk"
 )
 
@@ -24,7 +27,7 @@ func (c *Command) Name() string {
 }
 
 func (c *Command) Description() string {
-	return "Manage cryptographic keys (generate, list, set-active)"
+	return "Manage cryptographic keys (generate, import, export, list, set-active, rotate, retire, transit-serve)"
 }
 
 func (c *Command) Run(args []string) error {
@@ -37,10 +40,20 @@ func (c *Command) Run(args []string) error {
 	switch subcmd {
 	case "generate":
 		return c.runGenerate(args[1:])
+	case "import":
+		return c.runImport(args[1:])
+	case "export":
+		return c.runExport(args[1:])
 	case "list":
 		return c.runList(args[1:])
 	case "set-active":
 		return c.runSetActive(args[1:])
+	case "rotate":
+		return c.runRotate(args[1:])
+	case "retire":
+		return c.runRetire(args[1:])
+	case "transit-serve":
+		return c.runTransitServe(args[1:])
 	default:
 		c.printUsage()
 		return fmt.Errorf("unknown subcommand: %s", subcmd)
@@ -50,18 +63,38 @@ func (c *Command) Run(args []string) error {
 func (c *Command) printUsage() {
 	fmt.Fprintf(os.Stderr, "Usage: authly-cli keys <subcommand> [args]\n\n")
 	fmt.Fprintf(os.Stderr, "Subcommands:\n")
-	fmt.Fprintf(os.Stderr, "  generate              Generate a new RSA key pair\n")
+	fmt.Fprintf(os.Stderr, "  generate              Generate a new signing key pair\n")
 	fmt.Fprintf(os.Stderr, "    -kid <id>           Key ID (required)\n")
-	fmt.Fprintf(os.Stderr, "    -bits <size>        Key size: 2048, 3072, or 4096 (default: 2048)\n")
+	fmt.Fprintf(os.Stderr, "    -alg <alg>          Algorithm: RS256, PS256, ES256, ES384, or EdDSA (default: RS256)\n")
+	fmt.Fprintf(os.Stderr, "    -bits <size>        RSA key size: 2048, 3072, or 4096 (default: 2048)\n")
+	fmt.Fprintf(os.Stderr, "    -path <dir>         Custom keys directory (overrides config)\n")
+	fmt.Fprintf(os.Stderr, "  import                Import a PEM private key into the database (key_storage: database)\n")
+	fmt.Fprintf(os.Stderr, "    -kid <id>           Key ID (required)\n")
+	fmt.Fprintf(os.Stderr, "    -private <file>     Private key file (required)\n")
+	fmt.Fprintf(os.Stderr, "  export                Write the public keys as a JWKS\n")
+	fmt.Fprintf(os.Stderr, "    -out <file>         Output file (default: stdout)\n")
 	fmt.Fprintf(os.Stderr, "    -path <dir>         Custom keys directory (overrides config)\n")
 	fmt.Fprintf(os.Stderr, "  list                  List all available keys\n")
 	fmt.Fprintf(os.Stderr, "  set-active <kid>      Set active key ID\n")
+	fmt.Fprintf(os.Stderr, "  rotate                Activate the pre-published next key and publish a new one\n")
+	fmt.Fprintf(os.Stderr, "    -force              Activate even if the next key was published too recently\n")
+	fmt.Fprintf(os.Stderr, "    -path <dir>         Custom keys directory (overrides config)\n")
+	fmt.Fprintf(os.Stderr, "  retire <kid>          Stop a key from signing; it stays published for the retirement period\n")
+	fmt.Fprintf(os.Stderr, "    -remove             Delete the key files at once (compromised keys)\n")
+	fmt.Fprintf(os.Stderr, "    -path <dir>         Custom keys directory (overrides config)\n")
+	fmt.Fprintf(os.Stderr, "  transit-serve         Run a local stand-in transit signing service for the keys directory\n")
+	fmt.Fprintf(os.Stderr, "    -listen <addr>      Address to listen on (default: 127.0.0.1:8200)\n")
+	fmt.Fprintf(os.Stderr, "    -path <dir>         Custom keys directory (overrides config)\n")
+	fmt.Fprintf(os.Stderr, "\nWith auth.key_storage set to transit, the transit service holds the keys and only export is available.\n")
+	fmt.Fprintf(os.Stderr, "With auth.key_storage set to database, keys are managed in the database and -path is ignored.\n")
+	fmt.Fprintf(os.Stderr, "Commands that read or write private keys need KEY_ENCRYPTION_KEY or auth.key_encryption_key.\n")
 }
 
 func (c *Command) runGenerate(args []string) error {
 	fs := flag.NewFlagSet("generate", flag.ExitOnError)
 	kid := fs.String("kid", "", "Key ID (required)")
-	bits := fs.Int("bits", 2048, "Key size in bits (2048, 3072, or 4096)")
+	alg := fs.String("alg", "RS256", "Signing algorithm (RS256, PS256, ES256, ES384, or EdDSA)")
+	bits := fs.Int("bits", 2048, "RSA key size in bits (2048, 3072, or 4096)")
 	customPath := fs.String("path", "", "Custom keys directory path (overrides config)")
 
 	if err := fs.Parse(args); err != nil {
@@ -71,8 +104,14 @@ func (c *Command) runGenerate(args []string) error {
 	if *kid == "" {
 		return fmt.Errorf("key ID is required")
 	}
-	if *bits != 2048 && *bits != 3072 && *bits != 4096 {
-		return fmt.Errorf("key size must be 2048, 3072, or 4096")
+	switch *alg {
+	case "RS256", "PS256":
+		if *bits != 2048 && *bits != 3072 && *bits != 4096 {
+			return fmt.Errorf("key size must be 2048, 3072, or 4096")
+		}
+	case "ES256", "ES384", "EdDSA":
+	default:
+		return fmt.Errorf("algorithm must be RS256, PS256, ES256, ES384, or EdDSA")
 	}
 
 	// Load config to get default path
@@ -82,12 +121,121 @@ func (c *Command) runGenerate(args []string) error {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
+	if err := rejectTransitKeys(cfg); err != nil {
+		return err
+	}
+	if cfg.Auth.UsesDatabaseKeys() {
+		keyService, err := openKeyService(envConfig, cfg, true)
+		if err != nil {
+			return err
+		}
+		return generateDatabaseKey(keyService, *kid, *alg, *bits)
+	}
+
 	keysPath := cfg.Auth.KeysPath
 	if *customPath != "" {
 		keysPath = *customPath
 	}
 
-	return generateKey(keysPath, *kid, *bits)
+	return generateKey(keysPath, *kid, *alg, *bits)
+}
+
+func (c *Command) runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	kid := fs.String("kid", "", "Key ID (required)")
+	privatePath := fs.String("private", "", "Private key file (required)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *kid == "" {
+		return fmt.Errorf("key ID is required")
+	}
+	if *privatePath == "" {
+		return fmt.Errorf("private key file is required")
+	}
+
+	envConfig := config.LoadEnv()
+	cfg, err := config.Load(envConfig.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := rejectTransitKeys(cfg); err != nil {
+		return err
+	}
+	if !cfg.Auth.UsesDatabaseKeys() {
+		return fmt.Errorf("keys import requires auth.key_storage: database; copy the PEM files into %s instead", cfg.Auth.KeysPath)
+	}
+
+	privateKeyPEM, err := os.ReadFile(*privatePath)
+	if err != nil {
+		return fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	keyService, err := openKeyService(envConfig, cfg, true)
+	if err != nil {
+		return err
+	}
+	return importDatabaseKey(keyService, *kid, privateKeyPEM)
+}
+
+func (c *Command) runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	out := fs.String("out", "", "Output file (default: stdout)")
+	customPath := fs.String("path", "", "Custom keys directory path (overrides config)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	envConfig := config.LoadEnv()
+	cfg, err := config.Load(envConfig.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	var keySet jwk.Set
+	switch {
+	case cfg.Auth.UsesDatabaseKeys():
+		// Public keys are stored unencrypted, so exporting needs no key-encryption key
+		keyService, err := openKeyService(envConfig, cfg, false)
+		if err != nil {
+			return err
+		}
+		if keySet, err = keyService.ExportJWKS(); err != nil {
+			return fmt.Errorf("failed to export keys: %w", err)
+		}
+	case cfg.Auth.UsesTransitKeys():
+		client := transit.NewClient(cfg.Auth.Transit.URL, cfg.Auth.Transit.TokenFor(envConfig), cfg.Auth.Transit.Timeout())
+		keyStore, err := transit.NewKeySource(client, cfg.Auth.ActiveKID).Load()
+		if err != nil {
+			return fmt.Errorf("failed to load keys: %w", err)
+		}
+		keySet = keyStore.JWKS()
+	default:
+		keyStore, err := auth.LoadKeys(rotatorPath(cfg, *customPath), cfg.Auth.ActiveKID)
+		if err != nil {
+			return fmt.Errorf("failed to load keys: %w", err)
+		}
+		keySet = keyStore.JWKS()
+	}
+
+	data, err := json.MarshalIndent(keySet, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JWKS: %w", err)
+	}
+	data = append(data, '\n')
+
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		return fmt.Errorf("failed to write JWKS: %w", err)
+	}
+	fmt.Printf("Public keys written to %s\n", *out)
+	return nil
 }
 
 func (c *Command) runList(args []string) error {
@@ -104,6 +252,17 @@ func (c *Command) runList(args []string) error {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
+	if err := rejectTransitKeys(cfg); err != nil {
+		return err
+	}
+	if cfg.Auth.UsesDatabaseKeys() {
+		keyService, err := openKeyService(envConfig, cfg, false)
+		if err != nil {
+			return err
+		}
+		return listDatabaseKeys(keyService)
+	}
+
 	keysPath := cfg.Auth.KeysPath
 	if *customPath != "" {
 		keysPath = *customPath
@@ -124,79 +283,169 @@ func (c *Command) runSetActive(args []string) error {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
+	if err := rejectTransitKeys(cfg); err != nil {
+		return err
+	}
+	if cfg.Auth.UsesDatabaseKeys() {
+		keyService, err := openKeyService(envConfig, cfg, false)
+		if err != nil {
+			return err
+		}
+		return activateDatabaseKey(keyService, kid)
+	}
+
 	return setActiveKey(cfg, kid)
 }
 
-func generateKey(keysPath, kid string, bits int) error {
-	if err := os.MkdirAll(keysPath, 0700); err != nil {
-		return fmt.Errorf("failed to create keys directory: %w", err)
-	}
+func (c *Command) runRotate(args []string) error {
+	fs := flag.NewFlagSet("rotate", flag.ExitOnError)
+	force := fs.Bool("force", false, "Activate even if the next key was published too recently")
+	customPath := fs.String("path", "", "Custom keys directory path (overrides config)")
 
-	privPath := filepath.Join(keysPath, fmt.Sprintf("private-%s.pem", kid))
-	pubPath := filepath.Join(keysPath, fmt.Sprintf("public-%s.pem", kid))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
 
-	if _, err := os.Stat(privPath); err == nil {
-		return fmt.Errorf("key with ID %s already exists at %s", kid, privPath)
+	rotator, cfg, err := newRotator(*customPath)
+	if err != nil {
+		return err
 	}
 
-	// Test write permissions before generating keys
-	testFile := filepath.Join(keysPath, ".write-test")
-	if testF, err := os.OpenFile(testFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600); err != nil {
-		return fmt.Errorf("no write permission to keys directory %s: %w", keysPath, err)
-	} else {
-		if err := testF.Close(); err != nil {
-			return fmt.Errorf("failed to close write-test file %s: %w", testFile, err)
-		}
-		if err := os.Remove(testFile); err != nil {
-			return fmt.Errorf("failed to remove write-test file %s: %w", testFile, err)
+	active, err := rotator.Rotate(time.Now().UTC(), *force)
+	if errors.Is(err, auth.ErrNextKeyNotPublished) {
+		state, loadErr := auth.LoadRotationState(rotatorPath(cfg, *customPath))
+		if loadErr != nil {
+			return loadErr
 		}
+		next := state.Next()
+		fmt.Printf("Next key %s is published but cannot sign yet\n", next.KID)
+		fmt.Printf("  Run keys rotate again after %s, or use -force\n", next.CreatedAt.Add(cfg.Auth.KeyRotation.PrePublication()).Format(time.RFC3339))
+		return nil
 	}
-
-	fmt.Printf("Generating %d-bit RSA key pair...\n", bits)
-	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
 	if err != nil {
-		return fmt.Errorf("failed to generate RSA key: %w", err)
+		return fmt.Errorf("failed to rotate keys: %w", err)
 	}
 
-	privateKeyPEM := &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	fmt.Printf("Key %s is now active\n", active.KID)
+	fmt.Printf("  Running servers reload the keys when they notice the changed files\n")
+	return nil
+}
+
+func (c *Command) runRetire(args []string) error {
+	fs := flag.NewFlagSet("retire", flag.ExitOnError)
+	remove := fs.Bool("remove", false, "Delete the key files at once")
+	customPath := fs.String("path", "", "Custom keys directory path (overrides config)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return fmt.Errorf("key ID required")
 	}
-	fPriv, err := os.OpenFile(privPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	kid := fs.Arg(0)
+
+	envConfig := config.LoadEnv()
+	cfg, err := config.Load(envConfig.ConfigPath)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to load configuration: %w", err)
 	}
-	if err := pem.Encode(fPriv, privateKeyPEM); err != nil {
+	if err := rejectTransitKeys(cfg); err != nil {
 		return err
 	}
-	if err := fPriv.Close(); err != nil {
-		return err
+	if cfg.Auth.UsesDatabaseKeys() {
+		keyService, err := openKeyService(envConfig, cfg, false)
+		if err != nil {
+			return err
+		}
+		return retireDatabaseKey(keyService, kid, *remove)
 	}
 
-	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	rotator, cfg, err := newRotator(*customPath)
 	if err != nil {
 		return err
 	}
-	publicKeyPEM := &pem.Block{
-		Type:  "PUBLIC KEY",
-		Bytes: publicKeyBytes,
+
+	record, err := rotator.Retire(kid, time.Now().UTC(), *remove)
+	if err != nil {
+		return fmt.Errorf("failed to retire key: %w", err)
 	}
-	fPub, err := os.OpenFile(pubPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+
+	if record.State == auth.KeyStateRemoved {
+		fmt.Printf("Key %s removed\n", record.KID)
+		return nil
+	}
+	fmt.Printf("Key %s retired; it will be removed after %s\n", record.KID, record.RetiredAt.Add(cfg.Auth.KeyRotation.Retirement()).Format(time.RFC3339))
+	return nil
+}
+
+// newRotator creates a rotator for the configured keys directory with the configured rotation policy
+func newRotator(customPath string) (*auth.Rotator, *config.Config, error) {
+	envConfig := config.LoadEnv()
+	cfg, err := config.Load(envConfig.ConfigPath)
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if err := rejectTransitKeys(cfg); err != nil {
+		return nil, nil, err
+	}
+	if cfg.Auth.UsesDatabaseKeys() {
+		return nil, nil, fmt.Errorf("keys rotate requires file key storage; use keys generate and keys set-active instead")
+	}
+
+	rotation := cfg.Auth.KeyRotation
+	policy := auth.RotationPolicy{
+		Interval:       rotation.Interval(),
+		PrePublication: rotation.PrePublication(),
+		Retirement:     rotation.Retirement(),
+		Algorithm:      rotation.Algorithm,
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid auth.key_rotation settings: %w", err)
 	}
-	if err := pem.Encode(fPub, publicKeyPEM); err != nil {
-		if cerr := fPub.Close(); cerr != nil {
-			return fmt.Errorf("failed to encode public key: %w (additionally, failed to close file: %v)", err, cerr)
+
+	return auth.NewRotator(rotatorPath(cfg, customPath), cfg.Auth.ActiveKID, policy), cfg, nil
+}
+
+func rotatorPath(cfg *config.Config, customPath string) string {
+	if customPath != "" {
+		return customPath
+	}
+	return cfg.Auth.KeysPath
+}
+
+func generateKey(keysPath, kid, alg string, bits int) error {
+	if err := os.MkdirAll(keysPath, 0700); err != nil {
+		return fmt.Errorf("failed to create keys directory: %w", err)
+	}
+
+	privPath := filepath.Join(keysPath, fmt.Sprintf("private-%s.pem", kid))
+
+	if _, err := os.Stat(privPath); err == nil {
+		return fmt.Errorf("key with ID %s already exists at %s", kid, privPath)
+	}
+
+	// Test write permissions before generating keys
+	testFile := filepath.Join(keysPath, ".write-test")
+	if testF, err := os.OpenFile(testFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600); err != nil {
+		return fmt.Errorf("no write permission to keys directory %s: %w", keysPath, err)
+	} else {
+		if err := testF.Close(); err != nil {
+			return fmt.Errorf("failed to close write-test file %s: %w", testFile, err)
+		}
+		if err := os.Remove(testFile); err != nil {
+			return fmt.Errorf("failed to remove write-test file %s: %w", testFile, err)
 		}
-		return err
 	}
-	if err := fPub.Close(); err != nil {
-		return err
+
+	fmt.Printf("Generating %s key pair...\n", alg)
+	if err := auth.GenerateKeyPair(keysPath, kid, alg, bits); err != nil {
+		return fmt.Errorf("failed to generate key pair: %w", err)
 	}
 
 	fmt.Printf("Key pair generated successfully\n")
-	fmt.Printf("  Key ID: %s\n", kid)
+	fmt.Printf("  Key ID:    %s\n", kid)
+	fmt.Printf("  Algorithm: %s\n", alg)
 	return nil
 }
 
@@ -217,7 +466,13 @@ func listKeys(keysPath, activeKID string) error {
 		return nil
 	}
 
+	rotation, err := auth.LoadRotationState(keysPath)
+	if err != nil {
+		return err
+	}
+
 	fmt.Printf("Keys in %s:\n\n", keysPath)
+	activeKID = keyStore.ActiveKeyID()
 	normalizedActiveKID := activeKID
 	if !strings.HasPrefix(normalizedActiveKID, "key-") {
 		normalizedActiveKID = fmt.Sprintf("key-%s", normalizedActiveKID)
@@ -239,21 +494,36 @@ func listKeys(keysPath, activeKID string) error {
 			keyID = kid[4:]
 		}
 
-		// Get key size from raw key
+		// Describe the key type from the raw key
 		var rawKey any
-		if err := jwk.Export(key, &rawKey); err == nil {
-			if rsaKey, ok := rawKey.(*rsa.PublicKey); ok {
-				fmt.Printf("  %s%s\n", kid, active)
-				fmt.Printf("    Key size: %d bits\n", rsaKey.N.BitLen())
-				fmt.Printf("    Private:  private-%s.pem\n", keyID)
-				fmt.Printf("    Public:   public-%s.pem\n", keyID)
-				fmt.Println()
-			} else {
-				fmt.Fprintf(os.Stderr, "  %s: skipped (not an RSA key)\n", kid)
-			}
-		} else {
+		if err := jwk.Export(key, &rawKey); err != nil {
 			fmt.Fprintf(os.Stderr, "  %s: skipped (export failed: %v)\n", kid, err)
+			continue
+		}
+
+		var keyType string
+		switch k := rawKey.(type) {
+		case *rsa.PublicKey:
+			keyType = fmt.Sprintf("RSA %d bits", k.N.BitLen())
+		case *ecdsa.PublicKey:
+			keyType = fmt.Sprintf("EC %s", k.Curve.Params().Name)
+		case ed25519.PublicKey:
+			keyType = "Ed25519"
+		default:
+			fmt.Fprintf(os.Stderr, "  %s: skipped (unsupported key type)\n", kid)
+			continue
+		}
+
+		alg, _ := key.Algorithm()
+		fmt.Printf("  %s%s\n", kid, active)
+		fmt.Printf("    Key type:  %s\n", keyType)
+		fmt.Printf("    Algorithm: %s\n", alg)
+		if record := rotation.Find(keyID); record != nil {
+			fmt.Printf("    State:     %s\n", record.State)
 		}
+		fmt.Printf("    Private:   private-%s.pem\n", keyID)
+		fmt.Printf("    Public:    public-%s.pem\n", keyID)
+		fmt.Println()
 	}
 
 	fmt.Printf("Active KID: %s\n", activeKID)
@@ -271,6 +541,15 @@ func setActiveKey(cfg *config.Config, kid string) error {
 		return fmt.Errorf("key with ID %s not found", kid)
 	}
 
+	// Under rotation the active key comes from the rotation state, not the config
+	rotation, err := auth.LoadRotationState(cfg.Auth.KeysPath)
+	if err != nil {
+		return err
+	}
+	if rotation.Active() != nil {
+		return fmt.Errorf("keys in %s are managed by rotation; use keys rotate instead", cfg.Auth.KeysPath)
+	}
+
 	fmt.Printf("To set active key, update config.yaml:\n\n")
 	fmt.Printf("  auth:\n")
 	fmt.Printf("    active_kid: %s\n", kid)
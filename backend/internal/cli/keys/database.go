@@ -0,0 +1,115 @@
+package keys
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Anvoria/authly/internal/config"
+	"github.com/Anvoria/authly/internal/database"
+	"github.com/Anvoria/authly/internal/domain/auth"
+	"github.com/Anvoria/authly/internal/domain/signingkey"
+	"github.com/Anvoria/authly/internal/migrations"
+)
+
+// openKeyService connects to the database for auth.key_storage database. The key-encryption key is
+// only required by commands that read or write private keys.
+func openKeyService(envConfig *config.Environment, cfg *config.Config, withKEK bool) (signingkey.Service, error) {
+	var kek *signingkey.KeyEncryptionKey
+	if withKEK {
+		var err error
+		kek, err = signingkey.ParseKeyEncryptionKey(cfg.Auth.KeyEncryptionKeyFor(envConfig))
+		if err != nil {
+			return nil, fmt.Errorf("%w (set KEY_ENCRYPTION_KEY or auth.key_encryption_key)", err)
+		}
+	}
+
+	if err := database.ConnectDB(cfg); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err := migrations.RunMigrations(cfg); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	return signingkey.NewService(signingkey.NewRepository(database.DB), kek), nil
+}
+
+func generateDatabaseKey(keyService signingkey.Service, kid, alg string, bits int) error {
+	fmt.Printf("Generating %s key...\n", alg)
+	key, err := keyService.Generate(kid, alg, bits)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	printStoredKey(key)
+	return nil
+}
+
+func importDatabaseKey(keyService signingkey.Service, kid string, privateKeyPEM []byte) error {
+	key, err := keyService.Import(kid, privateKeyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to import key: %w", err)
+	}
+
+	fmt.Printf("Key imported successfully\n")
+	printStoredKey(key)
+	return nil
+}
+
+func printStoredKey(key *signingkey.SigningKey) {
+	fmt.Printf("  Key ID:    %s\n", key.KID)
+	fmt.Printf("  Algorithm: %s\n", key.Algorithm)
+	fmt.Printf("  State:     %s\n", key.State)
+	if key.State != auth.KeyStateActive {
+		fmt.Printf("  Run keys set-active %s to sign with it\n", key.KID)
+	}
+}
+
+func listDatabaseKeys(keyService signingkey.Service) error {
+	keys, err := keyService.List()
+	if err != nil {
+		return fmt.Errorf("failed to list keys: %w", err)
+	}
+	if len(keys) == 0 {
+		fmt.Printf("No keys found in the database\n")
+		return nil
+	}
+
+	fmt.Printf("Keys in the database:\n\n")
+	for _, key := range keys {
+		fmt.Printf("  key-%s\n", key.KID)
+		fmt.Printf("    Algorithm: %s\n", key.Algorithm)
+		fmt.Printf("    State:     %s\n", key.State)
+		fmt.Printf("    Created:   %s\n", key.CreatedAt.UTC().Format(time.RFC3339))
+		if key.ActivatedAt != nil {
+			fmt.Printf("    Activated: %s\n", key.ActivatedAt.UTC().Format(time.RFC3339))
+		}
+		if key.RetiredAt != nil {
+			fmt.Printf("    Retired:   %s\n", key.RetiredAt.UTC().Format(time.RFC3339))
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func activateDatabaseKey(keyService signingkey.Service, kid string) error {
+	if err := keyService.Activate(kid); err != nil {
+		return fmt.Errorf("failed to activate key: %w", err)
+	}
+
+	fmt.Printf("Key %s is now active\n", kid)
+	fmt.Printf("  Running servers reload the keys when they notice the change\n")
+	return nil
+}
+
+func retireDatabaseKey(keyService signingkey.Service, kid string, remove bool) error {
+	if err := keyService.Retire(kid, remove); err != nil {
+		return fmt.Errorf("failed to retire key: %w", err)
+	}
+
+	if remove {
+		fmt.Printf("Key %s removed\n", kid)
+		return nil
+	}
+	fmt.Printf("Key %s retired; it stays published until removed with keys retire -remove\n", kid)
+	return nil
+}
@@ -0,0 +1,51 @@
+package keys
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Anvoria/authly/internal/config"
+	"github.com/Anvoria/authly/internal/transit"
+)
+
+// runTransitServe runs the local stand-in for a transit signing service, serving the keys of a keys directory
+func (c *Command) runTransitServe(args []string) error {
+	fs := flag.NewFlagSet("transit-serve", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:8200", "Address to listen on")
+	customPath := fs.String("path", "", "Custom keys directory path (overrides config)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	envConfig := config.LoadEnv()
+	cfg, err := config.Load(envConfig.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	keysPath := rotatorPath(cfg, *customPath)
+	token := cfg.Auth.Transit.TokenFor(envConfig)
+	standIn, err := transit.NewServerFromDir(keysPath, token)
+	if err != nil {
+		return fmt.Errorf("failed to load keys: %w", err)
+	}
+	if token == "" {
+		fmt.Printf("Warning: no TRANSIT_TOKEN set; requests are not authenticated\n")
+	}
+
+	fmt.Printf("Transit stand-in serving the keys in %s on http://%s\n", keysPath, *listen)
+	fmt.Printf("  It holds the private keys in memory; use it for development only\n")
+	server := &http.Server{Addr: *listen, Handler: standIn, ReadHeaderTimeout: 10 * time.Second}
+	return server.ListenAndServe()
+}
+
+// rejectTransitKeys fails commands that manage private keys, which the transit service holds
+func rejectTransitKeys(cfg *config.Config) error {
+	if cfg.Auth.UsesTransitKeys() {
+		return fmt.Errorf("signing keys are held by the transit service at %s; manage them there", cfg.Auth.Transit.URL)
+	}
+	return nil
+}
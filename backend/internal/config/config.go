@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/goccy/go-yaml"
 )
@@ -44,6 +45,156 @@ type RateLimitConfig struct {
 type AuthConfig struct {
 	KeysPath  string `yaml:"keys_path"`
 	ActiveKID string `yaml:"active_kid"`
+
+	// RegistrationTokens are the initial access tokens accepted by dynamic client registration (RFC 7591).
+	// Registration is disabled when empty.
+	RegistrationTokens []string `yaml:"registration_tokens"`
+
+	// PairwiseSecret keys the pairwise subject identifiers of services with subject_type pairwise.
+	// Changing it changes every pairwise subject.
+	PairwiseSecret string `yaml:"pairwise_secret"`
+
+	// RefreshTokenReuseGrace is how many seconds a rotated refresh token may still be presented, by
+	// concurrent refreshes, before it counts as stolen and revokes its session. Zero uses the default.
+	RefreshTokenReuseGrace int `yaml:"refresh_token_reuse_grace"`
+
+	// ReuseNotification tells users when refresh token reuse revoked one of their sessions
+	ReuseNotification ReuseNotificationConfig `yaml:"reuse_notification"`
+
+	// KeysReloadInterval is how many seconds pass between checks of the key storage for changed keys.
+	// Zero uses the default.
+	KeysReloadInterval int `yaml:"keys_reload_interval"`
+
+	// KeyRotation schedules signing key rotation in keys_path
+	KeyRotation KeyRotationConfig `yaml:"key_rotation"`
+
+	// KeyStorage is where the signing keys live: "file" reads keys_path, "database" the signing_keys
+	// table shared by every instance, and "transit" a remote signing service that keeps the private
+	// keys in an HSM. Empty means file.
+	KeyStorage string `yaml:"key_storage"`
+
+	// KeyEncryptionKey is the base64-encoded 32-byte key that encrypts the private keys in the database.
+	// The KEY_ENCRYPTION_KEY environment variable takes precedence.
+	KeyEncryptionKey string `yaml:"key_encryption_key"`
+
+	// Transit is the signing service used by key_storage transit
+	Transit TransitConfig `yaml:"transit"`
+}
+
+// TransitConfig holds the connection to a transit signing service
+type TransitConfig struct {
+	URL string `yaml:"url"`
+
+	// Token authenticates to the service; the TRANSIT_TOKEN environment variable takes precedence
+	Token string `yaml:"token"`
+
+	// TimeoutSeconds bounds each request to the service. Zero uses the default.
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+}
+
+// Timeout returns the request timeout
+func (t *TransitConfig) Timeout() time.Duration {
+	return time.Duration(t.TimeoutSeconds) * time.Second
+}
+
+// TokenFor returns the service token, preferring the environment over the config file
+func (t *TransitConfig) TokenFor(env *Environment) string {
+	if env != nil && env.TransitToken != "" {
+		return env.TransitToken
+	}
+	return t.Token
+}
+
+// ReuseNotificationConfig selects how users learn that a stolen refresh token was used
+type ReuseNotificationConfig struct {
+	// Channel is "log", which records the notification in the log, or "email", which mails the user
+	// through SMTP. Empty disables notifications; the security event is logged either way.
+	Channel string `yaml:"channel"`
+
+	SMTP SMTPConfig `yaml:"smtp"`
+}
+
+// SMTPConfig holds the mail server used by the email notification channel
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+
+	// Password authenticates to the server; the SMTP_PASSWORD environment variable takes precedence
+	Password string `yaml:"password"`
+
+	// From is the sender address of the notifications
+	From string `yaml:"from"`
+}
+
+// PasswordFor returns the SMTP password, preferring the environment over the config file
+func (s *SMTPConfig) PasswordFor(env *Environment) string {
+	if env != nil && env.SMTPPassword != "" {
+		return env.SMTPPassword
+	}
+	return s.Password
+}
+
+const (
+	ReuseNotificationLog   = "log"
+	ReuseNotificationEmail = "email"
+)
+
+const (
+	KeyStorageFile     = "file"
+	KeyStorageDatabase = "database"
+	KeyStorageTransit  = "transit"
+)
+
+// KeyEncryptionKeyFor returns the key-encryption key, preferring the environment over the config file
+func (a *AuthConfig) KeyEncryptionKeyFor(env *Environment) string {
+	if env != nil && env.KeyEncryptionKey != "" {
+		return env.KeyEncryptionKey
+	}
+	return a.KeyEncryptionKey
+}
+
+// UsesDatabaseKeys reports whether the signing keys are stored in the database
+func (a *AuthConfig) UsesDatabaseKeys() bool {
+	return a.KeyStorage == KeyStorageDatabase
+}
+
+// UsesTransitKeys reports whether the signing keys are held by a transit signing service
+func (a *AuthConfig) UsesTransitKeys() bool {
+	return a.KeyStorage == KeyStorageTransit
+}
+
+// KeyRotationConfig holds signing key rotation configuration.
+// Only one instance should run scheduled rotation, and it needs a writable keys_path.
+type KeyRotationConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// IntervalHours is how long a key signs before the next key replaces it
+	IntervalHours int `yaml:"interval_hours"`
+
+	// PrePublicationHours is how long the next key is in the JWKS before it signs
+	PrePublicationHours int `yaml:"pre_publication_hours"`
+
+	// RetirementHours is how long a replaced key stays in the JWKS; at least the ID token lifetime
+	RetirementHours int `yaml:"retirement_hours"`
+
+	// Algorithm of new keys; empty keeps the algorithm of the active key
+	Algorithm string `yaml:"algorithm"`
+}
+
+// Interval returns the rotation interval
+func (k *KeyRotationConfig) Interval() time.Duration {
+	return time.Duration(k.IntervalHours) * time.Hour
+}
+
+// PrePublication returns how long the next key is published before it signs
+func (k *KeyRotationConfig) PrePublication() time.Duration {
+	return time.Duration(k.PrePublicationHours) * time.Hour
+}
+
+// Retirement returns how long a replaced key stays published
+func (k *KeyRotationConfig) Retirement() time.Duration {
+	return time.Duration(k.RetirementHours) * time.Hour
 }
 
 // DatabaseConfig holds database-specific configuration
@@ -95,6 +246,55 @@ func Load(path string) (*Config, error) {
 
 // Validate checks the configuration for potential issues
 func (c *Config) Validate() error {
+	if c.Auth.RefreshTokenReuseGrace < 0 {
+		return fmt.Errorf("auth.refresh_token_reuse_grace cannot be negative")
+	}
+
+	switch notification := c.Auth.ReuseNotification; notification.Channel {
+	case "", ReuseNotificationLog:
+	case ReuseNotificationEmail:
+		if notification.SMTP.Host == "" || notification.SMTP.Port <= 0 || notification.SMTP.From == "" {
+			return fmt.Errorf("auth.reuse_notification.smtp requires host, port and from for email notifications")
+		}
+	default:
+		return fmt.Errorf("auth.reuse_notification.channel must be %q or %q", ReuseNotificationLog, ReuseNotificationEmail)
+	}
+
+	if c.Auth.KeysReloadInterval < 0 {
+		return fmt.Errorf("auth.keys_reload_interval cannot be negative")
+	}
+
+	switch c.Auth.KeyStorage {
+	case "", KeyStorageFile, KeyStorageDatabase, KeyStorageTransit:
+	default:
+		return fmt.Errorf("auth.key_storage must be %q, %q or %q", KeyStorageFile, KeyStorageDatabase, KeyStorageTransit)
+	}
+
+	if c.Auth.KeyStorage != "" && c.Auth.KeyStorage != KeyStorageFile && c.Auth.KeyRotation.Enabled {
+		return fmt.Errorf("auth.key_rotation requires file key storage")
+	}
+
+	if c.Auth.UsesTransitKeys() {
+		if c.Auth.Transit.URL == "" {
+			return fmt.Errorf("auth.transit.url is required for transit key storage")
+		}
+		if c.Auth.Transit.TimeoutSeconds < 0 {
+			return fmt.Errorf("auth.transit.timeout_seconds cannot be negative")
+		}
+	}
+
+	if rotation := c.Auth.KeyRotation; rotation.Enabled {
+		if rotation.IntervalHours <= 0 {
+			return fmt.Errorf("auth.key_rotation.interval_hours must be positive")
+		}
+		if rotation.PrePublicationHours < 0 {
+			return fmt.Errorf("auth.key_rotation.pre_publication_hours cannot be negative")
+		}
+		if rotation.RetirementHours < 1 {
+			return fmt.Errorf("auth.key_rotation.retirement_hours must be at least 1")
+		}
+	}
+
 	if len(c.Server.AllowedOrigins) == 0 {
 		return fmt.Errorf("server.allowed_origins cannot be empty")
 	}
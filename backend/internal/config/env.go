@@ -35,6 +35,15 @@ type Environment struct {
 	Environment EnvironmentType `env:"ENVIRONMENT"`
 	ConfigPath  string          `env:"CONFIG_PATH"`
 	JWTSecret   string          `env:"JWT_SECRET"`
+
+	// KeyEncryptionKey overrides auth.key_encryption_key so that it can be kept out of the config file
+	KeyEncryptionKey string `env:"KEY_ENCRYPTION_KEY"`
+
+	// TransitToken overrides auth.transit.token
+	TransitToken string `env:"TRANSIT_TOKEN"`
+
+	// SMTPPassword overrides auth.reuse_notification.smtp.password
+	SMTPPassword string `env:"SMTP_PASSWORD"`
 }
 
 // LoadEnv loads the environment variables
@@ -56,6 +65,10 @@ func LoadEnv() *Environment {
 		Environment: envType,
 		ConfigPath:  getEnv("CONFIG_PATH", "config.yaml"),
 		JWTSecret:   getEnv("JWT_SECRET", ""),
+
+		KeyEncryptionKey: getEnv("KEY_ENCRYPTION_KEY", ""),
+		TransitToken:     getEnv("TRANSIT_TOKEN", ""),
+		SMTPPassword:     getEnv("SMTP_PASSWORD", ""),
 	}
 }
 
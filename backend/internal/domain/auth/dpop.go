@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	"github.com/lestrrat-go/jwx/v3/jws"
+	"github.com/lestrrat-go/jwx/v3/jwt"
+)
+
+const (
+	// DPoPScheme is the Authorization scheme for DPoP-bound access tokens (RFC 9449 Section 7.1)
+	DPoPScheme = "DPoP"
+	// DPoPHeader carries the DPoP proof JWT
+	DPoPHeader = "DPoP"
+
+	dpopProofType = "dpop+jwt"
+
+	// dpopProofWindow is how far a proof's iat may be from the server clock
+	dpopProofWindow = 1 * time.Minute
+)
+
+// DPoPProof is a verified DPoP proof (RFC 9449 Section 4)
+type DPoPProof struct {
+	// JKT is the base64url SHA-256 JWK thumbprint of the proof key (RFC 7638)
+	JKT      string
+	JTI      string
+	IssuedAt time.Time
+}
+
+// WithConfirmation binds the access token to a DPoP key via the "cnf" claim (RFC 9449 Section 6)
+func WithConfirmation(jkt string) AccessTokenOption {
+	return func(token jwt.Token) error {
+		return token.Set("cnf", map[string]any{"jkt": jkt})
+	}
+}
+
+// VerifyDPoP verifies a DPoP proof for the current request and records its jti in Redis so the
+// same proof cannot be presented twice. accessToken is empty at the token endpoint.
+func (s *Service) VerifyDPoP(proof, method, htu, accessToken string) (*DPoPProof, error) {
+	verified, err := VerifyDPoPProof(proof, method, htu, accessToken, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if s.replayCache == nil {
+		return nil, fmt.Errorf("replay cache not configured")
+	}
+
+	// A proof is only accepted inside the iat window, so its jti never needs to be kept longer
+	first, err := s.replayCache.Claim(context.Background(), "dpop:"+verified.JKT, verified.JTI, 2*dpopProofWindow)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record dpop proof: %w", err)
+	}
+	if !first {
+		return nil, ErrDPoPProofReplayed
+	}
+
+	return verified, nil
+}
+
+// VerifyDPoPProof checks a DPoP proof JWT against the request it was sent with: the signature must
+// verify with the public key embedded in its header, and htm, htu and iat must match the request.
+// When accessToken is non-empty the proof's ath claim must be its hash (RFC 9449 Section 4.3).
+func VerifyDPoPProof(proof, method, htu, accessToken string, now time.Time) (*DPoPProof, error) {
+	msg, err := jws.Parse([]byte(proof))
+	if err != nil {
+		return nil, ErrInvalidDPoPProof
+	}
+
+	signatures := msg.Signatures()
+	if len(signatures) != 1 {
+		return nil, ErrInvalidDPoPProof
+	}
+	headers := signatures[0].ProtectedHeaders()
+
+	if typ, ok := headers.Type(); !ok || typ != dpopProofType {
+		return nil, ErrInvalidDPoPProof
+	}
+
+	alg, ok := headers.Algorithm()
+	if !ok || alg.IsSymmetric() || alg.String() == "none" {
+		return nil, ErrInvalidDPoPProof
+	}
+
+	key, ok := headers.JWK()
+	if !ok {
+		return nil, ErrInvalidDPoPProof
+	}
+	if private, err := jwk.IsPrivateKey(key); err != nil || private {
+		return nil, ErrInvalidDPoPProof
+	}
+
+	token, err := jwt.Parse([]byte(proof), jwt.WithKey(alg, key), jwt.WithValidate(false))
+	if err != nil {
+		return nil, ErrInvalidDPoPProof
+	}
+
+	var htm, proofHTU string
+	if token.Get("htm", &htm) != nil || htm != method {
+		return nil, ErrInvalidDPoPProof
+	}
+	if token.Get("htu", &proofHTU) != nil || !sameHTU(proofHTU, htu) {
+		return nil, ErrInvalidDPoPProof
+	}
+
+	iat, ok := token.IssuedAt()
+	if !ok || iat.Before(now.Add(-dpopProofWindow)) || iat.After(now.Add(dpopProofWindow)) {
+		return nil, ErrInvalidDPoPProof
+	}
+
+	jti, ok := token.JwtID()
+	if !ok || jti == "" {
+		return nil, ErrInvalidDPoPProof
+	}
+
+	if accessToken != "" {
+		var ath string
+		hash := sha256.Sum256([]byte(accessToken))
+		if token.Get("ath", &ath) != nil || ath != base64.RawURLEncoding.EncodeToString(hash[:]) {
+			return nil, ErrInvalidDPoPProof
+		}
+	}
+
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, ErrInvalidDPoPProof
+	}
+
+	return &DPoPProof{
+		JKT:      base64.RawURLEncoding.EncodeToString(thumbprint),
+		JTI:      jti,
+		IssuedAt: iat,
+	}, nil
+}
+
+// sameHTU compares two request URIs ignoring query and fragment (RFC 9449 Section 4.3)
+func sameHTU(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(ua.Scheme, ub.Scheme) &&
+		strings.EqualFold(ua.Host, ub.Host) &&
+		ua.EscapedPath() == ub.EscapedPath()
+}
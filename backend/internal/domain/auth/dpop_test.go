@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	"github.com/lestrrat-go/jwx/v3/jws"
+	"github.com/lestrrat-go/jwx/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testHTU = "https://auth.example.com/v1/oauth/token"
+
+func newDPoPProof(t *testing.T, typ string, iat time.Time, claims map[string]any) string {
+	t.Helper()
+
+	raw, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	key, err := jwk.Import(raw)
+	require.NoError(t, err)
+	public, err := key.PublicKey()
+	require.NoError(t, err)
+
+	token, err := jwt.NewBuilder().JwtID("proof-1").IssuedAt(iat).Build()
+	require.NoError(t, err)
+	for name, value := range claims {
+		require.NoError(t, token.Set(name, value))
+	}
+
+	headers := jws.NewHeaders()
+	require.NoError(t, headers.Set(jws.TypeKey, typ))
+	require.NoError(t, headers.Set(jws.JWKKey, public))
+
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.ES256(), key, jws.WithProtectedHeaders(headers)))
+	require.NoError(t, err)
+
+	return string(signed)
+}
+
+func TestVerifyDPoPProof(t *testing.T) {
+	now := time.Now()
+	claims := map[string]any{"htm": "POST", "htu": testHTU}
+
+	t.Run("valid proof", func(t *testing.T) {
+		proof := newDPoPProof(t, dpopProofType, now, claims)
+
+		verified, err := VerifyDPoPProof(proof, "POST", testHTU+"?ignored=1", "", now)
+		require.NoError(t, err)
+		assert.Equal(t, "proof-1", verified.JTI)
+		assert.NotEmpty(t, verified.JKT)
+	})
+
+	t.Run("wrong method", func(t *testing.T) {
+		proof := newDPoPProof(t, dpopProofType, now, claims)
+
+		_, err := VerifyDPoPProof(proof, "GET", testHTU, "", now)
+		assert.ErrorIs(t, err, ErrInvalidDPoPProof)
+	})
+
+	t.Run("wrong uri", func(t *testing.T) {
+		proof := newDPoPProof(t, dpopProofType, now, claims)
+
+		_, err := VerifyDPoPProof(proof, "POST", "https://auth.example.com/v1/oauth/userinfo", "", now)
+		assert.ErrorIs(t, err, ErrInvalidDPoPProof)
+	})
+
+	t.Run("stale iat", func(t *testing.T) {
+		proof := newDPoPProof(t, dpopProofType, now.Add(-5*time.Minute), claims)
+
+		_, err := VerifyDPoPProof(proof, "POST", testHTU, "", now)
+		assert.ErrorIs(t, err, ErrInvalidDPoPProof)
+	})
+
+	t.Run("wrong typ", func(t *testing.T) {
+		proof := newDPoPProof(t, "JWT", now, claims)
+
+		_, err := VerifyDPoPProof(proof, "POST", testHTU, "", now)
+		assert.ErrorIs(t, err, ErrInvalidDPoPProof)
+	})
+
+	t.Run("access token hash", func(t *testing.T) {
+		hash := sha256.Sum256([]byte("access-token"))
+		proof := newDPoPProof(t, dpopProofType, now, map[string]any{
+			"htm": "GET",
+			"htu": "https://auth.example.com/v1/oauth/userinfo",
+			"ath": base64.RawURLEncoding.EncodeToString(hash[:]),
+		})
+
+		_, err := VerifyDPoPProof(proof, "GET", "https://auth.example.com/v1/oauth/userinfo", "access-token", now)
+		require.NoError(t, err)
+
+		_, err = VerifyDPoPProof(proof, "GET", "https://auth.example.com/v1/oauth/userinfo", "other-token", now)
+		assert.ErrorIs(t, err, ErrInvalidDPoPProof)
+	})
+}
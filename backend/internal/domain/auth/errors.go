@@ -14,6 +14,14 @@ var (
 	// ErrUnknownKey is returned when a JWT token contains a key ID (kid) that
 	// is not found in the key store during token verification.
 	ErrUnknownKey = errors.New("unknown key")
+
+	// ErrNoKeyForAlgorithm is returned when a token must be signed with an
+	// algorithm that none of the loaded keys uses.
+	ErrNoKeyForAlgorithm = errors.New("no key for signing algorithm")
+
+	// ErrNotAccessToken is returned when a validly signed JWT, such as an ID token,
+	// is presented where an access token is expected.
+	ErrNotAccessToken = errors.New("token is not an access token")
 )
 
 // Key loading errors
@@ -85,28 +93,34 @@ func (e *ErrFailedToDecodePrivateKeyPEM) Error() string {
 }
 
 // ErrFailedToParsePrivateKey is returned when a private key cannot be parsed
-// from PEM data, after attempting both PKCS1 and PKCS8 formats.
+// from PEM data, after attempting the PKCS1, SEC1 and PKCS8 formats.
 type ErrFailedToParsePrivateKey struct {
 	FileName string
 	Err      error
 }
 
 func (e *ErrFailedToParsePrivateKey) Error() string {
-	return "failed to parse private key from " + e.FileName + " (tried PKCS1 and PKCS8): " + e.Err.Error()
+	return "failed to parse private key from " + e.FileName + " (tried PKCS1, SEC1 and PKCS8): " + e.Err.Error()
 }
 
 func (e *ErrFailedToParsePrivateKey) Unwrap() error {
 	return e.Err
 }
 
-// ErrPrivateKeyNotRSA is returned when a private key file contains a key
-// that is not an RSA key.
-type ErrPrivateKeyNotRSA struct {
+// ErrUnsupportedKey is returned when a private key file contains a key that
+// is not an RSA, EC P-256/P-384 or Ed25519 key, or whose Algorithm header
+// does not fit the key.
+type ErrUnsupportedKey struct {
 	FileName string
+	Err      error
 }
 
-func (e *ErrPrivateKeyNotRSA) Error() string {
-	return "private key in " + e.FileName + " is not an RSA key"
+func (e *ErrUnsupportedKey) Error() string {
+	return "unsupported private key in " + e.FileName + ": " + e.Err.Error()
+}
+
+func (e *ErrUnsupportedKey) Unwrap() error {
+	return e.Err
 }
 
 // ErrFailedToReadPublicKeyFile is returned when a public key file cannot
@@ -149,14 +163,14 @@ func (e *ErrFailedToParsePublicKey) Unwrap() error {
 	return e.Err
 }
 
-// ErrPublicKeyNotRSA is returned when a public key file contains a key
-// that is not an RSA key.
-type ErrPublicKeyNotRSA struct {
+// ErrPublicKeyMismatch is returned when a public key file does not contain
+// the public half of the private key with the same key ID.
+type ErrPublicKeyMismatch struct {
 	FileName string
 }
 
-func (e *ErrPublicKeyNotRSA) Error() string {
-	return "public key in " + e.FileName + " is not an RSA key"
+func (e *ErrPublicKeyMismatch) Error() string {
+	return "public key in " + e.FileName + " does not match its private key"
 }
 
 // Middleware errors
@@ -192,6 +206,18 @@ var (
 	ErrServiceNotFoundForDomain = errors.New("service not found for domain")
 )
 
+// DPoP errors
+var (
+	// ErrInvalidDPoPProof is returned when a DPoP proof is missing, malformed, or not made for the request
+	ErrInvalidDPoPProof = errors.New("invalid dpop proof")
+
+	// ErrDPoPProofReplayed is returned when a DPoP proof's jti has already been used
+	ErrDPoPProofReplayed = errors.New("dpop proof replayed")
+
+	// ErrDPoPKeyMismatch is returned when the proof key does not match the key the token is bound to
+	ErrDPoPKeyMismatch = errors.New("dpop key mismatch")
+)
+
 // Handler errors
 var (
 	// ErrInvalidBody is returned when the request body is invalid
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SigningAlgorithms are the algorithms GenerateKeyPair can create keys for
+var SigningAlgorithms = []string{"RS256", "PS256", "ES256", "ES384", "EdDSA"}
+
+// GenerateKeyPair creates a key pair for the algorithm and writes it to path as
+// private-<kid>.pem and public-<kid>.pem. bits only applies to RSA keys.
+// Existing files are never overwritten.
+func GenerateKeyPair(path, kid, alg string, bits int) error {
+	privateKey, privateKeyPEM, err := newPrivateKey(alg, bits)
+	if err != nil {
+		return err
+	}
+
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return fmt.Errorf("failed to encode public key: %w", err)
+	}
+	publicKeyPEM := &pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: publicKeyBytes,
+	}
+
+	privPath := filepath.Join(path, fmt.Sprintf("private-%s.pem", kid))
+	pubPath := filepath.Join(path, fmt.Sprintf("public-%s.pem", kid))
+
+	if err := writePEM(privPath, privateKeyPEM, 0600); err != nil {
+		return err
+	}
+	if err := writePEM(pubPath, publicKeyPEM, 0644); err != nil {
+		// A private key without its public half would fail LoadKeys
+		_ = os.Remove(privPath)
+		return err
+	}
+
+	return nil
+}
+
+// GenerateSigningKey creates a private key for the algorithm; bits only applies to RSA keys
+func GenerateSigningKey(alg string, bits int) (crypto.Signer, error) {
+	privateKey, _, err := newPrivateKey(alg, bits)
+	return privateKey, err
+}
+
+// RemoveKeyPair deletes the key files of kid; files that are already gone are ignored
+func RemoveKeyPair(path, kid string) error {
+	for _, fileName := range []string{fmt.Sprintf("private-%s.pem", kid), fmt.Sprintf("public-%s.pem", kid)} {
+		if err := os.Remove(filepath.Join(path, fileName)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", fileName, err)
+		}
+	}
+	return nil
+}
+
+// newPrivateKey generates a key for the algorithm and encodes it the way LoadKeys reads it:
+// RSA keys as PKCS #1, with an Algorithm header for PS256, and EC and Ed25519 keys as PKCS #8
+func newPrivateKey(alg string, bits int) (crypto.Signer, *pem.Block, error) {
+	var privateKey crypto.Signer
+	var err error
+	switch alg {
+	case "RS256", "PS256":
+		rsaKey, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate RSA key: %w", err)
+		}
+
+		block := &pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+		}
+		if alg == "PS256" {
+			block.Headers = map[string]string{KeyAlgorithmHeader: alg}
+		}
+		return rsaKey, block, nil
+	case "ES256":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "EdDSA":
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, nil, fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate %s key: %w", alg, err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	return privateKey, &pem.Block{Type: "PRIVATE KEY", Bytes: der}, nil
+}
+
+// writePEM writes a single PEM block to a new file
+func writePEM(path string, block *pem.Block, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(f, block); err != nil {
+		if cerr := f.Close(); cerr != nil {
+			return fmt.Errorf("failed to encode %s: %w (additionally, failed to close file: %v)", filepath.Base(path), err, cerr)
+		}
+		return err
+	}
+	return f.Close()
+}
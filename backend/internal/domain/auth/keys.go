@@ -1,21 +1,147 @@
 package auth
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/lestrrat-go/jwx/v3/jwa"
 	"github.com/lestrrat-go/jwx/v3/jwk"
 )
 
+// KeyAlgorithmHeader is the PEM header of a private key file that selects the signing algorithm.
+// It is only needed for RSA keys used with PS256; every other key implies its algorithm.
+const KeyAlgorithmHeader = "Algorithm"
+
+// KeyStore holds the signing keys. Handlers share one KeyStore by pointer while Reload swaps its
+// keys, so after LoadKeys the fields are only read through its methods.
 type KeyStore struct {
 	ActiveKid string
-	KeySet    jwk.Set
+
+	// KeySet holds the public keys, which tokens are verified with and the JWKS is served from
+	KeySet jwk.Set
+
+	// signers sign with the private halves of the keys, by JWK key ID
+	signers map[string]crypto.Signer
+
+	// states are the rotation states of keys managed by a Rotator, by JWK key ID.
+	// Keys without a state sign like the active key's peers always have.
+	states map[string]KeyState
+
+	// source is where Reload reads the keys again
+	source KeySource
+
+	mu sync.RWMutex
+
+	// reloadMu serializes reloads so that an older read of the keys never replaces a newer one
+	reloadMu sync.Mutex
+}
+
+// KeySource is where the keys of a KeyStore come from: a keys directory or the database
+type KeySource interface {
+	// Load reads and validates every key into a new KeyStore
+	Load() (*KeyStore, error)
+
+	// Fingerprint changes whenever the keys change, so that they can be watched cheaply
+	Fingerprint() (string, error)
+}
+
+// dirSource reads the keys directory like LoadKeys
+type dirSource struct {
+	path      string
+	activeKid string
+}
+
+func (s *dirSource) Load() (*KeyStore, error) {
+	return LoadKeys(s.path, s.activeKid)
+}
+
+func (s *dirSource) Fingerprint() (string, error) {
+	return keysFingerprint(s.path)
+}
+
+// NewKeyStore creates a key store from keys made by NewSigningKey. states are the lifecycle states
+// of the keys by JWK key ID; source is where Reload reads the keys again.
+func NewKeyStore(activeKid string, keys []SigningKey, states map[string]KeyState, source KeySource) (*KeyStore, error) {
+	keySet := jwk.NewSet()
+	signers := make(map[string]crypto.Signer, len(keys))
+	for _, key := range keys {
+		if err := keySet.AddKey(key.Public); err != nil {
+			return nil, fmt.Errorf("failed to add key to set: %w", err)
+		}
+		keyID, _ := key.Public.KeyID()
+		signers[keyID] = key.Signer
+	}
+
+	return &KeyStore{
+		ActiveKid: activeKid,
+		KeySet:    keySet,
+		signers:   signers,
+		states:    states,
+		source:    source,
+	}, nil
+}
+
+// SigningKey is a key of a KeyStore: the public JWK it publishes, and the Signer holding the private half.
+// A parsed private key signs in memory; a Signer such as a transit client keeps the private key in an HSM.
+type SigningKey struct {
+	Public jwk.Key
+	Signer crypto.Signer
+}
+
+// NewSigningKey describes the key of signer as a public JWK with the key ID and signing algorithm set.
+// alg may be empty to infer it from the key, and must otherwise fit the key.
+func NewSigningKey(kid string, signer crypto.Signer, alg string) (SigningKey, error) {
+	signingAlg, err := keyAlgorithm(signer.Public(), alg)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	jwkKey, err := jwk.Import(signer.Public())
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("failed to convert public key to JWK: %w", err)
+	}
+
+	if err := jwkKey.Set(jwk.KeyIDKey, fmt.Sprintf("key-%s", kid)); err != nil {
+		return SigningKey{}, fmt.Errorf("failed to set key ID: %w", err)
+	}
+
+	if err := jwkKey.Set(jwk.AlgorithmKey, signingAlg); err != nil {
+		return SigningKey{}, fmt.Errorf("failed to set algorithm: %w", err)
+	}
+
+	return SigningKey{Public: jwkKey, Signer: signer}, nil
+}
+
+// ParsePrivateKeyPEM parses a private key file as LoadKeys reads it and returns the key with its signing algorithm
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, string, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, "", errors.New("no PEM block found")
+	}
+
+	priv, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	alg, err := keyAlgorithm(priv.Public(), block.Headers[KeyAlgorithmHeader])
+	if err != nil {
+		return nil, "", err
+	}
+	return priv, alg.String(), nil
 }
 
 func LoadKeys(path, activeKid string) (*KeyStore, error) {
@@ -27,7 +153,18 @@ func LoadKeys(path, activeKid string) (*KeyStore, error) {
 		return nil, &ErrKeysPathNotDirectory{Path: path}
 	}
 
-	keySet := jwk.NewSet()
+	// Keys under rotation take their active key from the rotation state instead of the config
+	rotation, err := LoadRotationState(path)
+	if err != nil {
+		return nil, err
+	}
+	source := &dirSource{path: path, activeKid: activeKid}
+	if active := rotation.Active(); active != nil {
+		activeKid = active.KID
+	}
+
+	var keys []SigningKey
+	states := make(map[string]KeyState)
 
 	files, err := os.ReadDir(path)
 	if err != nil {
@@ -51,6 +188,11 @@ func LoadKeys(path, activeKid string) (*KeyStore, error) {
 			continue
 		}
 
+		record := rotation.Find(kid)
+		if record != nil && record.State == KeyStateRemoved {
+			continue
+		}
+
 		privPath := filepath.Join(path, fileName)
 		privData, err := os.ReadFile(privPath)
 		if err != nil {
@@ -62,17 +204,9 @@ func LoadKeys(path, activeKid string) (*KeyStore, error) {
 			return nil, &ErrFailedToDecodePrivateKeyPEM{FileName: fileName}
 		}
 
-		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		priv, err := parsePrivateKey(block.Bytes)
 		if err != nil {
-			pkcs8Key, err2 := x509.ParsePKCS8PrivateKey(block.Bytes)
-			if err2 != nil {
-				return nil, &ErrFailedToParsePrivateKey{FileName: fileName, Err: err}
-			}
-			rsaKey, ok := pkcs8Key.(*rsa.PrivateKey)
-			if !ok {
-				return nil, &ErrPrivateKeyNotRSA{FileName: fileName}
-			}
-			priv = rsaKey
+			return nil, &ErrFailedToParsePrivateKey{FileName: fileName, Err: err}
 		}
 
 		pubFileName := fmt.Sprintf("public-%s.pem", kid)
@@ -92,45 +226,166 @@ func LoadKeys(path, activeKid string) (*KeyStore, error) {
 			return nil, &ErrFailedToParsePublicKey{FileName: pubFileName, Err: err}
 		}
 
-		_, ok := pub.(*rsa.PublicKey)
-		if !ok {
-			return nil, &ErrPublicKeyNotRSA{FileName: pubFileName}
+		public, ok := priv.Public().(interface{ Equal(crypto.PublicKey) bool })
+		if !ok || !public.Equal(pub) {
+			return nil, &ErrPublicKeyMismatch{FileName: pubFileName}
 		}
 
-		jwkKey, err := jwk.Import(priv)
+		key, err := NewSigningKey(kid, priv, block.Headers[KeyAlgorithmHeader])
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert private key to JWK: %w", err)
+			return nil, &ErrUnsupportedKey{FileName: fileName, Err: err}
 		}
 
-		keyID := fmt.Sprintf("key-%s", kid)
-		if err := jwkKey.Set(jwk.KeyIDKey, keyID); err != nil {
-			return nil, fmt.Errorf("failed to set key ID: %w", err)
+		keys = append(keys, key)
+		if record != nil {
+			states[fmt.Sprintf("key-%s", kid)] = record.State
 		}
+	}
 
-		if err := jwkKey.Set(jwk.AlgorithmKey, jwa.RS256()); err != nil {
-			return nil, fmt.Errorf("failed to set algorithm: %w", err)
-		}
+	return NewKeyStore(activeKid, keys, states, source)
+}
 
-		if err := keySet.AddKey(jwkKey); err != nil {
-			return nil, fmt.Errorf("failed to add key to set: %w", err)
+// Reload reads the keys from their source again and swaps them in, for example after a rotation.
+// The new keys are validated first: when a key is malformed or there is no active key,
+// Reload returns the error and the current keys stay in place.
+func (ks *KeyStore) Reload() error {
+	ks.reloadMu.Lock()
+	defer ks.reloadMu.Unlock()
+
+	if ks.source == nil {
+		return errors.New("key store has no source to reload from")
+	}
+	fresh, err := ks.source.Load()
+	if err != nil {
+		return err
+	}
+	if _, err := fresh.GetActiveKey(); err != nil {
+		return fmt.Errorf("active key %s: %w", fresh.ActiveKid, err)
+	}
+
+	keySet := ks.current().keySet
+	for i := 0; i < keySet.Len(); i++ {
+		key, ok := keySet.Key(i)
+		if !ok {
+			continue
+		}
+		// Tokens signed with a dropped key fail verification from now on
+		if keyID, _ := key.KeyID(); !hasKeyID(fresh.KeySet, keyID) {
+			slog.Warn("Signing key dropped on reload", "key_id", keyID)
 		}
 	}
 
-	ks := &KeyStore{
-		ActiveKid: activeKid,
-		KeySet:    keySet,
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.ActiveKid = fresh.ActiveKid
+	ks.KeySet = fresh.KeySet
+	ks.signers = fresh.signers
+	ks.states = fresh.states
+	return nil
+}
+
+func hasKeyID(keySet jwk.Set, keyID string) bool {
+	_, ok := keySet.LookupKeyID(keyID)
+	return ok
+}
+
+// ActiveKeyID returns the key ID of the active key as configured or set by rotation
+func (ks *KeyStore) ActiveKeyID() string {
+	return ks.current().activeKid
+}
+
+// keySnapshot is the state of a KeyStore at one point in time. Everything a single operation
+// needs is read from one snapshot, so a concurrent Reload never mixes two key sets.
+type keySnapshot struct {
+	activeKid string
+	keySet    jwk.Set
+	signers   map[string]crypto.Signer
+	states    map[string]KeyState
+}
+
+// current returns the keys under the read lock, so a concurrent Reload is seen either entirely or not at all
+func (ks *KeyStore) current() keySnapshot {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return keySnapshot{activeKid: ks.ActiveKid, keySet: ks.KeySet, signers: ks.signers, states: ks.states}
+}
+
+// canSign reports whether a key may sign tokens: keys that are only pre-published or retired may not
+func canSign(states map[string]KeyState, keyID string) bool {
+	state := states[keyID]
+	return state != KeyStateNext && state != KeyStateRetired
+}
+
+// parsePrivateKey parses a PKCS #1 RSA, SEC 1 EC or PKCS #8 private key
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("key cannot sign")
+	}
+	return signer, nil
+}
+
+// keyAlgorithm infers the JWS algorithm of a public key: RS256 (or PS256 when requested) for RSA,
+// ES256 and ES384 for P-256 and P-384 EC keys and EdDSA for Ed25519. A requested algorithm must fit the key.
+func keyAlgorithm(key crypto.PublicKey, requested string) (jwa.SignatureAlgorithm, error) {
+	var alg jwa.SignatureAlgorithm
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if requested == jwa.PS256().String() {
+			return jwa.PS256(), nil
+		}
+		alg = jwa.RS256()
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			alg = jwa.ES256()
+		case elliptic.P384():
+			alg = jwa.ES384()
+		default:
+			return alg, fmt.Errorf("unsupported curve %s", k.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		alg = jwa.EdDSA()
+	default:
+		return alg, fmt.Errorf("unsupported key type %T", key)
 	}
 
-	return ks, nil
+	if requested != "" && requested != alg.String() {
+		return alg, fmt.Errorf("algorithm %s does not fit the key", requested)
+	}
+	return alg, nil
 }
 
 func (ks *KeyStore) GetActiveKey() (jwk.Key, error) {
-	activeKid := ks.ActiveKid
+	return ks.current().activeKey()
+}
+
+// KeyForAlgorithm returns the active key if it signs with alg, or else the first key that does
+// and is not pre-published or retired. An empty alg selects the active key.
+func (ks *KeyStore) KeyForAlgorithm(alg string) (jwk.Key, error) {
+	return ks.current().keyForAlgorithm(alg)
+}
+
+func (s keySnapshot) activeKey() (jwk.Key, error) {
+	activeKid := s.activeKid
 	if !strings.HasPrefix(activeKid, "key-") {
 		activeKid = fmt.Sprintf("key-%s", activeKid)
 	}
 
-	key, ok := ks.KeySet.LookupKeyID(activeKid)
+	key, ok := s.keySet.LookupKeyID(activeKid)
 	if !ok {
 		return nil, ErrUnknownKey
 	}
@@ -138,8 +393,65 @@ func (ks *KeyStore) GetActiveKey() (jwk.Key, error) {
 	return key, nil
 }
 
+func (s keySnapshot) keyForAlgorithm(alg string) (jwk.Key, error) {
+	active, err := s.activeKey()
+	if err != nil {
+		return nil, err
+	}
+	if alg == "" || keyAlgorithmName(active) == alg {
+		return active, nil
+	}
+
+	for i := 0; i < s.keySet.Len(); i++ {
+		key, ok := s.keySet.Key(i)
+		if !ok || keyAlgorithmName(key) != alg {
+			continue
+		}
+		if keyID, _ := key.KeyID(); canSign(s.states, keyID) {
+			return key, nil
+		}
+	}
+
+	return nil, ErrNoKeyForAlgorithm
+}
+
+// Algorithms returns the algorithms tokens can be signed with, the active key's first
+func (ks *KeyStore) Algorithms() []string {
+	snapshot := ks.current()
+
+	var algs []string
+	if active, err := snapshot.activeKey(); err == nil {
+		algs = append(algs, keyAlgorithmName(active))
+	}
+
+	for i := 0; i < snapshot.keySet.Len(); i++ {
+		key, ok := snapshot.keySet.Key(i)
+		if !ok {
+			continue
+		}
+		if keyID, _ := key.KeyID(); !canSign(snapshot.states, keyID) {
+			continue
+		}
+		if alg := keyAlgorithmName(key); !slices.Contains(algs, alg) {
+			algs = append(algs, alg)
+		}
+	}
+
+	return algs
+}
+
+// keyAlgorithmName returns the algorithm set on the key; keys without one are RS256 keys
+func keyAlgorithmName(key jwk.Key) string {
+	if alg, ok := key.Algorithm(); ok {
+		return alg.String()
+	}
+	return jwa.RS256().String()
+}
+
+// JWKS returns the public keys, including pre-published and retired keys so that
+// relying parties can verify tokens across a rotation
 func (ks *KeyStore) JWKS() jwk.Set {
-	publicSet, err := jwk.PublicSetOf(ks.KeySet)
+	publicSet, err := jwk.PublicSetOf(ks.current().keySet)
 	if err != nil {
 		return jwk.NewSet()
 	}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v3/jws"
+	"github.com/lestrrat-go/jwx/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeyPair writes a key pair in the layout LoadKeys reads
+func writeKeyPair(t *testing.T, dir, kid string, key crypto.Signer, headers map[string]string) {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	priv := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Headers: headers, Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "private-"+kid+".pem"), priv, 0600))
+
+	der, err = x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	pub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "public-"+kid+".pem"), pub, 0644))
+}
+
+func TestLoadKeysAlgorithms(t *testing.T) {
+	dir := t.TempDir()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	writeKeyPair(t, dir, "ec", ecKey, nil)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writeKeyPair(t, dir, "ed", edKey, nil)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	writeKeyPair(t, dir, "pss", rsaKey, map[string]string{KeyAlgorithmHeader: "PS256"})
+
+	ks, err := LoadKeys(dir, "ec")
+	require.NoError(t, err)
+	assert.Equal(t, "ES256", ks.Algorithms()[0], "the active key's algorithm comes first")
+	assert.ElementsMatch(t, []string{"ES256", "EdDSA", "PS256"}, ks.Algorithms())
+
+	for _, alg := range []string{"", "ES256", "EdDSA", "PS256"} {
+		token, err := jwt.NewBuilder().Subject("user-1").Build()
+		require.NoError(t, err)
+
+		signed, err := ks.SignTokenWithAlgorithm(token, alg)
+		require.NoError(t, err)
+
+		msg, err := jws.Parse([]byte(signed))
+		require.NoError(t, err)
+		headerAlg, _ := msg.Signatures()[0].ProtectedHeaders().Algorithm()
+		if alg == "" {
+			alg = "ES256"
+		}
+		assert.Equal(t, alg, headerAlg.String())
+
+		_, err = ks.VerifySignature(signed)
+		assert.NoError(t, err)
+	}
+
+	token, err := jwt.NewBuilder().Subject("user-1").Build()
+	require.NoError(t, err)
+	_, err = ks.SignTokenWithAlgorithm(token, "ES384")
+	assert.ErrorIs(t, err, ErrNoKeyForAlgorithm)
+
+	// ID tokens for a client whose algorithm is no longer available fall back to the active key
+	s := &Service{KeyStore: ks, issuer: "https://auth.example.com"}
+	idToken, err := s.GenerateIDToken("user-1", "client-1", "", time.Now(), nil, "ES384")
+	require.NoError(t, err)
+	msg, err := jws.Parse([]byte(idToken))
+	require.NoError(t, err)
+	headerAlg, _ := msg.Signatures()[0].ProtectedHeaders().Algorithm()
+	assert.Equal(t, "ES256", headerAlg.String())
+}
+
+func TestLoadKeysRejectsMismatchedAlgorithm(t *testing.T) {
+	dir := t.TempDir()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	writeKeyPair(t, dir, "ec", ecKey, map[string]string{KeyAlgorithmHeader: "PS256"})
+
+	_, err = LoadKeys(dir, "ec")
+	var unsupported *ErrUnsupportedKey
+	assert.ErrorAs(t, err, &unsupported)
+}
@@ -2,12 +2,14 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/Anvoria/authly/internal/domain/subject"
 	"github.com/Anvoria/authly/internal/utils"
 )
 
@@ -49,10 +51,11 @@ func AuthMiddleware(keyStore *KeyStore, svc AuthService, issuer string, serviceR
 		}
 
 		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		if len(parts) != 2 || (parts[0] != "Bearer" && parts[0] != DPoPScheme) {
 			return utils.ErrorResponse(c, ErrInvalidAuthorizationHeader.Error(), fiber.StatusUnauthorized)
 		}
 
+		scheme := parts[0]
 		token := parts[1]
 		if token == "" {
 			return utils.ErrorResponse(c, ErrMissingToken.Error(), fiber.StatusUnauthorized)
@@ -108,6 +111,28 @@ func AuthMiddleware(keyStore *KeyStore, svc AuthService, issuer string, serviceR
 			}
 		}
 
+		// A DPoP-bound token is only usable together with a proof from its key, never as a bearer token
+		jkt := claims.GetConfirmationJKT()
+		if scheme == DPoPScheme || jkt != "" {
+			if scheme != DPoPScheme || jkt == "" {
+				return utils.ErrorResponse(c, ErrInvalidToken.Error(), fiber.StatusUnauthorized)
+			}
+
+			proof, err := svc.VerifyDPoP(c.Get(DPoPHeader), c.Method(), requestURL(c, issuer), token)
+			if err != nil {
+				if errors.Is(err, ErrInvalidDPoPProof) || errors.Is(err, ErrDPoPProofReplayed) {
+					c.Set(fiber.HeaderWWWAuthenticate, `DPoP error="invalid_dpop_proof"`)
+					return utils.ErrorResponse(c, err.Error(), fiber.StatusUnauthorized)
+				}
+				slog.Error("failed to verify dpop proof", "error", err)
+				return utils.ErrorResponse(c, ErrTokenValidationError.Error(), fiber.StatusInternalServerError)
+			}
+			if proof.JKT != jkt {
+				c.Set(fiber.HeaderWWWAuthenticate, `DPoP error="invalid_token"`)
+				return utils.ErrorResponse(c, ErrDPoPKeyMismatch.Error(), fiber.StatusUnauthorized)
+			}
+		}
+
 		revoked, err := svc.IsTokenRevoked(claims)
 		if err != nil {
 			return utils.ErrorResponse(c, ErrTokenValidationError.Error(), fiber.StatusInternalServerError)
@@ -116,10 +141,20 @@ func AuthMiddleware(keyStore *KeyStore, svc AuthService, issuer string, serviceR
 			return utils.ErrorResponse(c, ErrTokenRevoked.Error(), fiber.StatusUnauthorized)
 		}
 
+		// Pairwise subjects only identify the user to the relying party; handlers work with the user ID
+		userID, err := svc.ResolveSubject(claims.Subject())
+		if err != nil {
+			if errors.Is(err, subject.ErrUnknownSubject) {
+				return utils.ErrorResponse(c, ErrInvalidToken.Error(), fiber.StatusUnauthorized)
+			}
+			slog.Error("failed to resolve token subject", "error", err)
+			return utils.ErrorResponse(c, ErrTokenValidationError.Error(), fiber.StatusInternalServerError)
+		}
+
 		scopes := claims.GetScopes()
 
 		identity := &Identity{
-			UserID:      claims.Subject(),
+			UserID:      userID,
 			SessionID:   claims.GetSid(),
 			PermissionV: claims.GetPermissionV(),
 			Scopes:      scopes,
@@ -133,6 +168,15 @@ func AuthMiddleware(keyStore *KeyStore, svc AuthService, issuer string, serviceR
 	}
 }
 
+// requestURL rebuilds the URL the client called, without query, for matching a DPoP proof's htu claim.
+// The configured issuer is preferred over the Host header since the server usually runs behind a proxy.
+func requestURL(c *fiber.Ctx, issuer string) string {
+	if issuer != "" {
+		return strings.TrimSuffix(issuer, "/") + c.Path()
+	}
+	return c.BaseURL() + c.Path()
+}
+
 // RequirePermission returns a middleware that requires the specified permission scope to have a non-zero bitmask.
 // RequirePermission returns a middleware that enforces the presence of a non-zero permission bitmask for the given scope.
 // It sends HTTP 403 Forbidden if the permissions map is missing or not a map[string]uint64, if the scope is absent, or if its bitmask is zero.
@@ -9,6 +9,9 @@ import (
 	"github.com/lestrrat-go/jwx/v3/jwt"
 )
 
+// AMRPassword is the "amr" value for users who authenticated with their password (RFC 8176)
+const AMRPassword = "pwd"
+
 // AccessTokenClaims are the claims for the access token
 type AccessTokenClaims struct {
 	Sid   string
@@ -26,6 +29,25 @@ func (c *AccessTokenClaims) Audience() []string {
 	return aud
 }
 
+// ClientID returns the client the token was issued to. Tokens without a client_id claim were
+// issued to their first audience.
+func (c *AccessTokenClaims) ClientID() string {
+	var clientID string
+	if c.Token.Get("client_id", &clientID) == nil && clientID != "" {
+		return clientID
+	}
+	if aud := c.Audience(); len(aud) > 0 {
+		return aud[0]
+	}
+	return ""
+}
+
+// JwtID returns the unique identifier of the token
+func (c *AccessTokenClaims) JwtID() string {
+	jti, _ := c.Token.JwtID()
+	return jti
+}
+
 func (c *AccessTokenClaims) Issuer() string {
 	iss, _ := c.Token.Issuer()
 	return iss
@@ -110,6 +132,15 @@ func (c *AccessTokenClaims) GetRequestedScopes() []string {
 	return []string{}
 }
 
+// GetRequestedClaims returns the JSON claims request carried for the userinfo endpoint, if any
+func (c *AccessTokenClaims) GetRequestedClaims() string {
+	var claims string
+	if c.Token.Get("requested_claims", &claims) == nil {
+		return claims
+	}
+	return ""
+}
+
 // GetPermissionV extracts permission version from the token claims
 func (c *AccessTokenClaims) GetPermissionV() int {
 	var pver int
@@ -124,6 +155,76 @@ func (c *AccessTokenClaims) GetPermissionV() int {
 	return pver
 }
 
+// GetActor extracts the RFC 8693 "act" claim identifying the party acting on behalf of the subject
+// It returns nil for tokens that were not obtained through token exchange
+func (c *AccessTokenClaims) GetActor() map[string]any {
+	var act any
+	if c.Token.Get("act", &act) == nil {
+		if a, ok := act.(map[string]any); ok {
+			return a
+		}
+	}
+	return nil
+}
+
+// AuthTime returns when the user authenticated, or the zero time if the token does not say
+func (c *AccessTokenClaims) AuthTime() time.Time {
+	var authTime any
+	if c.Token.Get("auth_time", &authTime) == nil {
+		switch t := authTime.(type) {
+		case float64:
+			return time.Unix(int64(t), 0)
+		case int64:
+			return time.Unix(t, 0)
+		}
+	}
+	return time.Time{}
+}
+
+// ACR returns the authentication context class reference, if known
+func (c *AccessTokenClaims) ACR() string {
+	var acr string
+	if c.Token.Get("acr", &acr) == nil {
+		return acr
+	}
+	return ""
+}
+
+// AMR returns the methods the user authenticated with, if known
+func (c *AccessTokenClaims) AMR() []string {
+	var amr any
+	if c.Token.Get("amr", &amr) != nil {
+		return nil
+	}
+	switch m := amr.(type) {
+	case []string:
+		return m
+	case []any:
+		methods := make([]string, 0, len(m))
+		for _, v := range m {
+			if method, ok := v.(string); ok {
+				methods = append(methods, method)
+			}
+		}
+		return methods
+	}
+	return nil
+}
+
+// GetConfirmationJKT returns the DPoP key thumbprint from the "cnf" claim (RFC 9449 Section 6.1)
+// It returns an empty string for bearer tokens
+func (c *AccessTokenClaims) GetConfirmationJKT() string {
+	var cnf any
+	if c.Token.Get("cnf", &cnf) == nil {
+		if m, ok := cnf.(map[string]any); ok {
+			if jkt, ok := m["jkt"].(string); ok {
+				return jkt
+			}
+		}
+	}
+	return ""
+}
+
 // Validate validates standard JWT claims
 func (c *AccessTokenClaims) Validate(issuer string, expectedAudience []string) error {
 	exp := c.Expiration()
@@ -164,4 +265,6 @@ type Identity struct {
 	SessionID   string
 	PermissionV int
 	Scopes      map[string]uint64
+	// AuthTime is when the user authenticated; only known for browser sessions
+	AuthTime time.Time
 }
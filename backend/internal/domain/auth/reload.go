@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReloadBroadcaster announces key changes to the other instances and delivers their announcements.
+// Messages carry the origin of the announcement so an instance can skip its own.
+type ReloadBroadcaster interface {
+	Publish(ctx context.Context, origin string) error
+	Subscribe(ctx context.Context) (<-chan string, error)
+}
+
+// KeyReloader keeps a KeyStore in sync with its key source without restarting the server.
+// It reloads when the keys change, on SIGHUP, and when another instance announces a change.
+// Reloads that fail validation are logged and leave the current keys in place.
+type KeyReloader struct {
+	keyStore    *KeyStore
+	broadcaster ReloadBroadcaster
+
+	// origin identifies this instance in announcements
+	origin string
+
+	mu          sync.Mutex
+	fingerprint string
+}
+
+// NewKeyReloader creates a reloader for the key store. broadcaster may be nil for a single instance.
+func NewKeyReloader(keyStore *KeyStore, broadcaster ReloadBroadcaster) *KeyReloader {
+	r := &KeyReloader{
+		keyStore:    keyStore,
+		broadcaster: broadcaster,
+		origin:      uuid.NewString(),
+	}
+	r.fingerprint, _ = keyStore.fingerprint()
+	return r
+}
+
+// Run watches for reload triggers until ctx is done, checking the keys for changes every interval
+func (r *KeyReloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var announcements <-chan string
+	if r.broadcaster != nil {
+		var err error
+		if announcements, err = r.broadcaster.Subscribe(ctx); err != nil {
+			slog.Error("Failed to subscribe to key reload announcements", "error", err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.poll(ctx)
+		case <-hangup:
+			r.Reload(ctx, "SIGHUP", true)
+		case origin, ok := <-announcements:
+			if !ok {
+				announcements = nil
+				continue
+			}
+			if origin != r.origin {
+				r.Reload(ctx, "announced by another instance", false)
+			}
+		}
+	}
+}
+
+// Reload reloads the key store, and with announce tells the other instances to do the same
+func (r *KeyReloader) Reload(ctx context.Context, reason string, announce bool) {
+	r.mu.Lock()
+	// Record the keys being loaded so that polling does not reload them a second time
+	r.fingerprint, _ = r.keyStore.fingerprint()
+	r.mu.Unlock()
+
+	if err := r.keyStore.Reload(); err != nil {
+		slog.Error("Rejected key reload; keeping the current keys", "reason", reason, "error", err)
+		return
+	}
+	slog.Info("Signing keys reloaded", "reason", reason, "active_key", r.keyStore.ActiveKeyID())
+
+	if announce && r.broadcaster != nil {
+		if err := r.broadcaster.Publish(ctx, r.origin); err != nil {
+			slog.Error("Failed to announce key reload", "error", err)
+		}
+	}
+}
+
+// poll reloads when the keys changed since the last reload. Every instance watches the keys
+// itself, so these changes are not announced.
+func (r *KeyReloader) poll(ctx context.Context) {
+	fingerprint, err := r.keyStore.fingerprint()
+	if err != nil {
+		slog.Error("Failed to check keys for changes", "error", err)
+		return
+	}
+
+	r.mu.Lock()
+	changed := fingerprint != r.fingerprint
+	r.mu.Unlock()
+
+	if changed {
+		r.Reload(ctx, "keys changed", false)
+	}
+}
+
+// fingerprint returns the fingerprint of the key store's source
+func (ks *KeyStore) fingerprint() (string, error) {
+	if ks.source == nil {
+		return "", nil
+	}
+	return ks.source.Fingerprint()
+}
+
+// keysFingerprint hashes the names and contents of the files LoadKeys reads. Contents are hashed
+// rather than modification times because mounted secret volumes swap files through symlinks.
+func keysFingerprint(path string) (string, error) {
+	files, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for _, file := range files {
+		name := file.Name()
+		if name == RotationStateFile || (filepath.Ext(name) == ".pem" && (strings.HasPrefix(name, "private") || strings.HasPrefix(name, "public"))) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(path, name))
+		if err != nil {
+			return "", err
+		}
+		hash.Write([]byte(name))
+		hash.Write([]byte{0})
+		hash.Write(data)
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
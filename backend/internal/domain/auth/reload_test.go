@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingBroadcaster struct {
+	published []string
+}
+
+func (b *recordingBroadcaster) Publish(_ context.Context, origin string) error {
+	b.published = append(b.published, origin)
+	return nil
+}
+
+func (b *recordingBroadcaster) Subscribe(context.Context) (<-chan string, error) {
+	return make(chan string), nil
+}
+
+func TestKeyReloader(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, GenerateKeyPair(dir, "main", "ES256", 0))
+
+	ks, err := LoadKeys(dir, "main")
+	require.NoError(t, err)
+	broadcaster := &recordingBroadcaster{}
+	reloader := NewKeyReloader(ks, broadcaster)
+
+	t.Run("bad key files are rejected and the current keys kept", func(t *testing.T) {
+		bad := filepath.Join(dir, "private-bad.pem")
+		require.NoError(t, os.WriteFile(bad, []byte("not a key"), 0600))
+		defer os.Remove(bad)
+
+		reloader.poll(ctx)
+		assert.Equal(t, 1, ks.JWKS().Len())
+		_, err := ks.GetActiveKey()
+		assert.NoError(t, err)
+	})
+
+	t.Run("changed key files are picked up", func(t *testing.T) {
+		require.NoError(t, GenerateKeyPair(dir, "second", "EdDSA", 0))
+
+		reloader.poll(ctx)
+		assert.Equal(t, 2, ks.JWKS().Len())
+		assert.Equal(t, []string{"ES256", "EdDSA"}, ks.Algorithms())
+		assert.Empty(t, broadcaster.published, "every instance watches its own files")
+	})
+
+	t.Run("explicit reloads are announced", func(t *testing.T) {
+		reloader.Reload(ctx, "SIGHUP", true)
+		assert.Equal(t, []string{reloader.origin}, broadcaster.published)
+	})
+}
@@ -0,0 +1,442 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyState is the lifecycle state of a signing key under rotation
+type KeyState string
+
+const (
+	// KeyStateNext keys are published in the JWKS before they sign, so relying parties have them
+	// cached when the first token signed with them arrives
+	KeyStateNext KeyState = "next"
+
+	// KeyStateActive is the single key that signs tokens
+	KeyStateActive KeyState = "active"
+
+	// KeyStateRetired keys no longer sign but stay published until the tokens they signed have expired
+	KeyStateRetired KeyState = "retired"
+
+	// KeyStateRemoved keys have had their files deleted; the record is kept as history
+	KeyStateRemoved KeyState = "removed"
+)
+
+const (
+	// RotationStateFile holds the rotation state in the keys directory
+	RotationStateFile = "rotation.json"
+
+	// MinRetirement is the lifetime of ID tokens, the longest-lived tokens the key store signs.
+	// A retired key must stay published at least this long.
+	MinRetirement = idTokenLifetime
+
+	// defaultRotationBits is the size of rotated RSA keys
+	defaultRotationBits = 2048
+)
+
+var (
+	// ErrNextKeyNotPublished is returned when the next key has not been published long enough to activate
+	ErrNextKeyNotPublished = errors.New("next key has not been published for the pre-publication period")
+
+	// ErrCannotRetireActiveKey is returned when retiring the active key, which must be rotated out instead
+	ErrCannotRetireActiveKey = errors.New("the active key cannot be retired; rotate first")
+)
+
+// KeyRecord tracks one key through its lifecycle
+type KeyRecord struct {
+	KID         string     `json:"kid"`
+	Algorithm   string     `json:"alg"`
+	State       KeyState   `json:"state"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ActivatedAt *time.Time `json:"activated_at,omitempty"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+	RemovedAt   *time.Time `json:"removed_at,omitempty"`
+}
+
+// RotationState is the persisted rotation state of a keys directory.
+// Keys without a record are not managed by rotation and keep signing and being published.
+type RotationState struct {
+	Keys []*KeyRecord `json:"keys"`
+}
+
+// LoadRotationState reads the rotation state of the keys directory; a directory without one has an empty state
+func LoadRotationState(path string) (*RotationState, error) {
+	data, err := os.ReadFile(filepath.Join(path, RotationStateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &RotationState{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", RotationStateFile, err)
+	}
+
+	var state RotationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", RotationStateFile, err)
+	}
+	return &state, nil
+}
+
+// Save writes the state atomically, so a crash never leaves a half-written file behind
+func (s *RotationState) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(path, ".rotation-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", RotationStateFile, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to save %s: %w", RotationStateFile, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save %s: %w", RotationStateFile, err)
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(path, RotationStateFile))
+}
+
+// Find returns the record of the key, or nil if the key is not managed
+func (s *RotationState) Find(kid string) *KeyRecord {
+	kid = strings.TrimPrefix(kid, "key-")
+	for _, record := range s.Keys {
+		if record.KID == kid {
+			return record
+		}
+	}
+	return nil
+}
+
+// Active returns the record of the active key, or nil
+func (s *RotationState) Active() *KeyRecord {
+	return s.first(KeyStateActive)
+}
+
+// Next returns the record of the pre-published key, or nil
+func (s *RotationState) Next() *KeyRecord {
+	return s.first(KeyStateNext)
+}
+
+func (s *RotationState) first(state KeyState) *KeyRecord {
+	for _, record := range s.Keys {
+		if record.State == state {
+			return record
+		}
+	}
+	return nil
+}
+
+// RotationPolicy sets how long a key spends in each state
+type RotationPolicy struct {
+	// Interval is how long a key signs before the next key replaces it
+	Interval time.Duration
+
+	// PrePublication is how long the next key is published before it may sign.
+	// It should exceed how long relying parties cache the JWKS.
+	PrePublication time.Duration
+
+	// Retirement is how long a retired key stays published; at least MinRetirement
+	Retirement time.Duration
+
+	// Algorithm of new keys; empty keeps the algorithm of the active key
+	Algorithm string
+}
+
+// Validate checks that the policy cannot break tokens in flight
+func (p RotationPolicy) Validate() error {
+	if p.Interval <= 0 {
+		return errors.New("rotation interval must be positive")
+	}
+	if p.PrePublication < 0 {
+		return errors.New("pre-publication period cannot be negative")
+	}
+	if p.Retirement < MinRetirement {
+		return fmt.Errorf("retirement period must be at least %s", MinRetirement)
+	}
+	return nil
+}
+
+// Rotator moves the keys of a keys directory through their lifecycle: a next key is pre-published,
+// activated once the active key has signed for the rotation interval, and the replaced key is
+// retired and finally removed when its tokens have expired. The state is kept in RotationStateFile.
+type Rotator struct {
+	path      string
+	activeKid string
+	policy    RotationPolicy
+
+	// mu serializes rotations within the process; only one instance should rotate a keys directory
+	mu sync.Mutex
+}
+
+// NewRotator creates a rotator for the keys directory. activeKid is the configured active key,
+// which becomes the first managed active key when the directory has no rotation state yet.
+func NewRotator(path, activeKid string, policy RotationPolicy) *Rotator {
+	return &Rotator{
+		path:      path,
+		activeKid: strings.TrimPrefix(activeKid, "key-"),
+		policy:    policy,
+	}
+}
+
+// Run performs due rotation work every interval and has reloader reload the keys after they changed
+func (r *Rotator) Run(ctx context.Context, interval time.Duration, reloader *KeyReloader) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		changed, err := r.Step(time.Now().UTC())
+		if err != nil {
+			slog.Error("Signing key rotation failed", "error", err)
+		}
+		if changed {
+			reloader.Reload(ctx, "key rotation", true)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Step performs the rotation work that is due at now: it pre-publishes a next key when there is none,
+// activates it when the rotation interval has passed, and removes retired keys whose tokens have expired.
+// It reports whether the keys changed.
+func (r *Rotator) Step(now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, active, changed, err := r.load(now)
+	if err != nil {
+		return false, err
+	}
+
+	next := state.Next()
+	if next != nil && r.due(active, now) && r.published(next, now) {
+		promote(active, next, now)
+		changed = true
+	}
+
+	if state.Next() == nil {
+		if _, err := r.prepublish(state, now); err != nil {
+			return changed, r.save(state, changed, err)
+		}
+		changed = true
+	}
+
+	removed, err := r.removeExpired(state, now)
+	changed = changed || removed
+
+	return changed, r.save(state, changed, err)
+}
+
+// Rotate activates the next key now instead of at the end of the rotation interval. Without force the
+// next key must have been published for the pre-publication period; if there is no next key yet, one is
+// published and ErrNextKeyNotPublished returned. With force a missing next key is created and activated
+// at once, which relying parties with a cached JWKS may reject until they refresh it.
+// It returns the record of the key that became active.
+func (r *Rotator) Rotate(now time.Time, force bool) (*KeyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, active, changed, err := r.load(now)
+	if err != nil {
+		return nil, err
+	}
+
+	next := state.Next()
+	if next == nil {
+		if next, err = r.prepublish(state, now); err != nil {
+			return nil, r.save(state, changed, err)
+		}
+		changed = true
+	}
+	if !force && !r.published(next, now) {
+		return nil, r.save(state, changed, ErrNextKeyNotPublished)
+	}
+
+	promote(active, next, now)
+	if _, err := r.prepublish(state, now); err != nil {
+		return next, r.save(state, true, err)
+	}
+
+	return next, r.save(state, true, nil)
+}
+
+// Retire stops a key from signing; it stays published for the retirement period. With remove the
+// key files are deleted at once, for keys that are compromised. The active key has to be rotated out first.
+func (r *Rotator) Retire(kid string, now time.Time, remove bool) (*KeyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, _, changed, err := r.load(now)
+	if err != nil {
+		return nil, err
+	}
+
+	kid = strings.TrimPrefix(kid, "key-")
+	record := state.Find(kid)
+	switch {
+	case record == nil:
+		// Adopt an unmanaged key so that its retirement is tracked like any other
+		alg, err := keyFileAlgorithm(r.path, kid)
+		if err != nil {
+			return nil, r.save(state, changed, err)
+		}
+		record = &KeyRecord{KID: kid, Algorithm: alg, State: KeyStateRetired, CreatedAt: now, RetiredAt: &now}
+		state.Keys = append(state.Keys, record)
+		changed = true
+	case record.State == KeyStateActive:
+		return nil, r.save(state, changed, ErrCannotRetireActiveKey)
+	case record.State == KeyStateRemoved:
+		return record, r.save(state, changed, nil)
+	case record.State == KeyStateNext:
+		record.State = KeyStateRetired
+		record.RetiredAt = &now
+		changed = true
+	}
+
+	if remove {
+		err = r.remove(record, now)
+		changed = true
+	}
+
+	return record, r.save(state, changed, err)
+}
+
+// load reads the rotation state and makes sure it has an active key, adopting the configured one
+func (r *Rotator) load(now time.Time) (*RotationState, *KeyRecord, bool, error) {
+	state, err := LoadRotationState(r.path)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	if active := state.Active(); active != nil {
+		return state, active, false, nil
+	}
+
+	alg, err := keyFileAlgorithm(r.path, r.activeKid)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to adopt active key %s: %w", r.activeKid, err)
+	}
+
+	active := state.Find(r.activeKid)
+	if active == nil {
+		active = &KeyRecord{KID: r.activeKid, Algorithm: alg, CreatedAt: now}
+		state.Keys = append(state.Keys, active)
+	}
+	active.State = KeyStateActive
+	active.ActivatedAt = &now
+
+	return state, active, true, nil
+}
+
+// prepublish generates a new key in the next state
+func (r *Rotator) prepublish(state *RotationState, now time.Time) (*KeyRecord, error) {
+	alg := r.policy.Algorithm
+	if alg == "" {
+		alg = state.Active().Algorithm
+	}
+
+	// Key IDs are the creation time, with a counter for keys created within the same second
+	base := now.Format("20060102-150405")
+	kid := base
+	for i := 2; state.Find(kid) != nil; i++ {
+		kid = fmt.Sprintf("%s-%d", base, i)
+	}
+	if err := GenerateKeyPair(r.path, kid, alg, defaultRotationBits); err != nil {
+		return nil, fmt.Errorf("failed to generate next key: %w", err)
+	}
+
+	record := &KeyRecord{KID: kid, Algorithm: alg, State: KeyStateNext, CreatedAt: now}
+	state.Keys = append(state.Keys, record)
+
+	slog.Info("Pre-published next signing key", "kid", kid, "alg", alg)
+	return record, nil
+}
+
+// due reports whether the active key has signed for the rotation interval
+func (r *Rotator) due(active *KeyRecord, now time.Time) bool {
+	return active.ActivatedAt == nil || !now.Before(active.ActivatedAt.Add(r.policy.Interval))
+}
+
+// published reports whether the next key has been in the JWKS for the pre-publication period
+func (r *Rotator) published(next *KeyRecord, now time.Time) bool {
+	return !now.Before(next.CreatedAt.Add(r.policy.PrePublication))
+}
+
+// removeExpired removes the retired keys whose retirement period has passed
+func (r *Rotator) removeExpired(state *RotationState, now time.Time) (bool, error) {
+	removed := false
+	for _, record := range state.Keys {
+		if record.State != KeyStateRetired || record.RetiredAt == nil || now.Before(record.RetiredAt.Add(r.policy.Retirement)) {
+			continue
+		}
+		if err := r.remove(record, now); err != nil {
+			return removed, err
+		}
+		removed = true
+	}
+	return removed, nil
+}
+
+// remove deletes the key files of a record
+func (r *Rotator) remove(record *KeyRecord, now time.Time) error {
+	if err := RemoveKeyPair(r.path, record.KID); err != nil {
+		return err
+	}
+
+	record.State = KeyStateRemoved
+	record.RemovedAt = &now
+
+	slog.Info("Removed retired signing key", "kid", record.KID)
+	return nil
+}
+
+// save persists the state if it changed and passes err through, so a failed step keeps the work done before it
+func (r *Rotator) save(state *RotationState, changed bool, err error) error {
+	if changed {
+		if saveErr := state.Save(r.path); saveErr != nil {
+			return errors.Join(err, saveErr)
+		}
+	}
+	return err
+}
+
+// promote retires the active key and activates the next one
+func promote(active, next *KeyRecord, now time.Time) {
+	active.State = KeyStateRetired
+	active.RetiredAt = &now
+
+	next.State = KeyStateActive
+	next.ActivatedAt = &now
+
+	slog.Info("Rotated signing key", "retired", active.KID, "active", next.KID)
+}
+
+// keyFileAlgorithm returns the signing algorithm of a key in the keys directory
+func keyFileAlgorithm(path, kid string) (string, error) {
+	ks, err := LoadKeys(path, kid)
+	if err != nil {
+		return "", err
+	}
+	key, ok := ks.KeySet.LookupKeyID("key-" + kid)
+	if !ok {
+		return "", ErrUnknownKey
+	}
+	return keyAlgorithmName(key), nil
+}
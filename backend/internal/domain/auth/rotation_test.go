@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatorLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, GenerateKeyPair(dir, "main", "ES256", 0))
+
+	policy := RotationPolicy{Interval: 30 * 24 * time.Hour, PrePublication: 24 * time.Hour, Retirement: 24 * time.Hour}
+	rotator := NewRotator(dir, "main", policy)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// The configured key is adopted as active and a next key is pre-published
+	changed, err := rotator.Step(start)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	state, err := LoadRotationState(dir)
+	require.NoError(t, err)
+	next := state.Next()
+	require.NotNil(t, next)
+	assert.Equal(t, "main", state.Active().KID)
+	assert.Equal(t, "ES256", next.Algorithm)
+
+	ks, err := LoadKeys(dir, "main")
+	require.NoError(t, err)
+	assert.Equal(t, 2, ks.JWKS().Len(), "the next key is published")
+	key, err := ks.KeyForAlgorithm("ES256")
+	require.NoError(t, err)
+	kid, _ := key.KeyID()
+	assert.Equal(t, "key-main", kid, "the next key does not sign")
+
+	// Nothing is due before the interval has passed
+	changed, err = rotator.Step(start.Add(29 * 24 * time.Hour))
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// The next key takes over and the old one is retired but still published
+	rotated := start.Add(30 * 24 * time.Hour)
+	changed, err = rotator.Step(rotated)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	require.NoError(t, ks.Reload())
+	assert.Equal(t, next.KID, ks.ActiveKeyID())
+	assert.Equal(t, 3, ks.JWKS().Len(), "active, retired and a new next key are published")
+
+	token, err := jwt.NewBuilder().Subject("user-1").Build()
+	require.NoError(t, err)
+	signed, err := ks.SignToken(token)
+	require.NoError(t, err)
+	_, err = ks.VerifySignature(signed)
+	assert.NoError(t, err)
+
+	// The retired key is removed once its tokens have expired
+	changed, err = rotator.Step(rotated.Add(24 * time.Hour))
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	require.NoError(t, ks.Reload())
+	assert.Equal(t, 2, ks.JWKS().Len())
+	state, err = LoadRotationState(dir)
+	require.NoError(t, err)
+	assert.Equal(t, KeyStateRemoved, state.Find("main").State)
+}
+
+func TestRotatorManualRotation(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, GenerateKeyPair(dir, "main", "EdDSA", 0))
+
+	policy := RotationPolicy{Interval: 30 * 24 * time.Hour, PrePublication: 24 * time.Hour, Retirement: 24 * time.Hour}
+	rotator := NewRotator(dir, "main", policy)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := rotator.Rotate(now, false)
+	assert.ErrorIs(t, err, ErrNextKeyNotPublished, "a fresh next key must be pre-published first")
+
+	_, err = rotator.Retire("main", now, false)
+	assert.ErrorIs(t, err, ErrCannotRetireActiveKey)
+
+	active, err := rotator.Rotate(now, true)
+	require.NoError(t, err)
+
+	state, err := LoadRotationState(dir)
+	require.NoError(t, err)
+	assert.Equal(t, active.KID, state.Active().KID)
+	assert.Equal(t, KeyStateRetired, state.Find("main").State)
+	require.NotNil(t, state.Next())
+
+	record, err := rotator.Retire("main", now, true)
+	require.NoError(t, err)
+	assert.Equal(t, KeyStateRemoved, record.State)
+
+	ks, err := LoadKeys(dir, "main")
+	require.NoError(t, err)
+	assert.Equal(t, 2, ks.JWKS().Len())
+}
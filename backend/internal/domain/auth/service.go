@@ -12,7 +12,9 @@ import (
 	"github.com/Anvoria/authly/internal/domain/permission"
 	"github.com/Anvoria/authly/internal/domain/role"
 	"github.com/Anvoria/authly/internal/domain/session"
+	"github.com/Anvoria/authly/internal/domain/subject"
 	"github.com/Anvoria/authly/internal/domain/user"
+	"github.com/google/uuid"
 	"github.com/lestrrat-go/jwx/v3/jwt"
 	"gorm.io/gorm"
 )
@@ -29,6 +31,8 @@ type AuthService interface {
 	Login(username, password, userAgent, ip string) (*LoginResponse, error)
 	Register(req user.RegisterRequest) (*user.UserResponse, error)
 	IsTokenRevoked(claims *AccessTokenClaims) (bool, error)
+	VerifyDPoP(proof, method, htu, accessToken string) (*DPoPProof, error)
+	ResolveSubject(sub string) (string, error)
 }
 
 // Service handles authentication operations
@@ -39,17 +43,20 @@ type Service struct {
 	PermissionService permission.ServiceInterface
 	RoleService       role.Service
 	KeyStore          *KeyStore
+	Subjects          subject.Service
 	issuer            string
 	revocationCache   *cache.TokenRevocationCache
+	replayCache       *cache.ReplayCache
 }
 
 // NewService constructs and returns a *Service configured with the provided dependencies.
 //
 // The returned Service is initialized with the users repository, session service,
 // NewService constructs a new Service wired with the provided database handle, user repository,
-// session manager, permission service, role service, key store, issuer identifier, and optional
-// token revocation cache.
-func NewService(db *gorm.DB, users user.Repository, sessions session.Service, permService permission.ServiceInterface, roleService role.Service, keyStore *KeyStore, issuer string, revocationCache *cache.TokenRevocationCache) *Service {
+// session manager, permission service, role service, key store, issuer identifier, optional
+// token revocation cache, the replay cache used to reject reused DPoP proofs, and the subject
+// service that maps pairwise subject identifiers.
+func NewService(db *gorm.DB, users user.Repository, sessions session.Service, permService permission.ServiceInterface, roleService role.Service, keyStore *KeyStore, subjects subject.Service, issuer string, revocationCache *cache.TokenRevocationCache, replayCache *cache.ReplayCache) *Service {
 	return &Service{
 		db:                db,
 		Users:             users,
@@ -57,27 +64,97 @@ func NewService(db *gorm.DB, users user.Repository, sessions session.Service, pe
 		PermissionService: permService,
 		RoleService:       roleService,
 		KeyStore:          keyStore,
+		Subjects:          subjects,
 		issuer:            issuer,
 		revocationCache:   revocationCache,
+		replayCache:       replayCache,
 	}
 }
 
-// GenerateAccessToken generates an OIDC-compliant access token
+// Issuer returns the issuer identifier placed in the "iss" claim of every token this service signs
+func (s *Service) Issuer() string {
+	return s.issuer
+}
+
+// ResolveSubject returns the user ID behind the "sub" claim of a token, which is a pairwise
+// subject identifier for pairwise services
+func (s *Service) ResolveSubject(sub string) (string, error) {
+	return s.Subjects.Resolve(sub)
+}
+
+// AccessTokenOption adds claims to an access token before it is signed
+type AccessTokenOption func(token jwt.Token) error
+
+// WithActor sets the RFC 8693 "act" claim, identifying the party acting on behalf of the subject
+func WithActor(act map[string]any) AccessTokenOption {
+	return func(token jwt.Token) error {
+		return token.Set("act", act)
+	}
+}
+
+// WithRequestedClaims carries the JSON claims request that the userinfo endpoint honours for the token
+func WithRequestedClaims(claims string) AccessTokenOption {
+	return func(token jwt.Token) error {
+		if claims == "" {
+			return nil
+		}
+		return token.Set("requested_claims", claims)
+	}
+}
+
+// WithAuthentication records when and how the user authenticated (RFC 9068 Section 2.2.1).
+// Values that are not known are left out of the token.
+func WithAuthentication(authTime time.Time, acr string, amr []string) AccessTokenOption {
+	return func(token jwt.Token) error {
+		if !authTime.IsZero() {
+			if err := token.Set("auth_time", authTime.Unix()); err != nil {
+				return err
+			}
+		}
+		if acr != "" {
+			if err := token.Set("acr", acr); err != nil {
+				return err
+			}
+		}
+		if len(amr) > 0 {
+			return token.Set("amr", amr)
+		}
+		return nil
+	}
+}
+
+// WithResources adds the resource servers (RFC 8707) to the audience, after the client the token is issued to
+func WithResources(resources []string) AccessTokenOption {
+	return func(token jwt.Token) error {
+		if len(resources) == 0 {
+			return nil
+		}
+		aud, _ := token.Audience()
+		return token.Set(jwt.AudienceKey, append(aud, resources...))
+	}
+}
+
+// GenerateAccessToken generates a JWT access token following RFC 9068: it is typed "at+jwt" and
+// carries client_id and a unique jti besides the standard claims
 // scopes: OIDC scope strings (e.g., ["openid", "profile]")
+// clientID: the client that requested the token, which is not the audience for exchanged tokens
 // audience: resource server identifier (e.g., "api:clientID" or clientID)
 // permissions: optional permissions map for internal authorization
-func (s *Service) GenerateAccessToken(sub, sid string, scopes []string, audience string, permissions map[string]uint64, pver int) (string, error) {
+// opts: optional extra claims (e.g., WithActor for delegated tokens)
+func (s *Service) GenerateAccessToken(sub, sid string, scopes []string, clientID, audience string, permissions map[string]uint64, pver int, opts ...AccessTokenOption) (string, error) {
 	now := time.Now()
 	exp := now.Add(15 * time.Minute)
 
 	accessTokenScopes := filterAccessTokenScopes(scopes)
 
 	token, err := jwt.NewBuilder().
+		JwtID(uuid.NewString()).
 		Subject(sub).
 		Audience([]string{audience}).
 		Issuer(s.issuer).
 		IssuedAt(now).
 		Expiration(exp).
+		Claim("client_id", clientID).
 		Claim("sid", sid).
 		Claim("scope", strings.Join(accessTokenScopes, " ")). // Scopes for access token (filtered)
 		Claim("requested_scopes", strings.Join(scopes, " ")). // All requested scopes (for userinfo)
@@ -93,6 +170,12 @@ func (s *Service) GenerateAccessToken(sub, sid string, scopes []string, audience
 		}
 	}
 
+	for _, opt := range opts {
+		if err := opt(token); err != nil {
+			return "", fmt.Errorf("failed to apply access token option: %w", err)
+		}
+	}
+
 	claims := &AccessTokenClaims{
 		Sid:   sid,
 		Token: token,
@@ -101,10 +184,14 @@ func (s *Service) GenerateAccessToken(sub, sid string, scopes []string, audience
 	return s.KeyStore.Sign(claims)
 }
 
+// idTokenLifetime is how long ID tokens are valid
+const idTokenLifetime = time.Hour
+
 // GenerateIDToken generates an OIDC-compliant ID token
-func (s *Service) GenerateIDToken(sub, audience, nonce string, authTime time.Time, claims map[string]any) (string, error) {
+// signingAlg is the client's preferred signing algorithm; empty signs with the active key
+func (s *Service) GenerateIDToken(sub, audience, nonce string, authTime time.Time, claims map[string]any, signingAlg string) (string, error) {
 	now := time.Now()
-	exp := now.Add(1 * time.Hour)
+	exp := now.Add(idTokenLifetime)
 
 	builder := jwt.NewBuilder().
 		Subject(sub).
@@ -137,7 +224,14 @@ func (s *Service) GenerateIDToken(sub, audience, nonce string, authTime time.Tim
 		return "", err
 	}
 
-	return s.KeyStore.SignToken(token)
+	signed, err := s.KeyStore.SignTokenWithAlgorithm(token, signingAlg)
+	if errors.Is(err, ErrNoKeyForAlgorithm) {
+		// The key the client registered for may have been rotated out or removed on reload; an ID token
+		// signed with the active key is still verifiable through the JWKS, where failing is not
+		slog.Warn("No signing key for the requested ID token algorithm; using the active key", "alg", signingAlg, "audience", audience)
+		return s.KeyStore.SignToken(token)
+	}
+	return signed, err
 }
 
 // filterAccessTokenScopes removes OIDC scopes that don't belong in access token
@@ -237,19 +331,30 @@ func (s *Service) Register(req user.RegisterRequest) (*user.UserResponse, error)
 }
 
 // IsTokenRevoked checks if a token has been revoked by checking Redis cache
-// It uses the session ID (sid) from the token claims to check if the session is revoked
+// A token is revoked when its own jti was revoked or when the session (sid) backing it was
 func (s *Service) IsTokenRevoked(claims *AccessTokenClaims) (bool, error) {
 	if s.revocationCache == nil {
 		slog.Warn("Token revocation cache not available, skipping revocation check")
 		return false, nil
 	}
 
+	ctx := context.Background()
+	if jti := claims.JwtID(); jti != "" {
+		revoked, err := s.revocationCache.IsTokenRevoked(ctx, jti)
+		if err != nil {
+			slog.Warn("Failed to check token revocation in Redis", "error", err, "jti", jti)
+			return false, nil
+		}
+		if revoked {
+			return true, nil
+		}
+	}
+
 	sessionID := claims.GetSid()
 	if sessionID == "" {
 		return false, nil
 	}
 
-	ctx := context.Background()
 	revoked, err := s.revocationCache.IsSessionRevoked(ctx, sessionID)
 	if err != nil {
 		slog.Warn("Failed to check token revocation in Redis", "error", err, "session_id", sessionID)
@@ -258,3 +363,26 @@ func (s *Service) IsTokenRevoked(claims *AccessTokenClaims) (bool, error) {
 
 	return revoked, nil
 }
+
+// RevokeAccessToken revokes a single access token by its jti until the token expires,
+// leaving the session and every other token issued from it untouched
+func (s *Service) RevokeAccessToken(claims *AccessTokenClaims) error {
+	jti := claims.JwtID()
+	if jti == "" {
+		return ErrInvalidToken
+	}
+
+	ttl := time.Until(claims.Expiration())
+	if ttl <= 0 {
+		// Already expired, nothing left to revoke
+		return nil
+	}
+
+	if s.revocationCache == nil {
+		return errors.New("token revocation cache not available")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.revocationCache.RevokeToken(ctx, jti, ttl)
+}
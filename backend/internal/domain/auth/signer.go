@@ -2,22 +2,66 @@ package auth
 
 import (
 	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/lestrrat-go/jwx/v3/jws"
 	"github.com/lestrrat-go/jwx/v3/jwt"
 )
 
+// Sign signs an access token, typed "at+jwt" so it cannot be mistaken for an ID token (RFC 9068 Section 2.1)
 func (ks *KeyStore) Sign(claims *AccessTokenClaims) (string, error) {
-	return ks.SignToken(claims.Token)
+	return ks.SignTokenWithType(claims.Token, AccessTokenJWTType)
 }
 
+// SignToken signs the token with the active key, using the algorithm set on the key
 func (ks *KeyStore) SignToken(token jwt.Token) (string, error) {
-	key, err := ks.GetActiveKey()
+	return ks.SignTokenWithAlgorithm(token, "")
+}
+
+// SignTokenWithAlgorithm signs the token with a key for the given algorithm, preferring the active key.
+// Clients that registered a preferred ID token signing algorithm get their ID tokens signed this way.
+func (ks *KeyStore) SignTokenWithAlgorithm(token jwt.Token, alg string) (string, error) {
+	return ks.sign(token, alg, nil)
+}
+
+// SignTokenWithType signs the token like SignToken and additionally sets the "typ" protected header.
+// Token profiles such as logout tokens ("logout+jwt") use it to prevent one kind of JWT being accepted as another.
+func (ks *KeyStore) SignTokenWithType(token jwt.Token, typ string) (string, error) {
+	headers := jws.NewHeaders()
+	if err := headers.Set(jws.TypeKey, typ); err != nil {
+		return "", err
+	}
+
+	return ks.sign(token, "", headers)
+}
+
+// sign signs with the Signer of the key for alg, using the algorithm set on the key when it was loaded.
+// The key and its Signer come from one snapshot, so a concurrent Reload cannot separate them.
+// The Signer may hold the private key in memory or reach it remotely; only its signature is needed here.
+func (ks *KeyStore) sign(token jwt.Token, alg string, headers jws.Headers) (string, error) {
+	snapshot := ks.current()
+	key, err := snapshot.keyForAlgorithm(alg)
 	if err != nil {
 		return "", err
 	}
 
-	// Sign with RS256 using the provided token
-	// The key ID is already set on the key, so it will be included in the header
-	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256(), key))
+	signingAlg, ok := key.Algorithm()
+	if !ok {
+		signingAlg = jwa.RS256()
+	}
+
+	keyID, _ := key.KeyID()
+	signer, ok := snapshot.signers[keyID]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+
+	if headers == nil {
+		headers = jws.NewHeaders()
+	}
+	if err := headers.Set(jws.KeyIDKey, keyID); err != nil {
+		return "", err
+	}
+
+	signed, err := jwt.Sign(token, jwt.WithKey(signingAlg, signer, jws.WithProtectedHeaders(headers)))
 	if err != nil {
 		return "", err
 	}
@@ -1,19 +1,31 @@
 package auth
 
 import (
+	"strings"
+
 	"github.com/lestrrat-go/jwx/v3/jws"
 	"github.com/lestrrat-go/jwx/v3/jwt"
 )
 
+// AccessTokenJWTType is the "typ" header of access tokens (RFC 9068 Section 2.1)
+const AccessTokenJWTType = "at+jwt"
+
+// Verify verifies an access token. ID tokens and other JWTs signed with the same keys are
+// rejected by their "typ" header, even when issuer and audience would match (RFC 9068 Section 4).
 func (ks *KeyStore) Verify(tokenString string) (*AccessTokenClaims, error) {
+	keySet := ks.current().keySet
 	verifiedToken, err := jwt.Parse(
 		[]byte(tokenString),
-		jwt.WithKeySet(ks.KeySet, jws.WithInferAlgorithmFromKey(true)),
+		jwt.WithKeySet(keySet, jws.WithInferAlgorithmFromKey(true)),
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	if !isAccessTokenJWT(tokenString) {
+		return nil, ErrNotAccessToken
+	}
+
 	var sidStr string
 	var sid any
 	if verifiedToken.Get("sid", &sid) == nil {
@@ -29,3 +41,38 @@ func (ks *KeyStore) Verify(tokenString string) (*AccessTokenClaims, error) {
 
 	return claims, nil
 }
+
+// VerifySignature verifies the token signature against the key set without validating
+// time-based claims. It is meant for hints such as id_token_hint, where an expired
+// but authentic token is still acceptable.
+func (ks *KeyStore) VerifySignature(tokenString string) (jwt.Token, error) {
+	keySet := ks.current().keySet
+	return jwt.Parse(
+		[]byte(tokenString),
+		jwt.WithKeySet(keySet, jws.WithInferAlgorithmFromKey(true)),
+		jwt.WithValidate(false),
+	)
+}
+
+// isAccessTokenJWT reports whether the token is typed as an access token; the media type form is accepted too
+func isAccessTokenJWT(tokenString string) bool {
+	typ := TokenType(tokenString)
+	return strings.EqualFold(typ, AccessTokenJWTType) || strings.EqualFold(typ, "application/"+AccessTokenJWTType)
+}
+
+// TokenType returns the "typ" protected header of a compact JWS, or "" when it is not set.
+// It does not verify the signature, so callers must verify the token as well.
+func TokenType(tokenString string) string {
+	msg, err := jws.Parse([]byte(tokenString))
+	if err != nil {
+		return ""
+	}
+
+	signatures := msg.Signatures()
+	if len(signatures) != 1 {
+		return ""
+	}
+
+	typ, _ := signatures[0].ProtectedHeaders().Type()
+	return typ
+}
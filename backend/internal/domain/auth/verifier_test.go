@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeyStore(t *testing.T) *KeyStore {
+	t.Helper()
+
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	key, err := NewSigningKey("test", raw, "RS256")
+	require.NoError(t, err)
+
+	ks, err := NewKeyStore("test", []SigningKey{key}, nil, nil)
+	require.NoError(t, err)
+	return ks
+}
+
+func TestVerifyAccessTokenType(t *testing.T) {
+	s := &Service{KeyStore: newTestKeyStore(t), issuer: "https://auth.example.com"}
+	authTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	accessToken, err := s.GenerateAccessToken("user-1", "session-1", []string{"openid", "api"}, "client-1", "api-1", nil, 1,
+		WithAuthentication(authTime, "", []string{AMRPassword}))
+	require.NoError(t, err)
+
+	claims, err := s.KeyStore.Verify(accessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "client-1", claims.ClientID())
+	assert.Equal(t, []string{"api-1"}, claims.Audience())
+	assert.NotEmpty(t, claims.JwtID())
+	assert.Equal(t, authTime.Unix(), claims.AuthTime().Unix())
+	assert.Equal(t, []string{AMRPassword}, claims.AMR())
+
+	// An ID token for the same audience is signed with the same key but must not pass as an access token
+	idToken, err := s.GenerateIDToken("user-1", "client-1", "", authTime, map[string]any{"sid": "session-1"}, "")
+	require.NoError(t, err)
+
+	_, err = s.KeyStore.Verify(idToken)
+	assert.ErrorIs(t, err, ErrNotAccessToken)
+}
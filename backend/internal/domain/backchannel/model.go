@@ -0,0 +1,39 @@
+package backchannel
+
+import (
+	"time"
+
+	"github.com/Anvoria/authly/internal/database"
+	"github.com/google/uuid"
+)
+
+// DeliveryStatus is the state of a back-channel logout notification
+type DeliveryStatus string
+
+const (
+	// StatusPending means the notification has not been acknowledged yet and will be (re)tried
+	StatusPending DeliveryStatus = "pending"
+	// StatusDelivered means the relying party acknowledged the logout token
+	StatusDelivered DeliveryStatus = "delivered"
+	// StatusFailed means all delivery attempts were exhausted
+	StatusFailed DeliveryStatus = "failed"
+)
+
+// Delivery is a back-channel logout notification owed to one relying party for one revoked session
+type Delivery struct {
+	database.BaseModel
+
+	SessionID     uuid.UUID      `gorm:"column:session_id;type:uuid;not null;index"`
+	UserID        string         `gorm:"column:user_id;type:uuid;not null"`
+	ClientID      string         `gorm:"column:client_id;type:varchar(255);not null"`
+	LogoutURI     string         `gorm:"column:logout_uri;type:text;not null"`
+	Status        DeliveryStatus `gorm:"column:status;type:varchar(20);not null;default:pending"`
+	Attempts      int            `gorm:"column:attempts;not null;default:0"`
+	LastError     string         `gorm:"column:last_error;type:text"`
+	NextAttemptAt time.Time      `gorm:"column:next_attempt_at;not null"`
+	DeliveredAt   *time.Time     `gorm:"column:delivered_at"`
+}
+
+func (Delivery) TableName() string {
+	return "backchannel_logout_deliveries"
+}
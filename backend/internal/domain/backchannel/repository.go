@@ -0,0 +1,77 @@
+package backchannel
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository interface for back-channel logout delivery operations
+type Repository interface {
+	Create(delivery *Delivery) error
+	ClaimDue(now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+	Update(delivery *Delivery) error
+}
+
+// repository struct for back-channel logout delivery operations
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository returns a Repository backed by the provided GORM DB handle.
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+// Create persists a new delivery
+func (r *repository) Create(delivery *Delivery) error {
+	return r.db.Create(delivery).Error
+}
+
+// ClaimDue returns pending deliveries whose next attempt is due and pushes their next attempt
+// forward by lease, so that concurrent workers (or server instances) never send the same one twice.
+func (r *repository) ClaimDue(now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	var deliveries []Delivery
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]any, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+		}
+
+		return tx.Model(&Delivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// Update saves the outcome of a delivery attempt
+func (r *repository) Update(delivery *Delivery) error {
+	return r.db.Model(&Delivery{}).
+		Where("id = ?", delivery.ID).
+		Updates(map[string]any{
+			"status":          delivery.Status,
+			"attempts":        delivery.Attempts,
+			"last_error":      delivery.LastError,
+			"next_attempt_at": delivery.NextAttemptAt,
+			"delivered_at":    delivery.DeliveredAt,
+		}).Error
+}
@@ -0,0 +1,243 @@
+package backchannel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/Anvoria/authly/internal/domain/session"
+	"github.com/Anvoria/authly/internal/domain/subject"
+	"github.com/Anvoria/authly/internal/safehttp"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v3/jwt"
+	"gorm.io/gorm"
+)
+
+const (
+	// LogoutEvent is the event type identifying a logout token (OIDC Back-Channel Logout Section 2.4)
+	LogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+	logoutTokenType     = "logout+jwt"
+	logoutTokenLifetime = 2 * time.Minute
+
+	maxAttempts  = 6
+	retryBackoff = 30 * time.Second
+	claimLease   = 1 * time.Minute
+	batchSize    = 50
+
+	// maxResponseSize caps how much of a relying party's response is read; its body is ignored
+	maxResponseSize = 4 << 10
+)
+
+// Service notifies relying parties when a session they received tokens for is revoked
+// (OpenID Connect Back-Channel Logout 1.0). Notifications are persisted as deliveries and
+// sent by Run, which retries failed attempts with exponential backoff.
+type Service struct {
+	repo     Repository
+	sessions session.Service
+	services svc.Repository
+	keyStore *auth.KeyStore
+	subjects subject.Service
+	issuer   string
+	client   *http.Client
+	wake     chan struct{}
+}
+
+// NewService creates a back-channel logout Service. Register it with
+// session.Service.AddRevocationListener and start Run to deliver notifications.
+// Clients register their own backchannel_logout_uri, so notifications only go to public addresses.
+func NewService(repo Repository, sessions session.Service, services svc.Repository, keyStore *auth.KeyStore, subjects subject.Service, issuer string) *Service {
+	return &Service{
+		repo:     repo,
+		sessions: sessions,
+		services: services,
+		keyStore: keyStore,
+		subjects: subjects,
+		issuer:   issuer,
+		client:   safehttp.NewClient(10 * time.Second),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// SessionRevoked implements session.RevocationListener by queueing a delivery for every
+// client that received tokens for the session and has a back-channel logout URI registered
+func (s *Service) SessionRevoked(sess *session.Session) {
+	clientIDs, err := s.sessions.ClientIDs(sess.ID)
+	if err != nil {
+		slog.Error("Failed to load session clients for back-channel logout", "error", err, "session_id", sess.ID.String())
+		return
+	}
+
+	// The relying party knows a client's session by the sid of the browser session it was issued from
+	sid := sess.ID
+	if sess.ParentID != nil {
+		sid = *sess.ParentID
+	}
+
+	queued := false
+	for _, clientID := range clientIDs {
+		service, err := s.services.FindByClientID(clientID)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				slog.Error("Failed to find service for back-channel logout", "error", err, "client_id", clientID)
+			}
+			continue
+		}
+
+		if service.BackchannelLogoutURI == "" {
+			continue
+		}
+
+		delivery := &Delivery{
+			SessionID:     sid,
+			UserID:        sess.UserID,
+			ClientID:      clientID,
+			LogoutURI:     service.BackchannelLogoutURI,
+			Status:        StatusPending,
+			NextAttemptAt: time.Now().UTC(),
+		}
+		if err := s.repo.Create(delivery); err != nil {
+			slog.Error("Failed to queue back-channel logout", "error", err, "session_id", sess.ID.String(), "client_id", clientID)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		// Non-blocking: a pending wake-up already covers this delivery
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run delivers pending notifications until ctx is cancelled. It processes due deliveries
+// every interval and immediately after new ones are queued.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.processDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// processDue claims a batch of due deliveries and attempts them concurrently
+func (s *Service) processDue(ctx context.Context) {
+	deliveries, err := s.repo.ClaimDue(time.Now().UTC(), claimLease, batchSize)
+	if err != nil {
+		slog.Error("Failed to claim back-channel logout deliveries", "error", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Go(func() {
+			s.attempt(ctx, &deliveries[i])
+		})
+	}
+	wg.Wait()
+}
+
+// attempt sends one delivery and records the outcome, scheduling a retry on failure
+func (s *Service) attempt(ctx context.Context, delivery *Delivery) {
+	err := s.send(ctx, delivery)
+	now := time.Now().UTC()
+	delivery.Attempts++
+
+	if err == nil {
+		delivery.Status = StatusDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= maxAttempts {
+			delivery.Status = StatusFailed
+			slog.Warn("Back-channel logout delivery failed permanently", "error", err, "client_id", delivery.ClientID, "session_id", delivery.SessionID.String())
+		} else {
+			delivery.NextAttemptAt = now.Add(retryBackoff << (delivery.Attempts - 1))
+		}
+	}
+
+	if err := s.repo.Update(delivery); err != nil {
+		slog.Error("Failed to record back-channel logout delivery", "error", err, "delivery_id", delivery.ID.String())
+	}
+}
+
+// send posts a freshly signed logout token to the relying party
+// A new token is minted per attempt so that retries never carry an expired token
+func (s *Service) send(ctx context.Context, delivery *Delivery) error {
+	token, err := s.buildLogoutToken(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to build logout token: %w", err)
+	}
+
+	form := url.Values{"logout_token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.LogoutURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("relying party responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// buildLogoutToken creates the signed logout token (OIDC Back-Channel Logout Section 2.4)
+// The sub claim is the subject identifier the relying party knows the user by.
+func (s *Service) buildLogoutToken(delivery *Delivery) (string, error) {
+	service, err := s.services.FindByClientID(delivery.ClientID)
+	if err != nil {
+		return "", fmt.Errorf("failed to find service: %w", err)
+	}
+
+	sub, err := s.subjects.For(delivery.UserID, service)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	token, err := jwt.NewBuilder().
+		Issuer(s.issuer).
+		Subject(sub).
+		Audience([]string{delivery.ClientID}).
+		IssuedAt(now).
+		Expiration(now.Add(logoutTokenLifetime)).
+		JwtID(uuid.NewString()).
+		Claim("sid", delivery.SessionID.String()).
+		Claim("events", map[string]any{LogoutEvent: map[string]any{}}).
+		Build()
+	if err != nil {
+		return "", err
+	}
+
+	return s.keyStore.SignTokenWithType(token, logoutTokenType)
+}
@@ -0,0 +1,72 @@
+package consent
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+	"github.com/Anvoria/authly/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Handler serves the user's own consent grants
+type Handler struct {
+	service Service
+}
+
+// NewHandler creates a Handler backed by the given consent Service
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// List handles GET /v1/auth/me/consents
+func (h *Handler) List(c *fiber.Ctx) error {
+	userID, apiErr := currentUserID(c)
+	if apiErr != nil {
+		return utils.ErrorResponse(c, apiErr)
+	}
+
+	consents, err := h.service.List(userID)
+	if err != nil {
+		slog.Error("Failed to list consents", "error", err, "user_id", userID)
+		return utils.ErrorResponse(c, utils.NewAPIError("INTERNAL_ERROR", "Failed to list consents", fiber.StatusInternalServerError))
+	}
+
+	return utils.SuccessResponse(c, fiber.Map{
+		"consents": consents,
+	}, "Consents retrieved successfully")
+}
+
+// Revoke handles DELETE /v1/auth/me/consents/:client_id
+func (h *Handler) Revoke(c *fiber.Ctx) error {
+	userID, apiErr := currentUserID(c)
+	if apiErr != nil {
+		return utils.ErrorResponse(c, apiErr)
+	}
+
+	if err := h.service.Revoke(userID, c.Params("client_id")); err != nil {
+		if errors.Is(err, ErrConsentNotFound) {
+			return utils.ErrorResponse(c, utils.NewAPIError("CONSENT_NOT_FOUND", "No consent found for this client", fiber.StatusNotFound))
+		}
+		slog.Error("Failed to revoke consent", "error", err, "user_id", userID)
+		return utils.ErrorResponse(c, utils.NewAPIError("INTERNAL_ERROR", "Failed to revoke consent", fiber.StatusInternalServerError))
+	}
+
+	return utils.SuccessResponse(c, nil, "Consent revoked successfully")
+}
+
+// currentUserID returns the user of the session the request was made with
+func currentUserID(c *fiber.Ctx) (uuid.UUID, *utils.APIError) {
+	identity, ok := c.Locals(auth.IdentityKey).(*auth.Identity)
+	if !ok || identity == nil {
+		return uuid.Nil, utils.NewAPIError("NOT_AUTHENTICATED", "You must be logged in to access this resource", fiber.StatusUnauthorized)
+	}
+
+	userID, err := uuid.Parse(identity.UserID)
+	if err != nil {
+		return uuid.Nil, utils.NewAPIError("INVALID_USER_ID", "Invalid user ID", fiber.StatusUnauthorized)
+	}
+
+	return userID, nil
+}
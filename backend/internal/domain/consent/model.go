@@ -0,0 +1,32 @@
+package consent
+
+import (
+	"time"
+
+	"github.com/Anvoria/authly/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Consent records the scopes a user approved for a client
+type Consent struct {
+	database.BaseModel
+
+	UserID   uuid.UUID      `gorm:"column:user_id;type:uuid;not null;uniqueIndex:idx_consents_user_client"`
+	ClientID string         `gorm:"column:client_id;type:varchar(255);not null;uniqueIndex:idx_consents_user_client"`
+	Scopes   pq.StringArray `gorm:"column:scopes;type:text[]"`
+}
+
+func (Consent) TableName() string {
+	return "consents"
+}
+
+// ConsentResponse describes an app grant to the user who approved it
+type ConsentResponse struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	LogoURI    string    `json:"logo_uri,omitempty"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
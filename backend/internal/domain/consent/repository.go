@@ -0,0 +1,60 @@
+package consent
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository interface for consent operations
+type Repository interface {
+	Find(userID uuid.UUID, clientID string) (*Consent, error)
+	FindByUserID(userID uuid.UUID) ([]Consent, error)
+	Upsert(consent *Consent) error
+	Delete(userID uuid.UUID, clientID string) (bool, error)
+}
+
+// repository struct for consent operations
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository returns a Repository backed by the provided GORM DB handle.
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+// Find returns the consent a user gave a client
+func (r *repository) Find(userID uuid.UUID, clientID string) (*Consent, error) {
+	var consent Consent
+	if err := r.db.Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error; err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+// FindByUserID returns all consents of a user, most recently updated first
+func (r *repository) FindByUserID(userID uuid.UUID) ([]Consent, error) {
+	var consents []Consent
+	if err := r.db.Where("user_id = ?", userID).Order("updated_at DESC").Find(&consents).Error; err != nil {
+		return nil, err
+	}
+	return consents, nil
+}
+
+// Upsert creates the consent or replaces the scopes of an existing one
+func (r *repository) Upsert(consent *Consent) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scopes", "updated_at"}),
+	}).Create(consent).Error
+}
+
+// Delete removes a consent permanently so it can be granted again. It reports whether one existed.
+func (r *repository) Delete(userID uuid.UUID, clientID string) (bool, error) {
+	res := r.db.Unscoped().Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&Consent{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
@@ -0,0 +1,123 @@
+package consent
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/Anvoria/authly/internal/domain/session"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrConsentNotFound is returned when revoking a consent the user never gave
+var ErrConsentNotFound = errors.New("consent not found")
+
+// Service remembers which scopes a user approved per client, so the consent screen is only
+// shown again when a client asks for more than it was granted
+type Service interface {
+	Grant(userID uuid.UUID, clientID string, scopes []string) error
+	Covers(userID uuid.UUID, clientID string, scopes []string) (bool, error)
+	List(userID uuid.UUID) ([]ConsentResponse, error)
+	Revoke(userID uuid.UUID, clientID string) error
+}
+
+type service struct {
+	repo     Repository
+	services svc.Repository
+	sessions session.Service
+}
+
+// NewService creates a consent Service. Revoking a consent also revokes the sessions that
+// issued tokens to the client.
+func NewService(repo Repository, services svc.Repository, sessions session.Service) Service {
+	return &service{repo: repo, services: services, sessions: sessions}
+}
+
+// Grant records that the user approved the scopes for the client, adding to any earlier approval
+func (s *service) Grant(userID uuid.UUID, clientID string, scopes []string) error {
+	merged := slices.Clone(scopes)
+
+	existing, err := s.repo.Find(userID, clientID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to find consent: %w", err)
+	}
+	if existing != nil {
+		merged = append(merged, existing.Scopes...)
+	}
+
+	slices.Sort(merged)
+	merged = slices.Compact(merged)
+
+	return s.repo.Upsert(&Consent{UserID: userID, ClientID: clientID, Scopes: merged})
+}
+
+// Covers reports whether the user already approved every one of the scopes for the client
+func (s *service) Covers(userID uuid.UUID, clientID string, scopes []string) (bool, error) {
+	existing, err := s.repo.Find(userID, clientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to find consent: %w", err)
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(existing.Scopes, scope) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// List returns the user's app grants with the name and logo of each client
+func (s *service) List(userID uuid.UUID) ([]ConsentResponse, error) {
+	consents, err := s.repo.FindByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consents: %w", err)
+	}
+
+	res := make([]ConsentResponse, 0, len(consents))
+	for _, c := range consents {
+		item := ConsentResponse{
+			ClientID:  c.ClientID,
+			Scopes:    c.Scopes,
+			GrantedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+		}
+
+		client, err := s.services.FindByClientID(c.ClientID)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("failed to find service: %w", err)
+			}
+			// The client was deleted; keep the grant visible so it can still be revoked
+		} else {
+			item.ClientName = client.Name
+			item.LogoURI = client.LogoURI
+		}
+
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// Revoke forgets the user's consent for the client and signs the client out of the user's sessions
+func (s *service) Revoke(userID uuid.UUID, clientID string) error {
+	deleted, err := s.repo.Delete(userID, clientID)
+	if err != nil {
+		return fmt.Errorf("failed to delete consent: %w", err)
+	}
+	if !deleted {
+		return ErrConsentNotFound
+	}
+
+	if err := s.sessions.RevokeClientSessions(userID, clientID); err != nil {
+		slog.Warn("Failed to revoke client sessions after consent revocation", "error", err, "user_id", userID, "client_id", clientID)
+	}
+
+	return nil
+}
@@ -0,0 +1,305 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Anvoria/authly/internal/domain/user"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// CIBAGrantType is the grant_type used by clients polling for a backchannel authentication result (CIBA Core Section 10.1)
+	CIBAGrantType = "urn:openid:params:grant-type:ciba"
+
+	cibaDefaultLifetime = 5 * time.Minute
+	cibaMaxLifetime     = 10 * time.Minute
+	cibaDefaultInterval = 5 // seconds
+	cibaSessionTTL      = 168 * time.Hour
+
+	// bindingMessageMaxLength keeps the binding message short enough to show on a phone
+	bindingMessageMaxLength = 128
+)
+
+// BackchannelAuthentication starts a client-initiated backchannel authentication (CIBA poll mode)
+// for the user named by login_hint. The user is asked through the notifier to approve the request
+// on their own device while the client polls the token endpoint with the returned auth_req_id.
+func (s *Service) BackchannelAuthentication(req *BackchannelAuthenticationRequest) (*BackchannelAuthenticationResponse, error) {
+	// Only confidential clients may start a backchannel authentication (CIBA Core Section 7.1)
+	service, err := s.authenticateConfidentialClient(req.credentials())
+	if err != nil {
+		return nil, err
+	}
+	if !service.AllowsGrantType(CIBAGrantType) {
+		return nil, ErrUnauthorizedClient
+	}
+
+	requestedScopes := strings.Fields(req.Scope)
+	if !slices.Contains(requestedScopes, "openid") {
+		return nil, OIDCError{Code: ErrorCodeInvalidScope, Description: "The openid scope is required", StatusCode: http.StatusBadRequest}
+	}
+	if !s.isValidScopes(service.AllowedScopes, requestedScopes) {
+		return nil, ErrInvalidScope
+	}
+
+	if err := validateBindingMessage(req.BindingMessage); err != nil {
+		return nil, err
+	}
+
+	lifetime := cibaDefaultLifetime
+	if req.RequestedExpiry < 0 {
+		return nil, OIDCError{Code: ErrorCodeInvalidRequest, Description: "requested_expiry must be a positive integer", StatusCode: http.StatusBadRequest}
+	}
+	if req.RequestedExpiry > 0 {
+		lifetime = min(time.Duration(req.RequestedExpiry)*time.Second, cibaMaxLifetime)
+	}
+
+	resources, err := s.resolveResources(service, req.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := s.findUserByLoginHint(req.LoginHint)
+	if err != nil {
+		return nil, err
+	}
+
+	authReqID, err := s.generateAuthorizationCode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate auth_req_id: %w", err)
+	}
+
+	authReq := &BackchannelAuthentication{
+		AuthReqID:      authReqID,
+		ClientID:       service.ClientID,
+		UserID:         u.ID,
+		Scopes:         strings.Join(requestedScopes, " "),
+		Resources:      strings.Join(resources, " "),
+		BindingMessage: req.BindingMessage,
+		Status:         DeviceCodePending,
+		Interval:       cibaDefaultInterval,
+		ExpiresAt:      time.Now().Add(lifetime),
+	}
+	if err := s.codeRepo.CreateBackchannelAuthentication(authReq); err != nil {
+		return nil, fmt.Errorf("failed to save backchannel authentication request: %w", err)
+	}
+
+	err = s.notifier.NotifyBackchannelAuthentication(context.Background(), &BackchannelNotification{
+		RequestID:      authReq.ID,
+		UserID:         u.ID,
+		ClientID:       service.ClientID,
+		ClientName:     service.Name,
+		Scopes:         requestedScopes,
+		BindingMessage: req.BindingMessage,
+		ExpiresAt:      authReq.ExpiresAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to notify user: %w", err)
+	}
+
+	return &BackchannelAuthenticationResponse{
+		AuthReqID: authReqID,
+		ExpiresIn: int(lifetime.Seconds()),
+		Interval:  cibaDefaultInterval,
+	}, nil
+}
+
+// ListBackchannelAuthentications returns the backchannel authentication requests awaiting the user's decision
+func (s *Service) ListBackchannelAuthentications(userID uuid.UUID) ([]*PendingBackchannelAuthentication, error) {
+	reqs, err := s.codeRepo.FindPendingBackchannelAuthentications(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find backchannel authentication requests: %w", err)
+	}
+
+	pending := make([]*PendingBackchannelAuthentication, 0, len(reqs))
+	for _, req := range reqs {
+		service, err := s.serviceRepo.FindByClientID(req.ClientID)
+		if err != nil {
+			// Skip requests of deleted clients
+			continue
+		}
+
+		pending = append(pending, &PendingBackchannelAuthentication{
+			ID: req.ID.String(),
+			Client: &ClientInfo{
+				ID:            service.ClientID,
+				Name:          service.Name,
+				RedirectURIs:  service.RedirectURIs,
+				AllowedScopes: service.AllowedScopes,
+				Active:        service.Active,
+			},
+			Scopes:         strings.Fields(req.Scopes),
+			BindingMessage: req.BindingMessage,
+			ExpiresAt:      req.ExpiresAt,
+		})
+	}
+
+	return pending, nil
+}
+
+// ConfirmBackchannelAuthentication records the user's approval or denial of a backchannel authentication request
+func (s *Service) ConfirmBackchannelAuthentication(requestID string, userID uuid.UUID, approve bool) error {
+	id, err := uuid.Parse(requestID)
+	if err != nil {
+		return ErrInvalidAuthRequest
+	}
+
+	authReq, err := s.codeRepo.FindBackchannelAuthenticationByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidAuthRequest
+		}
+		return fmt.Errorf("failed to find backchannel authentication request: %w", err)
+	}
+	if authReq.UserID != userID {
+		return ErrInvalidAuthRequest
+	}
+
+	status := DeviceCodeDenied
+	if approve {
+		service, err := s.findActiveClient(authReq.ClientID)
+		if err != nil {
+			return err
+		}
+		if err := s.checkUserAccess(userID.String(), service); err != nil {
+			return err
+		}
+
+		status = DeviceCodeApproved
+	}
+
+	updated, err := s.codeRepo.UpdateBackchannelAuthenticationStatus(id, userID, DeviceCodePending, status)
+	if err != nil {
+		return fmt.Errorf("failed to update backchannel authentication request: %w", err)
+	}
+	if !updated {
+		return ErrInvalidAuthRequest
+	}
+
+	return nil
+}
+
+// CIBAGrant handles a client polling the token endpoint for a backchannel authentication result
+// (CIBA Core Section 10.1). Like the device code grant, it returns ErrAuthorizationPending until the
+// user acts, ErrSlowDown when the client polls too fast, and tokens exactly once after approval.
+func (s *Service) CIBAGrant(req *TokenRequest) (*TokenResponse, error) {
+	if req.GrantType != CIBAGrantType {
+		return nil, ErrInvalidGrant
+	}
+
+	service, err := s.authenticateConfidentialClient(req.credentials())
+	if err != nil {
+		return nil, err
+	}
+	if !service.AllowsGrantType(req.GrantType) {
+		return nil, ErrUnauthorizedClient
+	}
+
+	authReq, err := s.codeRepo.FindBackchannelAuthentication(req.AuthReqID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidGrant
+		}
+		return nil, fmt.Errorf("failed to find backchannel authentication request: %w", err)
+	}
+
+	if authReq.ClientID != service.ClientID {
+		return nil, ErrInvalidGrant
+	}
+
+	now := time.Now()
+	if now.After(authReq.ExpiresAt) {
+		return nil, ErrExpiredToken
+	}
+
+	interval, tooFast := pollInterval(authReq.LastPolledAt, authReq.Interval, now)
+	if err := s.codeRepo.UpdateBackchannelAuthenticationPoll(authReq.ID, now, interval); err != nil {
+		return nil, fmt.Errorf("failed to record backchannel authentication poll: %w", err)
+	}
+	if tooFast {
+		return nil, ErrSlowDown
+	}
+
+	switch authReq.Status {
+	case DeviceCodePending:
+		return nil, ErrAuthorizationPending
+	case DeviceCodeDenied:
+		return nil, ErrAccessDenied
+	case DeviceCodeApproved:
+	default:
+		return nil, ErrInvalidGrant
+	}
+
+	resources, err := s.narrowResources(service, authReq.Resources, req.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	// Consume the approval atomically so concurrent polls cannot both receive tokens
+	consumed, err := s.codeRepo.UpdateBackchannelAuthenticationStatus(authReq.ID, authReq.UserID, DeviceCodeApproved, DeviceCodeConsumed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume backchannel authentication request: %w", err)
+	}
+	if !consumed {
+		return nil, ErrInvalidGrant
+	}
+
+	// Permissions may have changed since the user approved the request
+	if err := s.checkUserAccess(authReq.UserID.String(), service); err != nil {
+		return nil, err
+	}
+
+	scopes := strings.Fields(authReq.Scopes)
+
+	// The client gets its own session so the user can list and revoke it like any other login
+	sessionID, secret, err := s.sessionService.Create(authReq.UserID, req.UserAgent, req.IPAddress, scopes, cibaSessionTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return s.issueUserTokens(authReq.UserID, sessionID, secret, service, scopes, resources, "", now, req.DPoPJKT)
+}
+
+// findUserByLoginHint resolves a login_hint, a username or email address, to an active user
+func (s *Service) findUserByLoginHint(loginHint string) (*user.User, error) {
+	if loginHint == "" {
+		return nil, OIDCError{Code: ErrorCodeInvalidRequest, Description: "login_hint is required", StatusCode: http.StatusBadRequest}
+	}
+
+	u, err := s.userService.FindByUsername(loginHint)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		u, err = s.userService.FindByEmail(loginHint)
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUnknownUserID
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if !u.IsActive {
+		return nil, ErrUnknownUserID
+	}
+
+	return u, nil
+}
+
+// validateBindingMessage checks that the binding message can be shown as is on both devices
+func validateBindingMessage(message string) error {
+	if utf8.RuneCountInString(message) > bindingMessageMaxLength {
+		return ErrInvalidBindingMessage
+	}
+	for _, r := range message {
+		if !unicode.IsPrint(r) {
+			return ErrInvalidBindingMessage
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package oidc
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BackchannelNotification asks a user to approve a backchannel authentication request on their
+// authentication device
+type BackchannelNotification struct {
+	// RequestID identifies the request when the user approves or denies it; it is not the auth_req_id
+	RequestID      uuid.UUID
+	UserID         uuid.UUID
+	ClientID       string
+	ClientName     string
+	Scopes         []string
+	BindingMessage string
+	ExpiresAt      time.Time
+}
+
+// BackchannelNotifier delivers backchannel authentication requests to users, for example as a push
+// notification to a mobile app. Delivery failures fail the request so the client learns about them.
+type BackchannelNotifier interface {
+	NotifyBackchannelAuthentication(ctx context.Context, notification *BackchannelNotification) error
+}
+
+// LogNotifier writes backchannel authentication requests to the log. It suits development setups
+// where users approve pending requests through the API.
+type LogNotifier struct{}
+
+// NewLogNotifier creates a notifier that only logs
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+// NotifyBackchannelAuthentication logs the notification
+func (n *LogNotifier) NotifyBackchannelAuthentication(_ context.Context, notification *BackchannelNotification) error {
+	slog.Info("Backchannel authentication requested",
+		"request_id", notification.RequestID,
+		"user_id", notification.UserID,
+		"client_id", notification.ClientID,
+		"binding_message", notification.BindingMessage,
+	)
+	return nil
+}
+
+// MemoryNotifier keeps backchannel authentication requests in memory, for tests
+type MemoryNotifier struct {
+	mu            sync.Mutex
+	notifications []*BackchannelNotification
+}
+
+// NewMemoryNotifier creates an empty in-memory notifier
+func NewMemoryNotifier() *MemoryNotifier {
+	return &MemoryNotifier{}
+}
+
+// NotifyBackchannelAuthentication records the notification
+func (n *MemoryNotifier) NotifyBackchannelAuthentication(_ context.Context, notification *BackchannelNotification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+// Notifications returns the notifications delivered so far
+func (n *MemoryNotifier) Notifications() []*BackchannelNotification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return append([]*BackchannelNotification(nil), n.notifications...)
+}
@@ -0,0 +1,172 @@
+package oidc
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Anvoria/authly/internal/domain/permission"
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/Anvoria/authly/internal/domain/user"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// backchannelStore keeps backchannel authentication requests in memory
+type backchannelStore struct {
+	Repository
+	requests map[uuid.UUID]*BackchannelAuthentication
+}
+
+func (r *backchannelStore) CreateBackchannelAuthentication(req *BackchannelAuthentication) error {
+	req.ID = uuid.New()
+	r.requests[req.ID] = req
+	return nil
+}
+
+func (r *backchannelStore) FindBackchannelAuthentication(authReqID string) (*BackchannelAuthentication, error) {
+	for _, req := range r.requests {
+		if req.AuthReqID == authReqID {
+			return req, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *backchannelStore) FindBackchannelAuthenticationByID(id uuid.UUID) (*BackchannelAuthentication, error) {
+	if req, ok := r.requests[id]; ok {
+		return req, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *backchannelStore) UpdateBackchannelAuthenticationStatus(id, userID uuid.UUID, from, to DeviceCodeStatus) (bool, error) {
+	req, ok := r.requests[id]
+	if !ok || req.UserID != userID || req.Status != from {
+		return false, nil
+	}
+	req.Status = to
+	return true, nil
+}
+
+func (r *backchannelStore) UpdateBackchannelAuthenticationPoll(id uuid.UUID, polledAt time.Time, interval int) error {
+	r.requests[id].LastPolledAt = &polledAt
+	r.requests[id].Interval = interval
+	return nil
+}
+
+type userDirectory struct {
+	user.Service
+	users []*user.User
+}
+
+func (d *userDirectory) FindByUsername(username string) (*user.User, error) {
+	for _, u := range d.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (d *userDirectory) FindByEmail(email string) (*user.User, error) {
+	for _, u := range d.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+type allowAll struct {
+	permission.ServiceInterface
+}
+
+func (allowAll) HasAnyPermission(string, string) (bool, error) {
+	return true, nil
+}
+
+func (allowAll) BuildScopes(string) (map[string]uint64, error) {
+	return map[string]uint64{}, nil
+}
+
+func (allowAll) GetPermissionVersion(string) (int, error) {
+	return 1, nil
+}
+
+func TestBackchannelAuthentication(t *testing.T) {
+	customer := &user.User{Username: "jane", Email: "jane@example.com", IsActive: true}
+	customer.ID = uuid.New()
+
+	callCentre := &svc.Service{
+		ClientID:                "call-centre",
+		ClientSecret:            "secret",
+		Name:                    "Call Centre",
+		TokenEndpointAuthMethod: svc.AuthMethodClientSecretPost,
+		AllowedScopes:           []string{"openid", "profile"},
+		Active:                  true,
+	}
+
+	store := &backchannelStore{requests: map[uuid.UUID]*BackchannelAuthentication{}}
+	notifier := NewMemoryNotifier()
+	s := &Service{
+		serviceRepo:       &serviceDirectory{services: []*svc.Service{callCentre}},
+		codeRepo:          store,
+		userService:       &userDirectory{users: []*user.User{customer}},
+		permissionService: allowAll{},
+		notifier:          notifier,
+	}
+
+	request := func(loginHint, bindingMessage string) (*BackchannelAuthenticationResponse, error) {
+		return s.BackchannelAuthentication(&BackchannelAuthenticationRequest{
+			ClientID:         "call-centre",
+			ClientSecret:     "secret",
+			ClientAuthMethod: svc.AuthMethodClientSecretPost,
+			Scope:            "openid profile",
+			LoginHint:        loginHint,
+			BindingMessage:   bindingMessage,
+		})
+	}
+	poll := func(authReqID string) error {
+		_, err := s.CIBAGrant(&TokenRequest{
+			GrantType:        CIBAGrantType,
+			AuthReqID:        authReqID,
+			ClientID:         "call-centre",
+			ClientSecret:     "secret",
+			ClientAuthMethod: svc.AuthMethodClientSecretPost,
+		})
+		return err
+	}
+
+	t.Run("rejects unknown users and bad binding messages", func(t *testing.T) {
+		_, err := request("nobody", "")
+		assert.ErrorIs(t, err, ErrUnknownUserID)
+
+		_, err = request("jane", strings.Repeat("x", bindingMessageMaxLength+1))
+		assert.ErrorIs(t, err, ErrInvalidBindingMessage)
+	})
+
+	t.Run("notifies the user and stays pending until they decide", func(t *testing.T) {
+		res, err := request("jane@example.com", "Code 4711")
+		require.NoError(t, err)
+		assert.Equal(t, cibaDefaultInterval, res.Interval)
+
+		notifications := notifier.Notifications()
+		require.Len(t, notifications, 1)
+		assert.Equal(t, customer.ID, notifications[0].UserID)
+		assert.Equal(t, "Code 4711", notifications[0].BindingMessage)
+
+		assert.ErrorIs(t, poll(res.AuthReqID), ErrAuthorizationPending)
+		assert.ErrorIs(t, poll(res.AuthReqID), ErrSlowDown)
+
+		requestID := notifications[0].RequestID.String()
+		assert.ErrorIs(t, s.ConfirmBackchannelAuthentication(requestID, uuid.New(), true), ErrInvalidAuthRequest)
+		require.NoError(t, s.ConfirmBackchannelAuthentication(requestID, customer.ID, false))
+		assert.ErrorIs(t, s.ConfirmBackchannelAuthentication(requestID, customer.ID, true), ErrInvalidAuthRequest)
+
+		store.requests[notifications[0].RequestID].LastPolledAt = nil
+		assert.ErrorIs(t, poll(res.AuthReqID), ErrAccessDenied)
+	})
+}
@@ -0,0 +1,222 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/google/uuid"
+)
+
+// claimScopes maps every claim that can be requested individually to the scope that releases it
+// as a group. sub and auth_time are part of every ID token and belong to no scope.
+var claimScopes = map[string]string{
+	"sub":       "",
+	"auth_time": "",
+
+	"name":               "profile",
+	"preferred_username": "profile",
+	"given_name":         "profile",
+	"family_name":        "profile",
+	"created_at":         "profile",
+	"updated_at":         "profile",
+	"active":             "profile",
+
+	"email":          "email",
+	"email_verified": "email",
+}
+
+// SupportedClaims lists the claims that can be requested individually, for discovery
+func SupportedClaims() []string {
+	return slices.Sorted(maps.Keys(claimScopes))
+}
+
+// ClaimsRequest is the claims authorization request parameter (OIDC Core Section 5.5). It asks
+// for individual claims in the ID token and from the userinfo endpoint.
+type ClaimsRequest struct {
+	UserInfo map[string]*ClaimRequest `json:"userinfo,omitempty"`
+	IDToken  map[string]*ClaimRequest `json:"id_token,omitempty"`
+}
+
+// ClaimRequest qualifies a single requested claim (OIDC Core Section 5.5.1). A nil ClaimRequest
+// requests the claim in the default manner, as a voluntary claim.
+type ClaimRequest struct {
+	Essential bool  `json:"essential,omitempty"`
+	Value     any   `json:"value,omitempty"`
+	Values    []any `json:"values,omitempty"`
+}
+
+// parseClaimsRequest parses the JSON claims parameter. An empty parameter yields nil.
+func parseClaimsRequest(raw string) (*ClaimsRequest, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var claims ClaimsRequest
+	if err := json.Unmarshal([]byte(raw), &claims); err != nil {
+		return nil, ErrInvalidClaimsRequest
+	}
+	if claims.UserInfo == nil && claims.IDToken == nil {
+		return nil, nil
+	}
+
+	return &claims, nil
+}
+
+// restrict drops the claims the client cannot receive: unsupported claims and claims of scopes the
+// client is not allowed. Voluntary claims are dropped silently; an essential claim outside the
+// client's scopes fails the request, since the client would otherwise never learn why it is missing.
+func (c *ClaimsRequest) restrict(allowedScopes []string) error {
+	if c == nil {
+		return nil
+	}
+
+	for _, members := range []map[string]*ClaimRequest{c.UserInfo, c.IDToken} {
+		for name, req := range members {
+			scope, supported := claimScopes[name]
+			if !supported {
+				delete(members, name)
+				continue
+			}
+			if scope == "" || slices.Contains(allowedScopes, scope) {
+				continue
+			}
+			if req != nil && req.Essential {
+				return OIDCError{
+					Code:        ErrorCodeInvalidRequest,
+					Description: fmt.Sprintf("The essential claim %s is not available to this client", name),
+					StatusCode:  http.StatusBadRequest,
+				}
+			}
+			delete(members, name)
+		}
+	}
+
+	return nil
+}
+
+// scopes returns the scopes whose claims are requested, which the user consents to like requested scopes
+func (c *ClaimsRequest) scopes() []string {
+	if c == nil {
+		return nil
+	}
+
+	var scopes []string
+	for _, members := range []map[string]*ClaimRequest{c.UserInfo, c.IDToken} {
+		for name := range members {
+			if scope := claimScopes[name]; scope != "" && !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	slices.Sort(scopes)
+
+	return scopes
+}
+
+// withClaimScopes adds the scopes of the requested claims to the requested scopes
+func withClaimScopes(requestedScopes []string, claims *ClaimsRequest) []string {
+	scopes := slices.Clone(requestedScopes)
+	for _, scope := range claims.scopes() {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// requestedSubject returns the sub value the client asked for, if any
+func (c *ClaimsRequest) requestedSubject() (any, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	for _, members := range []map[string]*ClaimRequest{c.IDToken, c.UserInfo} {
+		if req := members["sub"]; req != nil && req.Value != nil {
+			return req.Value, true
+		}
+	}
+	return nil, false
+}
+
+// checkRequestedSubject fails when the client asked for a specific sub that is not the signed-in user
+// (OIDC Core Section 3.1.2.2). The value is compared with the subject identifier the client knows.
+func (s *Service) checkRequestedSubject(claims *ClaimsRequest, service *svc.Service, userID uuid.UUID) error {
+	value, ok := claims.requestedSubject()
+	if !ok {
+		return nil
+	}
+
+	sub, err := s.subjectFor(userID.String(), service)
+	if err != nil {
+		return err
+	}
+	if fmt.Sprint(value) != sub {
+		return ErrLoginRequired
+	}
+
+	return nil
+}
+
+// encode serializes the claims request for storage with an authorization code or session
+func (c *ClaimsRequest) encode() (string, error) {
+	if c == nil {
+		return "", nil
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode claims request: %w", err)
+	}
+	return string(data), nil
+}
+
+// userInfoRequest serializes only the userinfo member, which travels with the access token
+func (c *ClaimsRequest) userInfoRequest() (string, error) {
+	if c == nil || len(c.UserInfo) == 0 {
+		return "", nil
+	}
+	return (&ClaimsRequest{UserInfo: c.UserInfo}).encode()
+}
+
+// matches reports whether value satisfies the value or values constraint of the claim request
+func (r *ClaimRequest) matches(value any) bool {
+	if r == nil || (r.Value == nil && len(r.Values) == 0) {
+		return true
+	}
+
+	actual := fmt.Sprint(value)
+	if r.Value != nil && fmt.Sprint(r.Value) == actual {
+		return true
+	}
+	for _, candidate := range r.Values {
+		if fmt.Sprint(candidate) == actual {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseClaims selects the claims to return from everything known about the user: the claims of
+// the granted scopes plus the individually requested ones. Claims whose requested value does not
+// match are left out.
+func releaseClaims(available map[string]any, scopes []string, requested map[string]*ClaimRequest) map[string]any {
+	released := make(map[string]any)
+
+	for name, value := range available {
+		req, isRequested := requested[name]
+		scope := claimScopes[name]
+		if !isRequested && (scope == "" || !slices.Contains(scopes, scope)) {
+			continue
+		}
+		if !req.matches(value) {
+			continue
+		}
+		released[name] = value
+	}
+
+	return released
+}
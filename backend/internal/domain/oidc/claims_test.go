@@ -0,0 +1,54 @@
+package oidc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimsRequest(t *testing.T) {
+	t.Run("empty and malformed", func(t *testing.T) {
+		claims, err := parseClaimsRequest("")
+		require.NoError(t, err)
+		assert.Nil(t, claims)
+
+		_, err = parseClaimsRequest("{not json")
+		assert.ErrorIs(t, err, ErrInvalidClaimsRequest)
+	})
+
+	t.Run("restrict drops claims the client cannot receive", func(t *testing.T) {
+		claims, err := parseClaimsRequest(`{"id_token":{"email":null,"given_name":null,"shoe_size":null}}`)
+		require.NoError(t, err)
+
+		require.NoError(t, claims.restrict([]string{"openid", "email"}))
+		assert.Contains(t, claims.IDToken, "email")
+		assert.NotContains(t, claims.IDToken, "given_name")
+		assert.NotContains(t, claims.IDToken, "shoe_size")
+		assert.Equal(t, []string{"openid", "email"}, withClaimScopes([]string{"openid"}, claims))
+	})
+
+	t.Run("essential claims outside the client's scopes fail", func(t *testing.T) {
+		claims, err := parseClaimsRequest(`{"userinfo":{"given_name":{"essential":true}}}`)
+		require.NoError(t, err)
+
+		err = claims.restrict([]string{"openid"})
+		assert.Equal(t, ErrorCodeInvalidRequest, MapErrorToOIDC(err).Code)
+	})
+
+	t.Run("release honours scopes, requests and values", func(t *testing.T) {
+		available := map[string]any{
+			"email":       "jane@example.com",
+			"given_name":  "Jane",
+			"family_name": "Doe",
+		}
+
+		released := releaseClaims(available, nil, map[string]*ClaimRequest{"given_name": nil})
+		assert.Equal(t, map[string]any{"given_name": "Jane"}, released)
+
+		released = releaseClaims(available, []string{"email"}, map[string]*ClaimRequest{
+			"family_name": {Values: []any{"Smith"}},
+		})
+		assert.Equal(t, map[string]any{"email": "jane@example.com"}, released)
+	})
+}
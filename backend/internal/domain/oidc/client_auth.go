@@ -0,0 +1,216 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/lestrrat-go/jwx/v3/jws"
+	"github.com/lestrrat-go/jwx/v3/jwt"
+	"gorm.io/gorm"
+)
+
+const (
+	// ClientAssertionTypeJWTBearer is the only client_assertion_type accepted (RFC 7523 Section 2.2)
+	ClientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	clientAssertionSkew        = 30 * time.Second
+	clientAssertionMaxLifetime = 1 * time.Hour
+)
+
+// ClientCredentials are the credentials a client presented to an endpoint
+type ClientCredentials struct {
+	ClientID            string
+	ClientSecret        string
+	ClientAssertionType string
+	ClientAssertion     string
+	// Method is how a client secret was transmitted: client_secret_basic, client_secret_post, or none.
+	// It is ignored for assertions, whose method follows from the client's registration.
+	Method string
+}
+
+func (r *TokenRequest) credentials() ClientCredentials {
+	return ClientCredentials{r.ClientID, r.ClientSecret, r.ClientAssertionType, r.ClientAssertion, r.ClientAuthMethod}
+}
+
+func (r *IntrospectionRequest) credentials() ClientCredentials {
+	return ClientCredentials{r.ClientID, r.ClientSecret, r.ClientAssertionType, r.ClientAssertion, r.ClientAuthMethod}
+}
+
+func (r *RevocationRequest) credentials() ClientCredentials {
+	return ClientCredentials{r.ClientID, r.ClientSecret, r.ClientAssertionType, r.ClientAssertion, r.ClientAuthMethod}
+}
+
+func (r *PushedAuthorizationRequest) credentials() ClientCredentials {
+	return ClientCredentials{r.ClientID, r.ClientSecret, r.ClientAssertionType, r.ClientAssertion, r.ClientAuthMethod}
+}
+
+func (r *DeviceAuthorizationRequest) credentials() ClientCredentials {
+	return ClientCredentials{r.ClientID, r.ClientSecret, r.ClientAssertionType, r.ClientAssertion, r.ClientAuthMethod}
+}
+
+func (r *BackchannelAuthenticationRequest) credentials() ClientCredentials {
+	return ClientCredentials{r.ClientID, r.ClientSecret, r.ClientAssertionType, r.ClientAssertion, r.ClientAuthMethod}
+}
+
+// authenticateClient resolves and authenticates the calling client using its registered
+// token_endpoint_auth_method. Public clients (method "none") are identified by client_id alone.
+func (s *Service) authenticateClient(creds ClientCredentials) (*svc.Service, error) {
+	if creds.ClientAssertion != "" || creds.ClientAssertionType != "" {
+		return s.authenticateClientAssertion(creds)
+	}
+
+	service, err := s.findActiveClient(creds.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch service.TokenEndpointAuthMethod {
+	case svc.AuthMethodNone:
+		return service, nil
+	case svc.AuthMethodClientSecretBasic, svc.AuthMethodClientSecretPost:
+		if creds.ClientSecret == "" || service.ClientSecret == "" {
+			return nil, ErrInvalidClientSecret
+		}
+		if creds.Method != service.TokenEndpointAuthMethod {
+			return nil, ErrInvalidClientAuthMethod
+		}
+		if subtle.ConstantTimeCompare([]byte(service.ClientSecret), []byte(creds.ClientSecret)) != 1 {
+			return nil, ErrInvalidClientSecret
+		}
+		return service, nil
+	default:
+		// JWT-authenticated clients must not fall back to presenting a shared secret
+		return nil, ErrInvalidClientAuthMethod
+	}
+}
+
+// authenticateConfidentialClient authenticates a client like authenticateClient but rejects public clients
+func (s *Service) authenticateConfidentialClient(creds ClientCredentials) (*svc.Service, error) {
+	service, err := s.authenticateClient(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	if service.TokenEndpointAuthMethod == svc.AuthMethodNone {
+		return nil, ErrUnauthorizedClient
+	}
+
+	return service, nil
+}
+
+// authenticateClientAssertion authenticates a client by a signed JWT (RFC 7523 Section 3).
+// client_secret_jwt assertions are HMAC-signed with the client secret, private_key_jwt assertions
+// with a key from the client's registered JWK Set. Each assertion is accepted only once.
+func (s *Service) authenticateClientAssertion(creds ClientCredentials) (*svc.Service, error) {
+	if creds.ClientAssertionType != ClientAssertionTypeJWTBearer || creds.ClientAssertion == "" {
+		return nil, ErrInvalidClientAssertion
+	}
+
+	// The unverified subject only selects which client's keys to verify with
+	unverified, err := jwt.ParseInsecure([]byte(creds.ClientAssertion))
+	if err != nil {
+		return nil, ErrInvalidClientAssertion
+	}
+	clientID, ok := unverified.Subject()
+	if !ok || clientID == "" {
+		return nil, ErrInvalidClientAssertion
+	}
+	if creds.ClientID != "" && creds.ClientID != clientID {
+		return nil, ErrInvalidClientAssertion
+	}
+
+	service, err := s.findActiveClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	var keyOption jwt.ParseOption
+	switch service.TokenEndpointAuthMethod {
+	case svc.AuthMethodClientSecretJWT:
+		if service.ClientSecret == "" {
+			return nil, ErrInvalidClientAssertion
+		}
+		keyOption = jwt.WithKey(jwa.HS256(), []byte(service.ClientSecret))
+	case svc.AuthMethodPrivateKeyJWT:
+		keySet, err := s.clientKeys.keySet(ctx, service)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client keys: %w", err)
+		}
+		keyOption = jwt.WithKeySet(keySet, jws.WithInferAlgorithmFromKey(true))
+	default:
+		return nil, ErrInvalidClientAuthMethod
+	}
+
+	token, err := jwt.Parse(
+		[]byte(creds.ClientAssertion),
+		keyOption,
+		jwt.WithValidate(true),
+		jwt.WithAcceptableSkew(clientAssertionSkew),
+		jwt.WithIssuer(service.ClientID),
+		jwt.WithSubject(service.ClientID),
+	)
+	if err != nil {
+		return nil, ErrInvalidClientAssertion
+	}
+
+	audience, _ := token.Audience()
+	if !slices.ContainsFunc(audience, s.isClientAssertionAudience) {
+		return nil, ErrInvalidClientAssertion
+	}
+
+	exp, ok := token.Expiration()
+	if !ok || time.Until(exp) > clientAssertionMaxLifetime {
+		return nil, ErrInvalidClientAssertion
+	}
+
+	jti, ok := token.JwtID()
+	if !ok || jti == "" {
+		return nil, ErrInvalidClientAssertion
+	}
+
+	first, err := s.replayCache.Claim(ctx, "client_assertion:"+service.ClientID, jti, time.Until(exp)+clientAssertionSkew)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record client assertion: %w", err)
+	}
+	if !first {
+		return nil, ErrInvalidClientAssertion
+	}
+
+	return service, nil
+}
+
+// isClientAssertionAudience accepts the issuer or any of its OAuth endpoints as assertion audience
+func (s *Service) isClientAssertionAudience(aud string) bool {
+	issuer := s.authService.Issuer()
+	return aud == issuer || strings.HasPrefix(aud, issuer+"/v1/oauth/")
+}
+
+// findActiveClient looks up a client by client_id and rejects disabled clients
+func (s *Service) findActiveClient(clientID string) (*svc.Service, error) {
+	if clientID == "" {
+		return nil, ErrInvalidClientID
+	}
+
+	service, err := s.serviceRepo.FindByClientID(clientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidClientID
+		}
+		return nil, fmt.Errorf("failed to find service: %w", err)
+	}
+
+	if !service.Active {
+		return nil, ErrClientNotActive
+	}
+
+	return service, nil
+}
@@ -0,0 +1,99 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/Anvoria/authly/internal/safehttp"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+)
+
+const (
+	clientKeysTTL          = 5 * time.Minute
+	clientKeysFetchTimeout = 5 * time.Second
+
+	// clientKeysMaxSize caps a fetched JWK Set; real sets are a few kilobytes
+	clientKeysMaxSize = 64 << 10
+)
+
+// clientKeyCache resolves the JWK Sets used to verify private_key_jwt client assertions.
+// Sets fetched from a jwks_uri are cached for clientKeysTTL to avoid a round trip per request.
+type clientKeyCache struct {
+	client  *http.Client
+	mu      sync.Mutex
+	entries map[string]clientKeyEntry
+}
+
+type clientKeyEntry struct {
+	set       jwk.Set
+	fetchedAt time.Time
+}
+
+func newClientKeyCache() *clientKeyCache {
+	return &clientKeyCache{
+		client:  safehttp.NewClient(clientKeysFetchTimeout),
+		entries: make(map[string]clientKeyEntry),
+	}
+}
+
+// keySet returns the client's inline JWK Set or the (possibly cached) set at its jwks_uri
+func (c *clientKeyCache) keySet(ctx context.Context, service *svc.Service) (jwk.Set, error) {
+	if service.JWKS != "" {
+		return jwk.ParseString(service.JWKS)
+	}
+	if service.JWKSURI == "" {
+		return nil, fmt.Errorf("client %s has no registered keys", service.ClientID)
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[service.JWKSURI]
+	c.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < clientKeysTTL {
+		return entry.set, nil
+	}
+
+	set, err := c.fetch(ctx, service.JWKSURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", service.JWKSURI, err)
+	}
+
+	c.mu.Lock()
+	c.entries[service.JWKSURI] = clientKeyEntry{set: set, fetchedAt: time.Now()}
+	c.mu.Unlock()
+
+	return set, nil
+}
+
+// fetch downloads and parses the JWK Set at uri, reading at most clientKeysMaxSize bytes
+func (c *clientKeyCache) fetch(ctx context.Context, uri string) (jwk.Set, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/jwk-set+json, application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, clientKeysMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > clientKeysMaxSize {
+		return nil, fmt.Errorf("JWK Set exceeds %d bytes", clientKeysMaxSize)
+	}
+
+	return jwk.Parse(body)
+}
@@ -0,0 +1,43 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/Anvoria/authly/internal/safehttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientKeyCacheFetch(t *testing.T) {
+	var body string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	service := &svc.Service{ClientID: "app", JWKSURI: srv.URL + "/jwks.json"}
+
+	t.Run("non-public addresses are refused", func(t *testing.T) {
+		body = `{"keys":[]}`
+		_, err := newClientKeyCache().keySet(context.Background(), service)
+		assert.ErrorIs(t, err, safehttp.ErrForbiddenAddress)
+	})
+
+	t.Run("oversized sets are refused", func(t *testing.T) {
+		cache := newClientKeyCache()
+		cache.client = srv.Client()
+
+		body = `{"keys":[],"padding":"` + strings.Repeat("x", clientKeysMaxSize) + `"}`
+		_, err := cache.keySet(context.Background(), service)
+		assert.ErrorContains(t, err, "exceeds")
+
+		body = `{"keys":[]}`
+		set, err := cache.keySet(context.Background(), service)
+		require.NoError(t, err)
+		assert.Equal(t, 0, set.Len())
+	})
+}
@@ -0,0 +1,29 @@
+package oidc
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConfirmAuthorization issues an authorization code for a request the user approved on the
+// consent screen and records the approved scopes, so later requests for them skip consent.
+// Only requests stored at validation are confirmed, so the browser cannot drop their requirements.
+func (s *Service) ConfirmAuthorization(req *AuthorizeRequest, userID, sessionID uuid.UUID, authTime time.Time) (*AuthorizeResponse, error) {
+	if req.RequestURI == "" {
+		return nil, ErrInvalidRequestURI
+	}
+
+	res, err := s.authorize(req, userID, sessionID, authTime, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// The code is already issued; failing to remember the approval only means asking again
+	if err := s.consentService.Grant(userID, res.ClientID, res.Scopes); err != nil {
+		slog.Warn("Failed to record consent", "error", err, "user_id", userID, "client_id", res.ClientID)
+	}
+
+	return res, nil
+}
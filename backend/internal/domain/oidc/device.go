@@ -0,0 +1,315 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// DeviceCodeGrantType is the grant_type used by devices polling the token endpoint (RFC 8628 Section 3.4)
+	DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+	deviceCodeLifetime    = 10 * time.Minute
+	deviceDefaultInterval = 5 // seconds
+	deviceSlowDownStep    = 5 // seconds added on slow_down (RFC 8628 Section 3.5)
+	deviceSessionTTL      = 168 * time.Hour
+
+	// userCodeAlphabet omits vowels and look-alike characters (RFC 8628 Section 6.1)
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+// DeviceAuthorization starts a device authorization grant by issuing a device_code/user_code pair
+func (s *Service) DeviceAuthorization(req *DeviceAuthorizationRequest) (*DeviceAuthorizationResponse, error) {
+	service, err := s.authenticateClient(req.credentials())
+	if err != nil {
+		return nil, err
+	}
+	if !service.AllowsGrantType(DeviceCodeGrantType) {
+		return nil, ErrUnauthorizedClient
+	}
+
+	requestedScopes := strings.Fields(req.Scope)
+	if len(requestedScopes) > 0 {
+		if !s.isValidScopes(service.AllowedScopes, requestedScopes) {
+			return nil, ErrInvalidScope
+		}
+	} else {
+		requestedScopes = service.AllowedScopes
+	}
+
+	deviceCode, err := s.generateAuthorizationCode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate device code: %w", err)
+	}
+
+	code := &DeviceCode{
+		DeviceCode: deviceCode,
+		ClientID:   service.ClientID,
+		Scopes:     strings.Join(requestedScopes, " "),
+		Status:     DeviceCodePending,
+		Interval:   deviceDefaultInterval,
+		ExpiresAt:  time.Now().Add(deviceCodeLifetime),
+	}
+
+	// User codes are short, so retry on the unlikely collision with an existing one
+	for attempt := 0; ; attempt++ {
+		code.UserCode, err = generateUserCode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate user code: %w", err)
+		}
+
+		err = s.codeRepo.CreateDeviceCode(code)
+		if err == nil {
+			break
+		}
+		if attempt == 2 {
+			return nil, fmt.Errorf("failed to save device code: %w", err)
+		}
+	}
+
+	verificationURI := s.authService.Issuer() + "/device"
+	userCode := formatUserCode(code.UserCode)
+
+	return &DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(userCode),
+		ExpiresIn:               int(deviceCodeLifetime.Seconds()),
+		Interval:                deviceDefaultInterval,
+	}, nil
+}
+
+// GetDeviceVerification describes the pending device authorization behind a user code so the
+// user can decide whether to approve it
+func (s *Service) GetDeviceVerification(userCode string) *DeviceVerificationResponse {
+	code, err := s.findPendingDeviceCode(userCode)
+	if err != nil {
+		oidcErr := MapErrorToOIDC(err)
+		return &DeviceVerificationResponse{
+			Valid:            false,
+			Error:            oidcErr.Code,
+			ErrorDescription: oidcErr.Description,
+		}
+	}
+
+	service, err := s.serviceRepo.FindByClientID(code.ClientID)
+	if err != nil {
+		return &DeviceVerificationResponse{
+			Valid:            false,
+			Error:            ErrorCodeInvalidClient,
+			ErrorDescription: "Client not found",
+		}
+	}
+
+	return &DeviceVerificationResponse{
+		Valid: true,
+		Client: &ClientInfo{
+			ID:            service.ClientID,
+			Name:          service.Name,
+			RedirectURIs:  service.RedirectURIs,
+			AllowedScopes: service.AllowedScopes,
+			Active:        service.Active,
+		},
+		Scopes: strings.Fields(code.Scopes),
+	}
+}
+
+// ConfirmDevice records the logged-in user's approval or denial of a device authorization
+func (s *Service) ConfirmDevice(userCode string, userID uuid.UUID, approve bool) error {
+	code, err := s.findPendingDeviceCode(userCode)
+	if err != nil {
+		return err
+	}
+
+	status := DeviceCodeDenied
+	if approve {
+		service, err := s.serviceRepo.FindByClientID(code.ClientID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidClientID
+			}
+			return fmt.Errorf("failed to find service: %w", err)
+		}
+
+		if !service.Active {
+			return ErrClientNotActive
+		}
+
+		if err := s.checkUserAccess(userID.String(), service); err != nil {
+			return err
+		}
+
+		status = DeviceCodeApproved
+	}
+
+	updated, err := s.codeRepo.UpdateDeviceCodeStatus(code.ID, DeviceCodePending, status, &userID)
+	if err != nil {
+		return fmt.Errorf("failed to update device code: %w", err)
+	}
+	if !updated {
+		return ErrInvalidUserCode
+	}
+
+	return nil
+}
+
+// DeviceCodeGrant handles a device polling the token endpoint (RFC 8628 Section 3.4)
+// It returns ErrAuthorizationPending until the user acts, ErrSlowDown when the device polls
+// faster than its interval, and tokens exactly once after approval.
+func (s *Service) DeviceCodeGrant(req *TokenRequest) (*TokenResponse, error) {
+	if req.GrantType != DeviceCodeGrantType {
+		return nil, ErrInvalidGrant
+	}
+
+	service, err := s.authenticateClient(req.credentials())
+	if err != nil {
+		return nil, err
+	}
+	if !service.AllowsGrantType(req.GrantType) {
+		return nil, ErrUnauthorizedClient
+	}
+
+	code, err := s.codeRepo.FindDeviceCode(req.DeviceCode)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidGrant
+		}
+		return nil, fmt.Errorf("failed to find device code: %w", err)
+	}
+
+	if code.ClientID != service.ClientID {
+		return nil, ErrInvalidGrant
+	}
+
+	now := time.Now()
+	if now.After(code.ExpiresAt) {
+		return nil, ErrExpiredToken
+	}
+
+	// Enforce the polling interval, widening it every time the device polls too early
+	interval, tooFast := pollInterval(code.LastPolledAt, code.Interval, now)
+	if err := s.codeRepo.UpdateDeviceCodePoll(code.ID, now, interval); err != nil {
+		return nil, fmt.Errorf("failed to record device poll: %w", err)
+	}
+	if tooFast {
+		return nil, ErrSlowDown
+	}
+
+	switch code.Status {
+	case DeviceCodePending:
+		return nil, ErrAuthorizationPending
+	case DeviceCodeDenied:
+		return nil, ErrAccessDenied
+	case DeviceCodeApproved:
+	default:
+		return nil, ErrInvalidGrant
+	}
+
+	if code.UserID == nil {
+		return nil, ErrInvalidGrant
+	}
+	userID := *code.UserID
+
+	resources, err := s.resolveResources(service, req.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	// Consume the approval atomically so concurrent polls cannot both receive tokens
+	consumed, err := s.codeRepo.UpdateDeviceCodeStatus(code.ID, DeviceCodeApproved, DeviceCodeConsumed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume device code: %w", err)
+	}
+	if !consumed {
+		return nil, ErrInvalidGrant
+	}
+
+	// Permissions may have changed since the user approved the device
+	if err := s.checkUserAccess(userID.String(), service); err != nil {
+		return nil, err
+	}
+
+	scopes := strings.Fields(code.Scopes)
+
+	// The device gets its own session so it can be listed and revoked independently of the browser
+	sessionID, secret, err := s.sessionService.Create(userID, req.UserAgent, req.IPAddress, scopes, deviceSessionTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return s.issueUserTokens(userID, sessionID, secret, service, scopes, resources, "", now, req.DPoPJKT)
+}
+
+// pollInterval checks a token endpoint poll against the interval the client must respect. Polling
+// too early widens the interval for all later polls (RFC 8628 Section 3.5).
+func pollInterval(lastPolledAt *time.Time, interval int, now time.Time) (int, bool) {
+	if lastPolledAt != nil && now.Sub(*lastPolledAt) < time.Duration(interval)*time.Second {
+		return interval + deviceSlowDownStep, true
+	}
+	return interval, false
+}
+
+// findPendingDeviceCode resolves a user-entered code to a device authorization still awaiting a decision
+func (s *Service) findPendingDeviceCode(userCode string) (*DeviceCode, error) {
+	normalized := normalizeUserCode(userCode)
+	if len(normalized) != userCodeLength {
+		return nil, ErrInvalidUserCode
+	}
+
+	code, err := s.codeRepo.FindDeviceCodeByUserCode(normalized)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidUserCode
+		}
+		return nil, fmt.Errorf("failed to find device code: %w", err)
+	}
+
+	if code.Status != DeviceCodePending {
+		return nil, ErrInvalidUserCode
+	}
+
+	return code, nil
+}
+
+// generateUserCode returns a random user code drawn from userCodeAlphabet
+func generateUserCode() (string, error) {
+	b := make([]byte, userCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	code := make([]byte, userCodeLength)
+	for i := range b {
+		// 256 is not a multiple of 20, but the resulting bias is negligible for a short-lived code
+		code[i] = userCodeAlphabet[int(b[i])%len(userCodeAlphabet)]
+	}
+
+	return string(code), nil
+}
+
+// formatUserCode splits a normalized user code in two halves for display, e.g. "BCDF-GHJK"
+func formatUserCode(code string) string {
+	half := len(code) / 2
+	return code[:half] + "-" + code[half:]
+}
+
+// normalizeUserCode makes user input case-insensitive and ignores separators and whitespace
+func normalizeUserCode(input string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(input) {
+		if r == '-' || r == ' ' || r == '\t' {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+	"github.com/Anvoria/authly/internal/domain/session"
+	"github.com/google/uuid"
+)
+
+// VerifyDPoP verifies the DPoP proof sent to the token endpoint and records the proof key's
+// thumbprint on the request, so that the grant binds the tokens it issues to that key (RFC 9449 Section 5)
+func (s *Service) VerifyDPoP(req *TokenRequest) error {
+	if req.DPoPProof == "" {
+		return nil
+	}
+
+	proof, err := s.authService.VerifyDPoP(req.DPoPProof, http.MethodPost, s.authService.Issuer()+"/v1/oauth/token", "")
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidDPoPProof) || errors.Is(err, auth.ErrDPoPProofReplayed) {
+			return ErrInvalidDPoPProof
+		}
+		return err
+	}
+
+	req.DPoPJKT = proof.JKT
+	return nil
+}
+
+// bindSessionDPoP binds a refresh session to the request's DPoP key, if any
+func (s *Service) bindSessionDPoP(sessionID uuid.UUID, jkt string) error {
+	if jkt == "" {
+		return nil
+	}
+
+	if err := s.sessionService.BindDPoP(sessionID, jkt); err != nil {
+		if errors.Is(err, session.ErrDPoPKeyMismatch) {
+			return ErrInvalidDPoPProof
+		}
+		return fmt.Errorf("failed to bind session to dpop key: %w", err)
+	}
+
+	return nil
+}
+
+// dpopOptions binds an access token to the request's DPoP key, if any
+func dpopOptions(jkt string) []auth.AccessTokenOption {
+	if jkt == "" {
+		return nil
+	}
+	return []auth.AccessTokenOption{auth.WithConfirmation(jkt)}
+}
+
+// tokenType returns the token_type for an access token bound to jkt ("DPoP") or unbound ("Bearer")
+func tokenType(jkt string) string {
+	if jkt != "" {
+		return auth.DPoPScheme
+	}
+	return "Bearer"
+}
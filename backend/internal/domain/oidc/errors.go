@@ -18,7 +18,15 @@ const (
 	ErrorCodeLoginRequired           = "login_required"
 	ErrorCodeInvalidRequestURI       = "invalid_request_uri"
 	ErrorCodeInteractionRequired     = "interaction_required"
+	ErrorCodeConsentRequired         = "consent_required"
 	ErrorCodeAccessDenied            = "access_denied"
+	ErrorCodeAuthorizationPending    = "authorization_pending"
+	ErrorCodeSlowDown                = "slow_down"
+	ErrorCodeExpiredToken            = "expired_token"
+	ErrorCodeInvalidTarget           = "invalid_target"
+	ErrorCodeInvalidDPoPProof        = "invalid_dpop_proof"
+	ErrorCodeUnknownUserID           = "unknown_user_id"
+	ErrorCodeInvalidBindingMessage   = "invalid_binding_message"
 )
 
 var (
@@ -63,6 +71,75 @@ var (
 
 	// ErrInvalidClientSecret is returned when the client authentication fails (e.g., invalid client_secret).
 	ErrInvalidClientSecret = errors.New("invalid_client_secret")
+
+	// ErrInvalidIDTokenHint is returned when the id_token_hint is not a token issued by this server or does not match the client.
+	ErrInvalidIDTokenHint = errors.New("invalid_id_token_hint")
+
+	// ErrInvalidPostLogoutRedirectURI is returned when the post_logout_redirect_uri is not registered for the client.
+	ErrInvalidPostLogoutRedirectURI = errors.New("invalid_post_logout_redirect_uri")
+
+	// ErrAuthorizationPending is returned while the user has not yet acted on a device authorization or backchannel authentication request.
+	ErrAuthorizationPending = errors.New("authorization_pending")
+
+	// ErrSlowDown is returned when a device polls the token endpoint faster than its interval allows.
+	ErrSlowDown = errors.New("slow_down")
+
+	// ErrExpiredToken is returned when the device code or backchannel authentication request has expired before the user approved it.
+	ErrExpiredToken = errors.New("expired_token")
+
+	// ErrAccessDenied is returned when the user rejected the device authorization or backchannel authentication request.
+	ErrAccessDenied = errors.New("access_denied")
+
+	// ErrInvalidUserCode is returned when the user code is unknown, expired, or already used.
+	ErrInvalidUserCode = errors.New("invalid_user_code")
+
+	// ErrUnsupportedTokenType is returned when a token exchange names a subject or requested token type other than an access token.
+	ErrUnsupportedTokenType = errors.New("unsupported_token_type")
+
+	// ErrInvalidTarget is returned when the requested audience is unknown, inactive, or not allowed for the caller.
+	ErrInvalidTarget = errors.New("invalid_target")
+
+	// ErrInvalidClientAssertion is returned when a client assertion JWT is malformed, unverifiable, expired, or replayed.
+	ErrInvalidClientAssertion = errors.New("invalid_client_assertion")
+
+	// ErrInvalidClientAuthMethod is returned when the client authenticated with a method other than its registered token_endpoint_auth_method.
+	ErrInvalidClientAuthMethod = errors.New("invalid_client_auth_method")
+
+	// ErrInvalidRequestURI is returned when a request_uri is unknown, expired, already used, or belongs to another client.
+	ErrInvalidRequestURI = errors.New("invalid_request_uri")
+
+	// ErrPushedAuthorizationRequired is returned when a client that requires PAR sends its authorization request through the browser.
+	ErrPushedAuthorizationRequired = errors.New("pushed_authorization_required")
+
+	// ErrUnsupportedResponseMode is returned when response_mode is not one of SupportedResponseModes.
+	ErrUnsupportedResponseMode = errors.New("unsupported_response_mode")
+
+	// ErrLoginRequired is returned when the request needs the user to authenticate (again) first.
+	ErrLoginRequired = errors.New("login_required")
+
+	// ErrConsentRequired is returned when the request needs user consent that cannot be asked for.
+	ErrConsentRequired = errors.New("consent_required")
+
+	// ErrInvalidPrompt is returned when prompt contains an unknown value or combines none with another value.
+	ErrInvalidPrompt = errors.New("invalid_prompt")
+
+	// ErrInvalidMaxAge is returned when max_age is not a non-negative integer.
+	ErrInvalidMaxAge = errors.New("invalid_max_age")
+
+	// ErrInvalidClaimsRequest is returned when the claims parameter is not a valid claims request object.
+	ErrInvalidClaimsRequest = errors.New("invalid_claims_request")
+
+	// ErrInvalidDPoPProof is returned when a DPoP proof is invalid or replayed, or does not match the key the refresh token is bound to.
+	ErrInvalidDPoPProof = errors.New("invalid_dpop_proof")
+
+	// ErrUnknownUserID is returned when the login_hint of a backchannel authentication request names no active user.
+	ErrUnknownUserID = errors.New("unknown_user_id")
+
+	// ErrInvalidBindingMessage is returned when the binding_message of a backchannel authentication request is too long or not printable.
+	ErrInvalidBindingMessage = errors.New("invalid_binding_message")
+
+	// ErrInvalidAuthRequest is returned when a backchannel authentication request is unknown, expired, already decided, or belongs to another user.
+	ErrInvalidAuthRequest = errors.New("invalid_auth_request")
 )
 
 // OIDCError represents a standardized OIDC protocol error.
@@ -75,11 +152,27 @@ type OIDCError struct {
 	StatusCode int
 }
 
+// Error implements error so that validation results already expressed as protocol errors
+// can be returned from the service layer unchanged
+func (e OIDCError) Error() string {
+	return e.Code
+}
+
 // MapErrorToOIDC maps an internal domain error to a standardized OIDC protocol error.
 // MapErrorToOIDC maps internal domain errors to their corresponding OIDC error responses.
 // MapErrorToOIDC maps an internal domain error to a standardized OIDC protocol error.
 // It returns an OIDCError with the OIDC error code, a client-facing description, and the corresponding HTTP status; unrecognized errors map to ErrorCodeServerError with HTTP 500.
 func MapErrorToOIDC(err error) OIDCError {
+	var oidcErr OIDCError
+	if errors.As(err, &oidcErr) {
+		return oidcErr
+	}
+
+	var redirectErr *AuthorizationRedirectError
+	if errors.As(err, &redirectErr) {
+		err = redirectErr.Err
+	}
+
 	switch err {
 	case ErrInvalidClientID:
 		return OIDCError{Code: ErrorCodeInvalidClient, Description: "Invalid client_id", StatusCode: http.StatusBadRequest}
@@ -107,6 +200,52 @@ func MapErrorToOIDC(err error) OIDCError {
 		return OIDCError{Code: ErrorCodeInvalidGrant, Description: "code_verifier is invalid", StatusCode: http.StatusBadRequest}
 	case ErrInvalidClientSecret:
 		return OIDCError{Code: ErrorCodeInvalidClient, Description: "Invalid client_secret", StatusCode: http.StatusUnauthorized}
+	case ErrInvalidClientAssertion:
+		return OIDCError{Code: ErrorCodeInvalidClient, Description: "Invalid client_assertion", StatusCode: http.StatusUnauthorized}
+	case ErrInvalidClientAuthMethod:
+		return OIDCError{Code: ErrorCodeInvalidClient, Description: "Client authentication method is not allowed for this client", StatusCode: http.StatusUnauthorized}
+	case ErrInvalidRequestURI:
+		return OIDCError{Code: ErrorCodeInvalidRequestURI, Description: "The request_uri is invalid or has expired", StatusCode: http.StatusBadRequest}
+	case ErrPushedAuthorizationRequired:
+		return OIDCError{Code: ErrorCodeInvalidRequest, Description: "This client must use pushed authorization requests", StatusCode: http.StatusBadRequest}
+	case ErrInvalidDPoPProof:
+		return OIDCError{Code: ErrorCodeInvalidDPoPProof, Description: "The DPoP proof is invalid", StatusCode: http.StatusBadRequest}
+	case ErrUnsupportedResponseMode:
+		return OIDCError{Code: ErrorCodeInvalidRequest, Description: "response_mode is not supported", StatusCode: http.StatusBadRequest}
+	case ErrLoginRequired:
+		return OIDCError{Code: ErrorCodeLoginRequired, Description: "The user must authenticate", StatusCode: http.StatusUnauthorized}
+	case ErrConsentRequired:
+		return OIDCError{Code: ErrorCodeConsentRequired, Description: "The user must consent to the requested scopes", StatusCode: http.StatusBadRequest}
+	case ErrInvalidPrompt:
+		return OIDCError{Code: ErrorCodeInvalidRequest, Description: "prompt is invalid", StatusCode: http.StatusBadRequest}
+	case ErrInvalidClaimsRequest:
+		return OIDCError{Code: ErrorCodeInvalidRequest, Description: "claims is not a valid claims request", StatusCode: http.StatusBadRequest}
+	case ErrInvalidMaxAge:
+		return OIDCError{Code: ErrorCodeInvalidRequest, Description: "max_age must be a non-negative integer", StatusCode: http.StatusBadRequest}
+	case ErrInvalidIDTokenHint:
+		return OIDCError{Code: ErrorCodeInvalidRequest, Description: "id_token_hint is invalid", StatusCode: http.StatusBadRequest}
+	case ErrInvalidPostLogoutRedirectURI:
+		return OIDCError{Code: ErrorCodeInvalidRequest, Description: "The post_logout_redirect_uri is not allowed for this client", StatusCode: http.StatusBadRequest}
+	case ErrAuthorizationPending:
+		return OIDCError{Code: ErrorCodeAuthorizationPending, Description: "The authorization request is still pending", StatusCode: http.StatusBadRequest}
+	case ErrSlowDown:
+		return OIDCError{Code: ErrorCodeSlowDown, Description: "Polling too frequently, increase the interval by 5 seconds", StatusCode: http.StatusBadRequest}
+	case ErrExpiredToken:
+		return OIDCError{Code: ErrorCodeExpiredToken, Description: "The device_code or auth_req_id has expired", StatusCode: http.StatusBadRequest}
+	case ErrAccessDenied:
+		return OIDCError{Code: ErrorCodeAccessDenied, Description: "The user denied the authorization request", StatusCode: http.StatusBadRequest}
+	case ErrInvalidUserCode:
+		return OIDCError{Code: ErrorCodeInvalidRequest, Description: "The user code is invalid or has expired", StatusCode: http.StatusBadRequest}
+	case ErrUnknownUserID:
+		return OIDCError{Code: ErrorCodeUnknownUserID, Description: "The login_hint does not identify a known user", StatusCode: http.StatusBadRequest}
+	case ErrInvalidBindingMessage:
+		return OIDCError{Code: ErrorCodeInvalidBindingMessage, Description: "The binding_message is invalid", StatusCode: http.StatusBadRequest}
+	case ErrInvalidAuthRequest:
+		return OIDCError{Code: ErrorCodeInvalidRequest, Description: "The authentication request is invalid or has expired", StatusCode: http.StatusBadRequest}
+	case ErrUnsupportedTokenType:
+		return OIDCError{Code: ErrorCodeInvalidRequest, Description: "Only access tokens can be exchanged", StatusCode: http.StatusBadRequest}
+	case ErrInvalidTarget:
+		return OIDCError{Code: ErrorCodeInvalidTarget, Description: "The requested audience or resource is invalid or not allowed for this client", StatusCode: http.StatusBadRequest}
 	case ErrUserAccessDenied:
 		return OIDCError{Code: ErrorCodeAccessDenied, Description: "User is not authorized to access this service", StatusCode: http.StatusForbidden}
 	default:
@@ -0,0 +1,180 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+	"gorm.io/gorm"
+)
+
+const (
+	// TokenExchangeGrantType is the grant_type for OAuth 2.0 Token Exchange (RFC 8693)
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	// AccessTokenType identifies an access token in token exchange requests and responses
+	AccessTokenType = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// TokenExchangeGrant exchanges a user's access token held by a backend service for a new access
+// token addressed to another service (RFC 8693 delegation). The issued token keeps the user as
+// subject, records the calling service in the "act" claim, and only carries the permissions that
+// both the user holds and the caller was granted for the target service.
+func (s *Service) TokenExchangeGrant(req *TokenRequest) (*TokenResponse, error) {
+	if req.GrantType != TokenExchangeGrantType {
+		return nil, ErrInvalidGrant
+	}
+
+	// Delegation is limited to confidential clients, whose ServicePermission grants bound the result
+	caller, err := s.authenticateConfidentialClient(req.credentials())
+	if err != nil {
+		return nil, err
+	}
+	if !caller.AllowsGrantType(req.GrantType) {
+		return nil, ErrUnauthorizedClient
+	}
+
+	if req.SubjectTokenType != AccessTokenType {
+		return nil, ErrUnsupportedTokenType
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != AccessTokenType {
+		return nil, ErrUnsupportedTokenType
+	}
+
+	subject, err := s.verifySubjectToken(req.SubjectToken, caller.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := s.serviceRepo.FindByClientID(req.Audience)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidTarget
+		}
+		return nil, fmt.Errorf("failed to find target service: %w", err)
+	}
+	if !target.Active {
+		return nil, ErrInvalidTarget
+	}
+
+	// Scopes can only be narrowed, never widened beyond the subject token
+	subjectScopes := subject.GetRequestedScopes()
+	scopes := subjectScopes
+	if req.Scope != "" {
+		scopes = strings.Fields(req.Scope)
+		if !s.isValidScopes(subjectScopes, scopes) {
+			return nil, ErrInvalidScope
+		}
+	}
+
+	userID, err := s.authService.ResolveSubject(subject.Subject())
+	if err != nil {
+		return nil, ErrInvalidGrant
+	}
+
+	// The target knows the user by its own subject identifier, not the caller's
+	sub, err := s.subjectFor(userID, target)
+	if err != nil {
+		return nil, err
+	}
+
+	userPermissions, err := s.permissionService.BuildScopes(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build permissions: %w", err)
+	}
+
+	callerPermissions, err := s.permissionService.BuildServiceScopes(caller.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build service permissions: %w", err)
+	}
+
+	callerTargetPermissions := s.filterPermissionsForClient(callerPermissions, target.ClientID)
+	if len(callerTargetPermissions) == 0 {
+		// The caller was never granted anything on the target, so it may not call it on the user's behalf
+		return nil, ErrInvalidTarget
+	}
+
+	permissions := intersectPermissions(s.filterPermissionsForClient(userPermissions, target.ClientID), callerTargetPermissions)
+
+	// Chain delegation so the target can see every hop (RFC 8693 Section 4.1)
+	act := map[string]any{"sub": caller.ClientID}
+	if prior := subject.GetActor(); prior != nil {
+		act["act"] = prior
+	}
+
+	accessToken, err := s.authService.GenerateAccessToken(
+		sub,
+		subject.GetSid(),
+		scopes,
+		caller.ClientID,
+		target.ClientID,
+		permissions,
+		subject.GetPermissionV(),
+		append(dpopOptions(req.DPoPJKT), auth.WithActor(act), auth.WithAuthentication(subject.AuthTime(), subject.ACR(), subject.AMR()))...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	return &TokenResponse{
+		AccessToken:     accessToken,
+		IssuedTokenType: AccessTokenType,
+		TokenType:       tokenType(req.DPoPJKT),
+		ExpiresIn:       900, // 15 minutes in seconds
+		Scope:           strings.Join(scopes, " "),
+	}, nil
+}
+
+// verifySubjectToken checks that the subject token is a live user access token issued to the caller
+func (s *Service) verifySubjectToken(token, callerClientID string) (*auth.AccessTokenClaims, error) {
+	claims, err := s.authService.KeyStore.Verify(token)
+	if err != nil {
+		return nil, ErrInvalidGrant
+	}
+
+	if err := claims.Validate(s.authService.Issuer(), []string{callerClientID}); err != nil {
+		return nil, ErrInvalidGrant
+	}
+
+	// Client credentials tokens have no user to delegate for
+	if claims.GetSid() == "service-session" {
+		return nil, ErrInvalidGrant
+	}
+
+	revoked, err := s.authService.IsTokenRevoked(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		return nil, ErrInvalidGrant
+	}
+
+	userID, err := s.authService.ResolveSubject(claims.Subject())
+	if err != nil {
+		return nil, ErrInvalidGrant
+	}
+
+	pver, err := s.permissionService.GetPermissionVersion(userID)
+	if err != nil {
+		slog.Warn("Failed to get permission version during token exchange", "error", err, "sub", claims.Subject())
+		return nil, ErrInvalidGrant
+	}
+	if pver != claims.GetPermissionV() {
+		return nil, ErrInvalidGrant
+	}
+
+	return claims, nil
+}
+
+// intersectPermissions keeps only the permission bits present in both maps for the same scope key
+func intersectPermissions(a, b map[string]uint64) map[string]uint64 {
+	result := make(map[string]uint64)
+	for key, mask := range a {
+		if shared := mask & b[key]; shared != 0 {
+			result[key] = shared
+		}
+	}
+	return result
+}
@@ -0,0 +1,30 @@
+package oidc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntersectPermissions(t *testing.T) {
+	user := map[string]uint64{
+		"billing":         0b1111,
+		"billing:invoice": 0b0011,
+		"reports":         0b0001,
+	}
+	caller := map[string]uint64{
+		"billing":         0b0101,
+		"billing:invoice": 0b0100,
+	}
+
+	result := intersectPermissions(user, caller)
+
+	assert.Equal(t, map[string]uint64{"billing": 0b0101}, result)
+}
+
+func TestIntersectPermissions_NoOverlap(t *testing.T) {
+	result := intersectPermissions(map[string]uint64{"billing": 1}, map[string]uint64{})
+
+	assert.NotNil(t, result)
+	assert.Empty(t, result)
+}
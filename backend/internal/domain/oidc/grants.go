@@ -7,14 +7,19 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Anvoria/authly/internal/domain/auth"
 	"github.com/Anvoria/authly/internal/domain/session"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-// ExchangeCode exchanges an authorization code for access and refresh tokens
-// sessionID and refreshSecret should come from the existing session cookie
-func (s *Service) ExchangeCode(req *TokenRequest, sessionID uuid.UUID, refreshSecret string) (*TokenResponse, error) {
+// clientSessionTTL is the lifetime of the session backing a client's refresh token
+const clientSessionTTL = 168 * time.Hour
+
+// ExchangeCode exchanges an authorization code for access and refresh tokens. The refresh token
+// belongs to a new session of the client under the browser session the code was issued in, so
+// clients redeem codes without the user's cookie and never rotate each other's refresh tokens.
+func (s *Service) ExchangeCode(req *TokenRequest) (*TokenResponse, error) {
 	// Validate grant_type
 	if req.GrantType != "authorization_code" {
 		return nil, ErrInvalidGrant
@@ -39,14 +44,15 @@ func (s *Service) ExchangeCode(req *TokenRequest, sessionID uuid.UUID, refreshSe
 		return nil, ErrInvalidCode
 	}
 
-	// Find service by client_id
-	service, err := s.serviceRepo.FindByClientID(req.ClientID)
+	// Authenticate the client with its registered method
+	service, err := s.authenticateClient(req.credentials())
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrInvalidClientID
-		}
-		return nil, fmt.Errorf("failed to find service: %w", err)
+		return nil, err
 	}
+	if !service.AllowsGrantType(req.GrantType) {
+		return nil, ErrUnauthorizedClient
+	}
+	req.ClientID = service.ClientID
 
 	// Validate client_id matches
 	if authCode.ClientID != req.ClientID {
@@ -58,13 +64,6 @@ func (s *Service) ExchangeCode(req *TokenRequest, sessionID uuid.UUID, refreshSe
 		return nil, ErrInvalidRedirectURI
 	}
 
-	// Validate client_secret for confidential clients
-	if service.ClientSecret != "" {
-		if req.ClientSecret == "" || service.ClientSecret != req.ClientSecret {
-			return nil, ErrInvalidClientSecret
-		}
-	}
-
 	// Validate PKCE if code_challenge was provided
 	if authCode.CodeChallenge != "" {
 		if req.CodeVerifier == "" {
@@ -77,27 +76,14 @@ func (s *Service) ExchangeCode(req *TokenRequest, sessionID uuid.UUID, refreshSe
 		}
 	}
 
-	sess, err := s.sessionService.Validate(sessionID, refreshSecret)
-	if err != nil {
-		return nil, fmt.Errorf("invalid session: %w", err)
-	}
-
-	userIDFromSession, err := uuid.Parse(sess.UserID)
-	if err != nil {
-		return nil, fmt.Errorf("invalid session user ID: %w", err)
-	}
-
-	if userIDFromSession != authCode.UserID {
-		return nil, fmt.Errorf("session user mismatch: session belongs to different user")
+	// Check if user has any permissions for this service
+	if err := s.checkUserAccess(authCode.UserID.String(), service); err != nil {
+		return nil, err
 	}
 
-	// Check if user has any permissions for this service
-	hasPerm, err := s.permissionService.HasAnyPermission(authCode.UserID.String(), service.ID.String())
+	resources, err := s.narrowResources(service, authCode.Resources, req.Resource)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check user permissions: %w", err)
-	}
-	if !hasPerm {
-		return nil, ErrUserAccessDenied
+		return nil, err
 	}
 
 	// Mark code as used
@@ -110,25 +96,65 @@ func (s *Service) ExchangeCode(req *TokenRequest, sessionID uuid.UUID, refreshSe
 
 	oidcScopes := strings.Fields(authCode.Scopes)
 
-	// Update session with granted scopes (binds session to these OIDC scopes)
-	if err := s.sessionService.UpdateScopes(sessionID, oidcScopes); err != nil {
-		return nil, fmt.Errorf("failed to update session scopes: %w", err)
+	claims, err := parseClaimsRequest(authCode.Claims)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stored claims request: %w", err)
+	}
+	userInfoClaims, err := claims.userInfoRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	// Codes of a browser session that was logged out in the meantime are no longer redeemable
+	sessionID, refreshSecret, err := s.sessionService.CreateChild(authCode.SessionID, authCode.UserID, req.UserAgent, req.IPAddress, oidcScopes, clientSessionTTL)
+	if err != nil {
+		if errors.Is(err, session.ErrInvalidSession) || errors.Is(err, session.ErrExpiredSession) {
+			return nil, ErrInvalidGrant
+		}
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	// The refresh token handed out is the client's session, so a DPoP client binds the session
+	if err := s.bindSessionDPoP(sessionID, req.DPoPJKT); err != nil {
+		return nil, err
+	}
+
+	// Tokens refreshed from the session keep answering the userinfo claims request
+	if err := s.sessionService.UpdateRequestedClaims(sessionID, userInfoClaims); err != nil {
+		return nil, fmt.Errorf("failed to update session claims: %w", err)
+	}
+	if err := s.sessionService.UpdateResources(sessionID, resources); err != nil {
+		return nil, fmt.Errorf("failed to update session resources: %w", err)
+	}
+
+	sub, err := s.subjectFor(authCode.UserID.String(), service)
+	if err != nil {
+		return nil, err
 	}
 
 	// Generate ID Token if openid scope is present
 	var idToken string
 	if slices.Contains(oidcScopes, "openid") {
-		userInfo, err := s.GetUserInfo(authCode.UserID.String(), oidcScopes)
+		// A claims request for the ID token replaces the scope claims with exactly the requested ones
+		idTokenScopes, idTokenClaims := oidcScopes, map[string]*ClaimRequest(nil)
+		if claims != nil && claims.IDToken != nil {
+			idTokenScopes, idTokenClaims = nil, claims.IDToken
+		}
+
+		userInfo, err := s.userClaims(authCode.UserID.String(), sub, idTokenScopes, idTokenClaims)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get user info for id token: %w", err)
 		}
+		// sid lets the relying party correlate back-channel logout tokens with this login
+		userInfo["sid"] = authCode.SessionID.String()
 
 		idToken, err = s.authService.GenerateIDToken(
-			authCode.UserID.String(),
+			sub,
 			req.ClientID,
 			authCode.Nonce,
-			sess.CreatedAt,
+			authCode.AuthTime,
 			userInfo,
+			service.IDTokenSignedResponseAlg,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate id token: %w", err)
@@ -140,30 +166,33 @@ func (s *Service) ExchangeCode(req *TokenRequest, sessionID uuid.UUID, refreshSe
 		return nil, fmt.Errorf("failed to build permissions: %w", err)
 	}
 
-	clientPermissions := s.filterPermissionsForClient(permissions, req.ClientID)
+	// The token carries the permissions of every resource server it is addressed to
+	clientPermissions := s.filterPermissionsForClient(permissions, audienceOf(req.ClientID, resources)...)
 
 	pver, err := s.permissionService.GetPermissionVersion(authCode.UserID.String())
 	if err != nil {
 		pver = 1
 	}
 
-	audience := req.ClientID
-
 	accessToken, err := s.authService.GenerateAccessToken(
-		authCode.UserID.String(),
+		sub,
 		sessionID.String(),
 		oidcScopes,
-		audience,
+		req.ClientID,
+		req.ClientID,
 		clientPermissions,
 		pver,
+		append(dpopOptions(req.DPoPJKT), auth.WithRequestedClaims(userInfoClaims), auth.WithResources(resources), passwordAuthentication(authCode.AuthTime))...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
+	s.trackSessionClient(sessionID, req.ClientID)
+
 	return &TokenResponse{
 		AccessToken:  accessToken,
-		TokenType:    "Bearer",
+		TokenType:    tokenType(req.DPoPJKT),
 		ExpiresIn:    900, // 15 minutes in seconds
 		RefreshToken: fmt.Sprintf("%s:%s", sessionID.String(), refreshSecret),
 		Scope:        authCode.Scopes,
@@ -191,36 +220,30 @@ func (s *Service) RefreshToken(req *TokenRequest) (*TokenResponse, error) {
 		return nil, ErrInvalidGrant
 	}
 
-	// Find service by client_id
-	service, err := s.serviceRepo.FindByClientID(req.ClientID)
+	// Authenticate the client with its registered method
+	service, err := s.authenticateClient(req.credentials())
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrInvalidClientID
-		}
-		return nil, fmt.Errorf("failed to find service: %w", err)
-	}
-
-	// Check if service is active
-	if !service.Active {
-		return nil, ErrClientNotActive
+		return nil, err
 	}
-
-	// Validate client_secret for confidential clients
-	if service.ClientSecret != "" {
-		if req.ClientSecret == "" || service.ClientSecret != req.ClientSecret {
-			return nil, ErrInvalidClientSecret
-		}
+	if !service.AllowsGrantType(req.GrantType) {
+		return nil, ErrUnauthorizedClient
 	}
+	req.ClientID = service.ClientID
 
 	// Validate session first to get UserID and GrantedScopes
 	sess, err := s.sessionService.Validate(sessionID, refreshSecret)
 	if err != nil {
-		if errors.Is(err, session.ErrInvalidSession) || errors.Is(err, session.ErrInvalidSecret) || errors.Is(err, session.ErrExpiredSession) {
+		if errors.Is(err, session.ErrInvalidSession) || errors.Is(err, session.ErrInvalidSecret) || errors.Is(err, session.ErrExpiredSession) || errors.Is(err, session.ErrReplayDetected) {
 			return nil, ErrInvalidGrant
 		}
 		return nil, fmt.Errorf("failed to validate session: %w", err)
 	}
 
+	// A DPoP-bound refresh token is only usable with a proof from the same key (RFC 9449 Section 5)
+	if sess.DPoPJKT != "" && sess.DPoPJKT != req.DPoPJKT {
+		return nil, ErrInvalidDPoPProof
+	}
+
 	// Validate Scopes against originally granted scopes (RFC 6749 Section 6)
 	var requestedScopes []string
 	grantedScopes := strings.Fields(sess.GrantedScopes)
@@ -242,23 +265,21 @@ func (s *Service) RefreshToken(req *TokenRequest) (*TokenResponse, error) {
 	}
 
 	// Check if user still has permissions for this service
-	hasPerm, err := s.permissionService.HasAnyPermission(userID.String(), service.ID.String())
-	if err != nil {
-		return nil, fmt.Errorf("failed to check user permissions: %w", err)
+	if err := s.checkUserAccess(userID.String(), service); err != nil {
+		return nil, err
 	}
-	if !hasPerm {
-		return nil, ErrUserAccessDenied
+
+	// Like scopes, resources can be narrowed to a subset of the original grant
+	resources, err := s.narrowResources(service, sess.Resources, req.Resource)
+	if err != nil {
+		return nil, err
 	}
 
 	// Rotate session (Refresh Token Rotation)
 	// We use a default TTL of 7 days (168 hours) for refreshed sessions
-	newSecret, err := s.sessionService.Rotate(sessionID, refreshSecret, 168*time.Hour)
+	// Reusing a retired refresh token revokes the session inside Rotate
+	newSecret, err := s.sessionService.Rotate(sessionID, refreshSecret, clientSessionTTL)
 	if err != nil {
-		if errors.Is(err, session.ErrReplayDetected) {
-			// Revoke session if replay detected
-			_ = s.sessionService.Revoke(sessionID)
-			return nil, ErrInvalidGrant
-		}
 		return nil, ErrInvalidGrant
 	}
 
@@ -267,30 +288,37 @@ func (s *Service) RefreshToken(req *TokenRequest) (*TokenResponse, error) {
 		return nil, fmt.Errorf("failed to build permissions: %w", err)
 	}
 
-	clientPermissions := s.filterPermissionsForClient(permissions, req.ClientID)
+	clientPermissions := s.filterPermissionsForClient(permissions, audienceOf(req.ClientID, resources)...)
 
 	pver, err := s.permissionService.GetPermissionVersion(userID.String())
 	if err != nil {
 		pver = 1
 	}
 
-	audience := req.ClientID
+	sub, err := s.subjectFor(userID.String(), service)
+	if err != nil {
+		return nil, err
+	}
 
 	accessToken, err := s.authService.GenerateAccessToken(
-		userID.String(),
+		sub,
 		sessionID.String(),
 		requestedScopes,
-		audience,
+		req.ClientID,
+		req.ClientID,
 		clientPermissions,
 		pver,
+		append(dpopOptions(req.DPoPJKT), auth.WithRequestedClaims(sess.RequestedClaims), auth.WithResources(resources), passwordAuthentication(sess.AuthTime))...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
+	s.trackSessionClient(sessionID, req.ClientID)
+
 	return &TokenResponse{
 		AccessToken:  accessToken,
-		TokenType:    "Bearer",
+		TokenType:    tokenType(req.DPoPJKT),
 		ExpiresIn:    900, // 15 minutes in seconds
 		RefreshToken: fmt.Sprintf("%s:%s", sessionID.String(), newSecret),
 		Scope:        strings.Join(requestedScopes, " "),
@@ -303,24 +331,15 @@ func (s *Service) ClientCredentialsGrant(req *TokenRequest) (*TokenResponse, err
 		return nil, ErrInvalidGrant
 	}
 
-	// Find service by client_id
-	service, err := s.serviceRepo.FindByClientID(req.ClientID)
+	// Client authentication is mandatory for client_credentials
+	service, err := s.authenticateConfidentialClient(req.credentials())
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrInvalidClientID
-		}
-		return nil, fmt.Errorf("failed to find service: %w", err)
+		return nil, err
 	}
-
-	// Check if service is active
-	if !service.Active {
-		return nil, ErrClientNotActive
-	}
-
-	// Validate client_secret (mandatory for client_credentials)
-	if service.ClientSecret == "" || req.ClientSecret != service.ClientSecret {
-		return nil, ErrInvalidClientSecret
+	if !service.AllowsGrantType(req.GrantType) {
+		return nil, ErrUnauthorizedClient
 	}
+	req.ClientID = service.ClientID
 
 	// Validate scopes
 	// For client_credentials, scopes must be pre-registered (AllowedScopes)
@@ -334,6 +353,11 @@ func (s *Service) ClientCredentialsGrant(req *TokenRequest) (*TokenResponse, err
 		requestedScopes = service.AllowedScopes
 	}
 
+	resources, err := s.resolveResources(service, req.Resource)
+	if err != nil {
+		return nil, err
+	}
+
 	// For Client Credentials, the "user" is the Service itself.
 	// We use the Service ID as the Subject (sub).
 	subject := service.ID.String()
@@ -345,6 +369,11 @@ func (s *Service) ClientCredentialsGrant(req *TokenRequest) (*TokenResponse, err
 		return nil, fmt.Errorf("failed to build service permissions: %w", err)
 	}
 
+	// A token addressed to specific resource servers only carries their permissions
+	if len(resources) > 0 {
+		permissions = s.filterPermissionsForClient(permissions, audienceOf(req.ClientID, resources)...)
+	}
+
 	// Client Credentials tokens usually don't have Refresh Tokens.
 
 	accessToken, err := s.authService.GenerateAccessToken(
@@ -352,8 +381,10 @@ func (s *Service) ClientCredentialsGrant(req *TokenRequest) (*TokenResponse, err
 		"service-session", // No interactive session
 		requestedScopes,
 		req.ClientID,
+		req.ClientID,
 		permissions,
 		1,
+		append(dpopOptions(req.DPoPJKT), auth.WithResources(resources))...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
@@ -361,7 +392,7 @@ func (s *Service) ClientCredentialsGrant(req *TokenRequest) (*TokenResponse, err
 
 	return &TokenResponse{
 		AccessToken: accessToken,
-		TokenType:   "Bearer",
+		TokenType:   tokenType(req.DPoPJKT),
 		ExpiresIn:   3600, // 1 hour
 		Scope:       strings.Join(requestedScopes, " "),
 	}, nil
@@ -393,25 +424,14 @@ func (s *Service) PasswordGrant(req *TokenRequest) (*TokenResponse, error) {
 	}
 
 	// Validate Client
-	service, err := s.serviceRepo.FindByClientID(req.ClientID)
+	service, err := s.authenticateClient(req.credentials())
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrInvalidClientID
-		}
-		return nil, fmt.Errorf("failed to find service: %w", err)
+		return nil, err
 	}
-
-	// Check if service is active
-	if !service.Active {
-		return nil, ErrClientNotActive
-	}
-
-	// Validate client_secret for confidential clients
-	if service.ClientSecret != "" {
-		if req.ClientSecret == "" || service.ClientSecret != req.ClientSecret {
-			return nil, ErrInvalidClientSecret
-		}
+	if !service.AllowsGrantType(req.GrantType) {
+		return nil, ErrUnauthorizedClient
 	}
+	req.ClientID = service.ClientID
 
 	// Validate Scopes
 	requestedScopes := strings.Fields(req.Scope)
@@ -424,12 +444,13 @@ func (s *Service) PasswordGrant(req *TokenRequest) (*TokenResponse, error) {
 	}
 
 	// Check if user has any permissions for this service
-	hasPerm, err := s.permissionService.HasAnyPermission(u.ID.String(), service.ID.String())
-	if err != nil {
-		return nil, fmt.Errorf("failed to check user permissions: %w", err)
+	if err := s.checkUserAccess(u.ID.String(), service); err != nil {
+		return nil, err
 	}
-	if !hasPerm {
-		return nil, ErrUserAccessDenied
+
+	resources, err := s.resolveResources(service, req.Resource)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create a new session (Password grant acts like a login)
@@ -437,20 +458,36 @@ func (s *Service) PasswordGrant(req *TokenRequest) (*TokenResponse, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
+	if err := s.bindSessionDPoP(sessionID, req.DPoPJKT); err != nil {
+		return nil, err
+	}
+	if err := s.sessionService.UpdateResources(sessionID, resources); err != nil {
+		return nil, fmt.Errorf("failed to update session resources: %w", err)
+	}
+	sub, err := s.subjectFor(u.ID.String(), service)
+	if err != nil {
+		return nil, err
+	}
+
+	// The password grant authenticates the user right now
+	authTime := time.Now()
+
 	// Generate ID Token if openid scope is present
 	var idToken string
 	if slices.Contains(requestedScopes, "openid") {
-		userInfo, err := s.GetUserInfo(u.ID.String(), requestedScopes)
+		userInfo, err := s.userClaims(u.ID.String(), sub, requestedScopes, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get user info: %w", err)
 		}
+		userInfo["sid"] = sessionID.String()
 
 		idToken, err = s.authService.GenerateIDToken(
-			u.ID.String(),
+			sub,
 			req.ClientID,
 			"", // No nonce in password flow
-			time.Now(),
+			authTime,
 			userInfo,
+			service.IDTokenSignedResponseAlg,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate id token: %w", err)
@@ -462,7 +499,7 @@ func (s *Service) PasswordGrant(req *TokenRequest) (*TokenResponse, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to build permissions: %w", err)
 	}
-	clientPermissions := s.filterPermissionsForClient(permissions, req.ClientID)
+	clientPermissions := s.filterPermissionsForClient(permissions, audienceOf(req.ClientID, resources)...)
 
 	pver, err := s.permissionService.GetPermissionVersion(u.ID.String())
 	if err != nil {
@@ -470,20 +507,24 @@ func (s *Service) PasswordGrant(req *TokenRequest) (*TokenResponse, error) {
 	}
 
 	accessToken, err := s.authService.GenerateAccessToken(
-		u.ID.String(),
+		sub,
 		sessionID.String(),
 		requestedScopes,
 		req.ClientID,
+		req.ClientID,
 		clientPermissions,
 		pver,
+		append(dpopOptions(req.DPoPJKT), auth.WithResources(resources), passwordAuthentication(authTime))...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
+	s.trackSessionClient(sessionID, req.ClientID)
+
 	return &TokenResponse{
 		AccessToken:  accessToken,
-		TokenType:    "Bearer",
+		TokenType:    tokenType(req.DPoPJKT),
 		ExpiresIn:    900,
 		RefreshToken: fmt.Sprintf("%s:%s", sessionID.String(), secret),
 		Scope:        strings.Join(requestedScopes, " "),
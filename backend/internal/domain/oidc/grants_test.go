@@ -0,0 +1,154 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/Anvoria/authly/internal/domain/session"
+	"github.com/Anvoria/authly/internal/domain/subject"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// codeStore keeps authorization codes in memory
+type codeStore struct {
+	Repository
+	codes map[string]*AuthorizationCode
+}
+
+func (r *codeStore) FindByCode(code string) (*AuthorizationCode, error) {
+	if authCode, ok := r.codes[code]; ok {
+		copied := *authCode
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *codeStore) MarkAsUsed(code string) error {
+	authCode, ok := r.codes[code]
+	if !ok || authCode.Used {
+		return gorm.ErrRecordNotFound
+	}
+	authCode.Used = true
+	return nil
+}
+
+// browserSessions issues child sessions only under browser sessions that are still active
+type browserSessions struct {
+	session.Service
+	active   map[uuid.UUID]bool
+	children map[uuid.UUID]uuid.UUID
+}
+
+func (s *browserSessions) CreateChild(parentID, userID uuid.UUID, userAgent, ip string, scopes []string, ttl time.Duration) (uuid.UUID, string, error) {
+	if !s.active[parentID] {
+		return uuid.Nil, "", session.ErrInvalidSession
+	}
+	id := uuid.New()
+	s.children[id] = parentID
+	return id, "secret-" + id.String(), nil
+}
+
+func (s *browserSessions) UpdateRequestedClaims(uuid.UUID, string) error {
+	return nil
+}
+
+func (s *browserSessions) UpdateResources(uuid.UUID, []string) error {
+	return nil
+}
+
+func (s *browserSessions) TrackClient(uuid.UUID, string) error {
+	return nil
+}
+
+func TestExchangeCode(t *testing.T) {
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	key, err := auth.NewSigningKey("test", raw, "RS256")
+	require.NoError(t, err)
+	keyStore, err := auth.NewKeyStore("test", []auth.SigningKey{key}, nil, nil)
+	require.NoError(t, err)
+	authService := auth.NewService(nil, nil, nil, nil, nil, keyStore, subject.NewService(nil, ""), "https://auth.example.com", nil, nil)
+
+	clients := []*svc.Service{
+		{ClientID: "web", ClientSecret: "web-secret", TokenEndpointAuthMethod: svc.AuthMethodClientSecretPost, Active: true},
+		{ClientID: "cli", ClientSecret: "cli-secret", TokenEndpointAuthMethod: svc.AuthMethodClientSecretPost, Active: true},
+	}
+	codes := &codeStore{codes: map[string]*AuthorizationCode{}}
+	sessions := &browserSessions{active: map[uuid.UUID]bool{}, children: map[uuid.UUID]uuid.UUID{}}
+	s := &Service{
+		serviceRepo:       &serviceDirectory{services: clients},
+		codeRepo:          codes,
+		authService:       authService,
+		sessionService:    sessions,
+		permissionService: allowAll{},
+	}
+
+	userID := uuid.New()
+	browserSession := uuid.New()
+	sessions.active[browserSession] = true
+
+	issue := func(clientID string) string {
+		code := uuid.NewString()
+		codes.codes[code] = &AuthorizationCode{
+			Code:        code,
+			ClientID:    clientID,
+			UserID:      userID,
+			SessionID:   browserSession,
+			AuthTime:    time.Now(),
+			RedirectURI: "https://" + clientID + ".example.com/callback",
+			Scopes:      "profile",
+			ExpiresAt:   time.Now().Add(time.Minute),
+		}
+		return code
+	}
+	// Token requests come from the client's back end, which never sees the user's session cookie
+	redeem := func(clientID, code string) (*TokenResponse, error) {
+		return s.ExchangeCode(&TokenRequest{
+			GrantType:        "authorization_code",
+			Code:             code,
+			RedirectURI:      "https://" + clientID + ".example.com/callback",
+			ClientID:         clientID,
+			ClientSecret:     clientID + "-secret",
+			ClientAuthMethod: svc.AuthMethodClientSecretPost,
+		})
+	}
+	sessionOf := func(res *TokenResponse) uuid.UUID {
+		id, err := uuid.Parse(strings.SplitN(res.RefreshToken, ":", 2)[0])
+		require.NoError(t, err)
+		return id
+	}
+
+	t.Run("each client redeems into its own session under the browser session", func(t *testing.T) {
+		web, err := redeem("web", issue("web"))
+		require.NoError(t, err)
+		cli, err := redeem("cli", issue("cli"))
+		require.NoError(t, err)
+
+		webSession, cliSession := sessionOf(web), sessionOf(cli)
+		assert.NotEqual(t, webSession, cliSession, "clients never rotate each other's refresh tokens")
+		assert.NotEqual(t, browserSession, webSession)
+		assert.Equal(t, browserSession, sessions.children[webSession])
+		assert.Equal(t, browserSession, sessions.children[cliSession])
+
+		claims, err := keyStore.Verify(web.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, webSession.String(), claims.GetSid())
+	})
+
+	t.Run("codes are not redeemable after the browser session ended", func(t *testing.T) {
+		code := issue("web")
+		sessions.active[browserSession] = false
+		defer func() { sessions.active[browserSession] = true }()
+
+		_, err := redeem("web", code)
+		assert.ErrorIs(t, err, ErrInvalidGrant)
+	})
+}
@@ -1,11 +1,15 @@
 package oidc
 
 import (
+	"encoding/base64"
+	"errors"
 	"log/slog"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/Anvoria/authly/internal/domain/auth"
+	svc "github.com/Anvoria/authly/internal/domain/service"
 
 	"github.com/Anvoria/authly/internal/utils"
 	"github.com/gofiber/fiber/v2"
@@ -32,9 +36,12 @@ func NewHandler(service ServiceInterface) *Handler {
 // The handler responds with a JSON object containing the issuer and endpoint URLs (authorization,
 // token, userinfo, jwks), supported scopes, supported response and grant types, subject types,
 // and supported ID token signing algorithms. The provided domain is used as the issuer base URL
-// for all advertised endpoints.
-func OpenIDConfigurationHandler(domain string) fiber.Handler {
+// for all advertised endpoints. The signing algorithms are those of the keys in keyStore.
+func OpenIDConfigurationHandler(domain string, keyStore *auth.KeyStore) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		// The active key signs everything without a per-client algorithm, so it comes first
+		signingAlgs := keyStore.Algorithms()
+
 		return c.JSON(fiber.Map{
 			"issuer": domain,
 
@@ -42,21 +49,74 @@ func OpenIDConfigurationHandler(domain string) fiber.Handler {
 			"token_endpoint":         domain + "/v1/oauth/token",
 			"userinfo_endpoint":      domain + "/v1/oauth/userinfo",
 			"jwks_uri":               domain + "/.well-known/jwks.json",
+			"introspection_endpoint": domain + "/v1/oauth/introspect",
+			"revocation_endpoint":    domain + "/v1/oauth/revoke",
+			"end_session_endpoint":   domain + "/v1/oauth/logout",
+
+			"pushed_authorization_request_endpoint": domain + "/v1/oauth/par",
+			"require_pushed_authorization_requests": false,
+
+			"device_authorization_endpoint": domain + "/v1/oauth/device_authorization",
+
+			"backchannel_authentication_endpoint":        domain + "/v1/oauth/bc-authorize",
+			"backchannel_token_delivery_modes_supported": []string{"poll"},
+			"backchannel_user_code_parameter_supported":  false,
+
+			"registration_endpoint": domain + "/v1/oauth/register",
+
+			"prompt_values_supported": []string{PromptNone, PromptLogin, PromptConsent, PromptSelectAccount},
 
 			"scopes_supported": []string{
 				"openid", "profile", "email",
 			},
 
+			"claims_supported":           SupportedClaims(),
+			"claims_parameter_supported": true,
+
 			"response_types_supported": []string{"code"},
+			"response_modes_supported": SupportedResponseModes,
 			"grant_types_supported": []string{
 				"authorization_code",
 				"refresh_token",
 				"password",
 				"client_credentials",
+				DeviceCodeGrantType,
+				TokenExchangeGrantType,
+				CIBAGrantType,
+			},
+
+			"token_endpoint_auth_methods_supported": []string{
+				svc.AuthMethodClientSecretBasic,
+				svc.AuthMethodClientSecretPost,
+				svc.AuthMethodClientSecretJWT,
+				svc.AuthMethodPrivateKeyJWT,
+				svc.AuthMethodNone,
+			},
+			"token_endpoint_auth_signing_alg_values_supported": []string{"HS256", "RS256", "PS256", "ES256", "EdDSA"},
+
+			"introspection_endpoint_auth_methods_supported": []string{
+				svc.AuthMethodClientSecretBasic,
+				svc.AuthMethodClientSecretPost,
+				svc.AuthMethodClientSecretJWT,
+				svc.AuthMethodPrivateKeyJWT,
+			},
+			"revocation_endpoint_auth_methods_supported": []string{
+				svc.AuthMethodClientSecretBasic,
+				svc.AuthMethodClientSecretPost,
+				svc.AuthMethodClientSecretJWT,
+				svc.AuthMethodPrivateKeyJWT,
+				svc.AuthMethodNone,
 			},
 
-			"subject_types_supported":               []string{"public"},
-			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"subject_types_supported":               []string{svc.SubjectTypePublic, svc.SubjectTypePairwise},
+			"id_token_signing_alg_values_supported": signingAlgs,
+
+			"authorization_signing_alg_values_supported": signingAlgs[:min(1, len(signingAlgs))],
+
+			"dpop_signing_alg_values_supported": []string{"RS256", "PS256", "ES256", "EdDSA"},
+
+			"backchannel_logout_supported":         true,
+			"backchannel_logout_session_supported": true,
 		})
 	}
 }
@@ -69,33 +129,46 @@ func (h *Handler) Authorize(c *fiber.Ctx) error {
 		return utils.ErrorResponse(c, "invalid_request: malformed parameters", fiber.StatusBadRequest)
 	}
 
-	// Validate required fields
-	if req.ResponseType == "" {
-		return utils.ErrorResponse(c, "invalid_request: response_type is required", fiber.StatusBadRequest)
-	}
+	// Validate required fields; a request_uri stands in for everything but client_id
 	if req.ClientID == "" {
 		return utils.ErrorResponse(c, "invalid_request: client_id is required", fiber.StatusBadRequest)
 	}
-	if req.RedirectURI == "" {
-		return utils.ErrorResponse(c, "invalid_request: redirect_uri is required", fiber.StatusBadRequest)
-	}
-	if req.Scope == "" {
-		return utils.ErrorResponse(c, "invalid_request: scope is required", fiber.StatusBadRequest)
+	if req.RequestURI == "" {
+		if req.ResponseType == "" {
+			return utils.ErrorResponse(c, "invalid_request: response_type is required", fiber.StatusBadRequest)
+		}
+		if req.RedirectURI == "" {
+			return utils.ErrorResponse(c, "invalid_request: redirect_uri is required", fiber.StatusBadRequest)
+		}
+		if req.Scope == "" {
+			return utils.ErrorResponse(c, "invalid_request: scope is required", fiber.StatusBadRequest)
+		}
 	}
 
-	identity, ok := c.Locals(auth.IdentityKey).(*auth.Identity)
-	if !ok || identity == nil {
-		return utils.ErrorResponse(c, "login_required", fiber.StatusUnauthorized)
+	// Without a session the service decides between login_required and, for prompt=none, a redirect
+	userID, sessionID := uuid.Nil, uuid.Nil
+	var authTime time.Time
+	if identity, ok := c.Locals(auth.IdentityKey).(*auth.Identity); ok && identity != nil {
+		parsed, err := uuid.Parse(identity.UserID)
+		if err != nil {
+			return utils.ErrorResponse(c, "invalid_user_id", fiber.StatusInternalServerError)
+		}
+		sid, err := uuid.Parse(identity.SessionID)
+		if err != nil {
+			return utils.ErrorResponse(c, "invalid_session_id", fiber.StatusInternalServerError)
+		}
+		userID, sessionID = parsed, sid
+		authTime = identity.AuthTime
 	}
 
-	userID, err := uuid.Parse(identity.UserID)
+	// Call service; the issued code is bound to the user agent's session
+	res, err := h.service.Authorize(&req, userID, sessionID, authTime)
 	if err != nil {
-		return utils.ErrorResponse(c, "invalid_user_id", fiber.StatusInternalServerError)
-	}
+		var redirectErr *AuthorizationRedirectError
+		if errors.As(err, &redirectErr) {
+			return h.sendAuthorizationResponse(c, redirectErr.ClientID, redirectErr.RedirectURI, redirectErr.ResponseMode, redirectErr.Parameters())
+		}
 
-	// Call service
-	res, err := h.service.Authorize(&req, userID)
-	if err != nil {
 		oidcErr := MapErrorToOIDC(err)
 		if oidcErr.Code == ErrorCodeServerError {
 			slog.Error("Authorize endpoint error", "error", err)
@@ -103,21 +176,26 @@ func (h *Handler) Authorize(c *fiber.Ctx) error {
 		return utils.ErrorResponse(c, oidcErr.Code, oidcErr.StatusCode)
 	}
 
-	// Redirect to redirect_uri with code and state
-	u, err := url.Parse(req.RedirectURI)
+	return h.sendAuthorizationResponse(c, res.ClientID, res.RedirectURI, res.ResponseMode, res.Parameters())
+}
+
+// sendAuthorizationResponse delivers an authorization response to the client, either by redirecting
+// the user agent or by serving an auto-submitting form for the form_post response modes
+func (h *Handler) sendAuthorizationResponse(c *fiber.Ctx, clientID, redirectURI, responseMode string, params url.Values) error {
+	encoded, err := h.service.EncodeAuthorizationResponse(clientID, redirectURI, responseMode, params)
 	if err != nil {
-		slog.Error("Failed to parse redirect_uri", "error", err, "redirect_uri", req.RedirectURI)
+		slog.Error("Failed to encode authorization response", "error", err, "redirect_uri", redirectURI)
 		return utils.ErrorResponse(c, "invalid_redirect_uri", fiber.StatusBadRequest)
 	}
 
-	q := u.Query()
-	q.Set("code", res.Code)
-	if res.State != "" {
-		q.Set("state", res.State)
+	if !encoded.FormPost {
+		return c.Redirect(encoded.RedirectURI, fiber.StatusFound)
 	}
-	u.RawQuery = q.Encode()
 
-	return c.Redirect(u.String(), fiber.StatusFound)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+	c.Type("html", "utf-8")
+	return renderFormPost(c, encoded)
 }
 
 // handleOIDCError handles errors from OIDC service methods, mapping them to standard OIDC error responses
@@ -141,14 +219,22 @@ func (h *Handler) Token(c *fiber.Ctx) error {
 	req.UserAgent = c.Get("User-Agent")
 	req.IPAddress = c.IP()
 
+	req.ClientAuthMethod = resolveClientAuth(c, &req.ClientID, &req.ClientSecret)
+
 	// Validate common required fields
 	if req.GrantType == "" {
 		return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "grant_type is required")
 	}
-	if req.ClientID == "" {
+	if req.ClientID == "" && req.ClientAssertion == "" {
 		return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "client_id is required")
 	}
 
+	// Tokens are bound to the key of a DPoP proof when one is sent
+	req.DPoPProof = c.Get(auth.DPoPHeader)
+	if err := h.service.VerifyDPoP(&req); err != nil {
+		return h.handleOIDCError(c, err, "dpop")
+	}
+
 	switch req.GrantType {
 	case "refresh_token":
 		if req.RefreshToken == "" {
@@ -166,7 +252,7 @@ func (h *Handler) Token(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusOK).JSON(res)
 
 	case "client_credentials":
-		if req.ClientSecret == "" {
+		if req.ClientSecret == "" && req.ClientAssertion == "" {
 			return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "client_secret is required")
 		}
 
@@ -198,33 +284,51 @@ func (h *Handler) Token(c *fiber.Ctx) error {
 			return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "redirect_uri is required")
 		}
 
-		// Get session from cookie
-		sessionCookie := c.Cookies("session")
-		if sessionCookie == "" {
-			return utils.OIDCErrorResponse(c, ErrorCodeInvalidGrant, "Session cookie is required", fiber.StatusUnauthorized)
+		// The code carries the session it was issued in, so no cookie is needed to redeem it
+		res, err := h.service.ExchangeCode(&req)
+		if err != nil {
+			return h.handleOIDCError(c, err, "authorization_code")
 		}
 
-		// Parse session cookie: format is "sessionID:secret"
-		parts := strings.SplitN(sessionCookie, ":", 2)
-		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
-			return utils.OIDCErrorResponse(c, ErrorCodeInvalidGrant, "Invalid session cookie format", fiber.StatusUnauthorized)
-		}
+		return c.Status(fiber.StatusOK).JSON(res)
 
-		sessionIDStr := parts[0]
-		refreshSecret := parts[1]
+	case DeviceCodeGrantType:
+		if req.DeviceCode == "" {
+			return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "device_code is required")
+		}
 
-		// Parse session ID as UUID
-		sessionID, err := uuid.Parse(sessionIDStr)
+		res, err := h.service.DeviceCodeGrant(&req)
 		if err != nil {
-			return utils.OIDCErrorResponse(c, ErrorCodeInvalidGrant, "Invalid session ID format", fiber.StatusUnauthorized)
+			return h.handleOIDCError(c, err, "device_code")
+		}
+		return c.Status(fiber.StatusOK).JSON(res)
+
+	case CIBAGrantType:
+		if req.AuthReqID == "" {
+			return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "auth_req_id is required")
 		}
 
-		// Call service with existing session
-		res, err := h.service.ExchangeCode(&req, sessionID, refreshSecret)
+		res, err := h.service.CIBAGrant(&req)
 		if err != nil {
-			return h.handleOIDCError(c, err, "authorization_code")
+			return h.handleOIDCError(c, err, "ciba")
 		}
+		return c.Status(fiber.StatusOK).JSON(res)
 
+	case TokenExchangeGrantType:
+		if req.SubjectToken == "" {
+			return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "subject_token is required")
+		}
+		if req.SubjectTokenType == "" {
+			return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "subject_token_type is required")
+		}
+		if req.Audience == "" {
+			return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "audience is required")
+		}
+
+		res, err := h.service.TokenExchangeGrant(&req)
+		if err != nil {
+			return h.handleOIDCError(c, err, "token_exchange")
+		}
 		return c.Status(fiber.StatusOK).JSON(res)
 
 	default:
@@ -232,6 +336,334 @@ func (h *Handler) Token(c *fiber.Ctx) error {
 	}
 }
 
+// PushAuthorization handles the pushed authorization request endpoint (RFC 9126)
+// The client authenticates like at the token endpoint and receives a request_uri to send the user's browser to /authorize with
+func (h *Handler) PushAuthorization(c *fiber.Ctx) error {
+	var req PushedAuthorizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		slog.Error("Failed to parse pushed authorization request body", "error", err)
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "malformed request body")
+	}
+
+	req.ClientAuthMethod = resolveClientAuth(c, &req.ClientID, &req.ClientSecret)
+
+	if req.ClientID == "" && req.ClientAssertion == "" {
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidClient, "client authentication is required", fiber.StatusUnauthorized)
+	}
+	// RFC 9126 Section 2.1: a pushed request cannot itself reference another request_uri
+	if req.RequestURI != "" {
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "request_uri is not allowed")
+	}
+
+	res, err := h.service.PushAuthorizationRequest(&req)
+	if err != nil {
+		return h.handleOIDCError(c, err, "par")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(res)
+}
+
+// Introspect handles the OAuth2 token introspection request (RFC 7662)
+// The caller authenticates with HTTP Basic or client_id/client_secret form fields
+func (h *Handler) Introspect(c *fiber.Ctx) error {
+	var req IntrospectionRequest
+	if err := c.BodyParser(&req); err != nil {
+		slog.Error("Failed to parse introspection request body", "error", err)
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "malformed request body")
+	}
+
+	req.ClientAuthMethod = resolveClientAuth(c, &req.ClientID, &req.ClientSecret)
+
+	if req.ClientID == "" && req.ClientAssertion == "" {
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidClient, "client authentication is required", fiber.StatusUnauthorized)
+	}
+	if req.Token == "" {
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "token is required")
+	}
+
+	res, err := h.service.Introspect(&req)
+	if err != nil {
+		return h.handleOIDCError(c, err, "introspect")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+// Revoke handles the OAuth2 token revocation request (RFC 7009)
+// Accepts a refresh token in "sessionID:secret" format or an access token and revokes the backing session;
+// an access token is additionally revoked by its jti
+func (h *Handler) Revoke(c *fiber.Ctx) error {
+	var req RevocationRequest
+	if err := c.BodyParser(&req); err != nil {
+		slog.Error("Failed to parse revocation request body", "error", err)
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "malformed request body")
+	}
+
+	req.ClientAuthMethod = resolveClientAuth(c, &req.ClientID, &req.ClientSecret)
+
+	if req.ClientID == "" && req.ClientAssertion == "" {
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidClient, "client authentication is required", fiber.StatusUnauthorized)
+	}
+	if req.Token == "" {
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "token is required")
+	}
+
+	if err := h.service.Revoke(&req); err != nil {
+		return h.handleOIDCError(c, err, "revoke")
+	}
+
+	// RFC 7009 Section 2.2: respond with 200 whether or not the token was valid
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// DeviceAuthorization handles the device authorization request (RFC 8628 Section 3.1)
+// Public clients identify themselves with client_id alone; confidential clients authenticate as usual
+func (h *Handler) DeviceAuthorization(c *fiber.Ctx) error {
+	var req DeviceAuthorizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		slog.Error("Failed to parse device authorization request body", "error", err)
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "malformed request body")
+	}
+
+	req.ClientAuthMethod = resolveClientAuth(c, &req.ClientID, &req.ClientSecret)
+
+	if req.ClientID == "" && req.ClientAssertion == "" {
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "client_id is required")
+	}
+
+	res, err := h.service.DeviceAuthorization(&req)
+	if err != nil {
+		return h.handleOIDCError(c, err, "device_authorization")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+// ValidateDevice describes the device authorization behind a user code to the logged-in user
+// Like ValidateAuthorization, it always responds 200 and reports problems in the body
+func (h *Handler) ValidateDevice(c *fiber.Ctx) error {
+	identity, ok := c.Locals(auth.IdentityKey).(*auth.Identity)
+	if !ok || identity == nil {
+		return c.Status(fiber.StatusOK).JSON(&DeviceVerificationResponse{
+			Valid:            false,
+			Error:            ErrorCodeLoginRequired,
+			ErrorDescription: "User must be logged in to verify a device",
+		})
+	}
+
+	userCode := c.Query("user_code")
+	if userCode == "" {
+		return c.Status(fiber.StatusOK).JSON(&DeviceVerificationResponse{
+			Valid:            false,
+			Error:            ErrorCodeInvalidRequest,
+			ErrorDescription: "user_code is required",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(h.service.GetDeviceVerification(userCode))
+}
+
+// ConfirmDevice records the logged-in user's approval or denial of a device authorization
+func (h *Handler) ConfirmDevice(c *fiber.Ctx) error {
+	var req ConfirmDeviceRequest
+	if err := c.BodyParser(&req); err != nil {
+		slog.Error("Failed to parse device confirmation request body", "error", err)
+		return c.Status(fiber.StatusOK).JSON(&ConfirmDeviceResponse{
+			Success:          false,
+			Error:            ErrorCodeInvalidRequest,
+			ErrorDescription: "Failed to parse request body",
+		})
+	}
+
+	if req.UserCode == "" {
+		return c.Status(fiber.StatusOK).JSON(&ConfirmDeviceResponse{
+			Success:          false,
+			Error:            ErrorCodeInvalidRequest,
+			ErrorDescription: "user_code is required",
+		})
+	}
+
+	identity, ok := c.Locals(auth.IdentityKey).(*auth.Identity)
+	if !ok || identity == nil {
+		return c.Status(fiber.StatusOK).JSON(&ConfirmDeviceResponse{
+			Success:          false,
+			Error:            ErrorCodeLoginRequired,
+			ErrorDescription: "User must be logged in to verify a device",
+		})
+	}
+
+	userID, err := uuid.Parse(identity.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusOK).JSON(&ConfirmDeviceResponse{
+			Success:          false,
+			Error:            ErrorCodeServerError,
+			ErrorDescription: "Invalid user ID",
+		})
+	}
+
+	if err := h.service.ConfirmDevice(req.UserCode, userID, req.Approve); err != nil {
+		oidcErr := MapErrorToOIDC(err)
+		if oidcErr.Code == ErrorCodeServerError {
+			slog.Error("ConfirmDevice endpoint error", "error", err)
+		}
+		return c.Status(fiber.StatusOK).JSON(&ConfirmDeviceResponse{
+			Success:          false,
+			Error:            oidcErr.Code,
+			ErrorDescription: oidcErr.Description,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&ConfirmDeviceResponse{Success: true})
+}
+
+// Logout handles OIDC RP-Initiated Logout
+// A request carrying an id_token_hint for the current session logs it out at once; any other
+// request with a session is sent to the logout confirmation page. Invalid requests log nobody out.
+func (h *Handler) Logout(c *fiber.Ctx) error {
+	var req LogoutRequest
+	var err error
+	if c.Method() == fiber.MethodPost {
+		err = c.BodyParser(&req)
+	} else {
+		err = c.QueryParser(&req)
+	}
+	if err != nil {
+		slog.Error("Failed to parse logout request", "error", err)
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "malformed parameters")
+	}
+
+	res, err := h.service.Logout(&req, logoutSessionID(c), false)
+	if err != nil {
+		oidcErr := MapErrorToOIDC(err)
+		if oidcErr.Code == ErrorCodeServerError {
+			slog.Error("Logout endpoint error", "error", err)
+		}
+		return utils.OIDCErrorResponse(c, oidcErr.Code, oidcErr.Description, oidcErr.StatusCode)
+	}
+
+	if res.ConfirmationURI != "" {
+		return c.Redirect(res.ConfirmationURI, fiber.StatusFound)
+	}
+
+	if res.Revoked {
+		clearSessionCookie(c)
+	}
+
+	if res.RedirectURI != "" {
+		return c.Redirect(res.RedirectURI, fiber.StatusFound)
+	}
+
+	return utils.SuccessResponse(c, nil, "Logout successful")
+}
+
+// ConfirmLogout logs out the current session after the user confirmed it on the logout page
+// The cookie is SameSite=Lax, so a cross-site request never reaches this with a session
+func (h *Handler) ConfirmLogout(c *fiber.Ctx) error {
+	var req LogoutRequest
+	if err := c.BodyParser(&req); err != nil {
+		slog.Error("Failed to parse logout confirmation request body", "error", err)
+		return c.Status(fiber.StatusOK).JSON(&ConfirmLogoutResponse{
+			Success:          false,
+			Error:            ErrorCodeInvalidRequest,
+			ErrorDescription: "Failed to parse request body",
+		})
+	}
+
+	res, err := h.service.Logout(&req, logoutSessionID(c), true)
+	if err != nil {
+		oidcErr := MapErrorToOIDC(err)
+		if oidcErr.Code == ErrorCodeServerError {
+			slog.Error("ConfirmLogout endpoint error", "error", err)
+		}
+		return c.Status(fiber.StatusOK).JSON(&ConfirmLogoutResponse{
+			Success:          false,
+			Error:            oidcErr.Code,
+			ErrorDescription: oidcErr.Description,
+		})
+	}
+
+	if res.Revoked {
+		clearSessionCookie(c)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&ConfirmLogoutResponse{
+		Success:     true,
+		RedirectURI: res.RedirectURI,
+	})
+}
+
+// logoutSessionID returns the browser session referenced by the session cookie, if any
+func logoutSessionID(c *fiber.Ctx) *uuid.UUID {
+	identity, ok := c.Locals(auth.IdentityKey).(*auth.Identity)
+	if !ok || identity == nil {
+		return nil
+	}
+	sid, err := uuid.Parse(identity.SessionID)
+	if err != nil {
+		return nil
+	}
+	return &sid
+}
+
+// clearSessionCookie expires the browser session cookie
+func clearSessionCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "session",
+		Value:    "",
+		HTTPOnly: true,
+		Secure:   true,
+		Path:     "/",
+		SameSite: "Lax",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+	})
+}
+
+// parseBasicClientAuth extracts client credentials from an HTTP Basic Authorization header.
+// Per RFC 6749 Section 2.3.1 both values are form-urlencoded before being base64 encoded.
+func parseBasicClientAuth(header string) (clientID, clientSecret string, ok bool) {
+	scheme, encoded, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", "", false
+	}
+
+	rawID, rawSecret, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return "", "", false
+	}
+
+	clientID, err = url.QueryUnescape(rawID)
+	if err != nil {
+		return "", "", false
+	}
+	clientSecret, err = url.QueryUnescape(rawSecret)
+	if err != nil {
+		return "", "", false
+	}
+
+	return clientID, clientSecret, clientID != ""
+}
+
+// resolveClientAuth lets HTTP Basic credentials take precedence over client_id/client_secret form
+// fields and reports which method transmitted the secret, so it can be checked against the
+// client's registered token_endpoint_auth_method
+func resolveClientAuth(c *fiber.Ctx, clientID, clientSecret *string) string {
+	if id, secret, ok := parseBasicClientAuth(c.Get(fiber.HeaderAuthorization)); ok {
+		*clientID = id
+		*clientSecret = secret
+		return svc.AuthMethodClientSecretBasic
+	}
+	if *clientSecret != "" {
+		return svc.AuthMethodClientSecretPost
+	}
+	return svc.AuthMethodNone
+}
+
 // UserInfo handles the OIDC UserInfo endpoint
 // Returns user information based on scopes from the access token
 func (h *Handler) UserInfo(c *fiber.Ctx) error {
@@ -250,7 +682,20 @@ func (h *Handler) UserInfo(c *fiber.Ctx) error {
 		scopes = []string{"openid"}
 	}
 
-	userInfo, err := h.service.GetUserInfo(identity.UserID, scopes)
+	// Claims requested individually at authorization time travel with the token
+	var requested map[string]*ClaimRequest
+	if claimsReq, err := parseClaimsRequest(claims.GetRequestedClaims()); err == nil && claimsReq != nil {
+		requested = claimsReq.UserInfo
+	}
+
+	// The subject was derived for the first audience, which for exchanged tokens is the target
+	// service rather than the client that requested the token
+	clientID := claims.ClientID()
+	if aud := claims.Audience(); len(aud) > 0 {
+		clientID = aud[0]
+	}
+
+	userInfo, err := h.service.GetUserInfo(identity.UserID, clientID, scopes, requested)
 	if err != nil {
 		slog.Error("UserInfo endpoint error", "error", err)
 		return utils.OIDCErrorResponse(c, "server_error", "internal_server_error", fiber.StatusInternalServerError)
@@ -273,12 +718,14 @@ func (h *Handler) ValidateAuthorization(c *fiber.Ctx) error {
 	}
 
 	var userID *string
+	var authTime time.Time
 	if identity, ok := c.Locals(auth.IdentityKey).(*auth.Identity); ok && identity != nil {
 		uid := identity.UserID
 		userID = &uid
+		authTime = identity.AuthTime
 	}
 
-	res := h.service.ValidateAuthorizationRequest(&req, userID)
+	res := h.service.ValidateAuthorizationRequest(&req, userID, authTime)
 	return c.Status(fiber.StatusOK).JSON(res)
 }
 
@@ -295,14 +742,7 @@ func (h *Handler) ConfirmAuthorization(c *fiber.Ctx) error {
 		})
 	}
 
-	// Validate required fields
-	if req.ResponseType == "" {
-		return c.Status(fiber.StatusOK).JSON(&ConfirmAuthorizationResponse{
-			Success:          false,
-			Error:            "invalid_request",
-			ErrorDescription: "response_type is required",
-		})
-	}
+	// Validate required fields; the request itself was stored when it was validated
 	if req.ClientID == "" {
 		return c.Status(fiber.StatusOK).JSON(&ConfirmAuthorizationResponse{
 			Success:          false,
@@ -310,18 +750,11 @@ func (h *Handler) ConfirmAuthorization(c *fiber.Ctx) error {
 			ErrorDescription: "client_id is required",
 		})
 	}
-	if req.RedirectURI == "" {
+	if req.RequestURI == "" {
 		return c.Status(fiber.StatusOK).JSON(&ConfirmAuthorizationResponse{
 			Success:          false,
 			Error:            "invalid_request",
-			ErrorDescription: "redirect_uri is required",
-		})
-	}
-	if req.Scope == "" {
-		return c.Status(fiber.StatusOK).JSON(&ConfirmAuthorizationResponse{
-			Success:          false,
-			Error:            "invalid_request",
-			ErrorDescription: "scope is required",
+			ErrorDescription: "request_uri is required",
 		})
 	}
 
@@ -343,21 +776,23 @@ func (h *Handler) ConfirmAuthorization(c *fiber.Ctx) error {
 			ErrorDescription: "Invalid user ID",
 		})
 	}
+	sessionID, err := uuid.Parse(identity.SessionID)
+	if err != nil {
+		return c.Status(fiber.StatusOK).JSON(&ConfirmAuthorizationResponse{
+			Success:          false,
+			Error:            "server_error",
+			ErrorDescription: "Invalid session ID",
+		})
+	}
 
-	// Convert ConfirmAuthorizationRequest to AuthorizeRequest
+	// Only the reference is taken from the browser; the parameters come from the stored request
 	authorizeReq := &AuthorizeRequest{
-		ResponseType:        req.ResponseType,
-		ClientID:            req.ClientID,
-		RedirectURI:         req.RedirectURI,
-		Scope:               req.Scope,
-		State:               req.State,
-		Nonce:               req.Nonce,
-		CodeChallenge:       req.CodeChallenge,
-		CodeChallengeMethod: req.CodeChallengeMethod,
-	}
-
-	// Call service to authorize
-	res, err := h.service.Authorize(authorizeReq, userID)
+		RequestURI: req.RequestURI,
+		ClientID:   req.ClientID,
+	}
+
+	// Call service to authorize and remember the approval
+	res, err := h.service.ConfirmAuthorization(authorizeReq, userID, sessionID, identity.AuthTime)
 	if err != nil {
 		oidcErr := MapErrorToOIDC(err)
 		if oidcErr.Code == ErrorCodeServerError {
@@ -370,10 +805,9 @@ func (h *Handler) ConfirmAuthorization(c *fiber.Ctx) error {
 		})
 	}
 
-	// Build redirect URI with code and state
-	u, err := url.Parse(req.RedirectURI)
+	encoded, err := h.service.EncodeAuthorizationResponse(res.ClientID, res.RedirectURI, res.ResponseMode, res.Parameters())
 	if err != nil {
-		slog.Error("Failed to parse redirect_uri in ConfirmAuthorization", "error", err, "redirect_uri", req.RedirectURI)
+		slog.Error("Failed to encode authorization response in ConfirmAuthorization", "error", err, "redirect_uri", res.RedirectURI)
 		return c.Status(fiber.StatusOK).JSON(&ConfirmAuthorizationResponse{
 			Success:          false,
 			Error:            "invalid_redirect_uri",
@@ -381,16 +815,118 @@ func (h *Handler) ConfirmAuthorization(c *fiber.Ctx) error {
 		})
 	}
 
-	q := u.Query()
-	q.Set("code", res.Code)
-	if res.State != "" {
-		q.Set("state", res.State)
+	// form_post responses are submitted by the frontend instead of followed as a redirect
+	response := &ConfirmAuthorizationResponse{
+		Success:     true,
+		RedirectURI: encoded.RedirectURI,
+		FormPost:    encoded.FormPost,
+	}
+	if encoded.FormPost {
+		response.Parameters = make(map[string]string, len(encoded.Parameters))
+		for name := range encoded.Parameters {
+			response.Parameters[name] = encoded.Parameters.Get(name)
+		}
 	}
 
-	u.RawQuery = q.Encode()
+	return c.Status(fiber.StatusOK).JSON(response)
+}
 
-	return c.Status(fiber.StatusOK).JSON(&ConfirmAuthorizationResponse{
-		Success:     true,
-		RedirectURI: u.String(),
-	})
+// BackchannelAuthentication handles the backchannel authentication request (CIBA Core Section 7.1)
+// Only confidential clients can start a backchannel authentication
+func (h *Handler) BackchannelAuthentication(c *fiber.Ctx) error {
+	var req BackchannelAuthenticationRequest
+	if err := c.BodyParser(&req); err != nil {
+		slog.Error("Failed to parse backchannel authentication request body", "error", err)
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidRequest, "malformed request body")
+	}
+
+	req.ClientAuthMethod = resolveClientAuth(c, &req.ClientID, &req.ClientSecret)
+
+	if req.ClientID == "" && req.ClientAssertion == "" {
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidClient, "client authentication is required", fiber.StatusUnauthorized)
+	}
+
+	res, err := h.service.BackchannelAuthentication(&req)
+	if err != nil {
+		return h.handleOIDCError(c, err, "backchannel_authentication")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+// ListBackchannelAuthentications lists the backchannel authentication requests awaiting the logged-in user's decision
+func (h *Handler) ListBackchannelAuthentications(c *fiber.Ctx) error {
+	identity, ok := c.Locals(auth.IdentityKey).(*auth.Identity)
+	if !ok || identity == nil {
+		return utils.ErrorResponse(c, utils.NewAPIError("NOT_AUTHENTICATED", "You must be logged in to access this resource", fiber.StatusUnauthorized))
+	}
+
+	userID, err := uuid.Parse(identity.UserID)
+	if err != nil {
+		return utils.ErrorResponse(c, utils.NewAPIError("INVALID_USER_ID", "Invalid user ID", fiber.StatusUnauthorized))
+	}
+
+	pending, err := h.service.ListBackchannelAuthentications(userID)
+	if err != nil {
+		slog.Error("Failed to list backchannel authentication requests", "error", err, "user_id", userID)
+		return utils.ErrorResponse(c, utils.NewAPIError("INTERNAL_ERROR", "Failed to list authentication requests", fiber.StatusInternalServerError))
+	}
+
+	return utils.SuccessResponse(c, fiber.Map{
+		"requests": pending,
+	}, "Authentication requests retrieved successfully")
+}
+
+// ConfirmBackchannelAuthentication records the logged-in user's approval or denial of a backchannel authentication request
+// Like ConfirmDevice, it always responds 200 and reports problems in the body
+func (h *Handler) ConfirmBackchannelAuthentication(c *fiber.Ctx) error {
+	var req ConfirmBackchannelAuthenticationRequest
+	if err := c.BodyParser(&req); err != nil {
+		slog.Error("Failed to parse backchannel authentication confirmation body", "error", err)
+		return c.Status(fiber.StatusOK).JSON(&ConfirmBackchannelAuthenticationResponse{
+			Success:          false,
+			Error:            ErrorCodeInvalidRequest,
+			ErrorDescription: "Failed to parse request body",
+		})
+	}
+
+	if req.ID == "" {
+		return c.Status(fiber.StatusOK).JSON(&ConfirmBackchannelAuthenticationResponse{
+			Success:          false,
+			Error:            ErrorCodeInvalidRequest,
+			ErrorDescription: "id is required",
+		})
+	}
+
+	identity, ok := c.Locals(auth.IdentityKey).(*auth.Identity)
+	if !ok || identity == nil {
+		return c.Status(fiber.StatusOK).JSON(&ConfirmBackchannelAuthenticationResponse{
+			Success:          false,
+			Error:            ErrorCodeLoginRequired,
+			ErrorDescription: "User must be logged in to answer an authentication request",
+		})
+	}
+
+	userID, err := uuid.Parse(identity.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusOK).JSON(&ConfirmBackchannelAuthenticationResponse{
+			Success:          false,
+			Error:            ErrorCodeServerError,
+			ErrorDescription: "Invalid user ID",
+		})
+	}
+
+	if err := h.service.ConfirmBackchannelAuthentication(req.ID, userID, req.Approve); err != nil {
+		oidcErr := MapErrorToOIDC(err)
+		if oidcErr.Code == ErrorCodeServerError {
+			slog.Error("ConfirmBackchannelAuthentication endpoint error", "error", err)
+		}
+		return c.Status(fiber.StatusOK).JSON(&ConfirmBackchannelAuthenticationResponse{
+			Success:          false,
+			Error:            oidcErr.Code,
+			ErrorDescription: oidcErr.Description,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&ConfirmBackchannelAuthenticationResponse{Success: true})
 }
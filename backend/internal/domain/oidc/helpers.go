@@ -6,7 +6,11 @@ import (
 	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
+	"log/slog"
 	"strings"
+
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/google/uuid"
 )
 
 // generateAuthorizationCode generates a cryptographically random authorization code
@@ -39,53 +43,93 @@ func (s *Service) verifyCodeVerifier(codeVerifier, codeChallenge, method string)
 	return nil
 }
 
-// filterPermissionsForClient filters permissions map to only include permissions for the given client
+// filterPermissionsForClient filters permissions map to only include permissions for the given clients
 // This is for internal authorization, not OIDC scopes
-func (s *Service) filterPermissionsForClient(allPermissions map[string]uint64, clientID string) map[string]uint64 {
+func (s *Service) filterPermissionsForClient(allPermissions map[string]uint64, clientIDs ...string) map[string]uint64 {
 	clientPermissions := make(map[string]uint64)
 	for scopeKey, bitmask := range allPermissions {
-		// Include if it's for this client (format: "clientID" or "clientID:resource")
-		if scopeKey == clientID || strings.HasPrefix(scopeKey, clientID+":") {
-			clientPermissions[scopeKey] = bitmask
+		// Include if it's for one of the clients (format: "clientID" or "clientID:resource")
+		for _, clientID := range clientIDs {
+			if scopeKey == clientID || strings.HasPrefix(scopeKey, clientID+":") {
+				clientPermissions[scopeKey] = bitmask
+				break
+			}
 		}
 	}
 	return clientPermissions
 }
 
-// GetUserInfo returns user information based on requested scopes
-// Only returns claims that are allowed by the scopes
-func (s *Service) GetUserInfo(userID string, scopes []string) (map[string]any, error) {
-	u, err := s.userService.GetUserInfo(userID)
+// checkUserAccess denies the user tokens for a service they hold no permissions for
+func (s *Service) checkUserAccess(userID string, service *svc.Service) error {
+	hasPerm, err := s.permissionService.HasAnyPermission(userID, service.ID.String())
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return fmt.Errorf("failed to check user permissions: %w", err)
 	}
+	if !hasPerm {
+		return ErrUserAccessDenied
+	}
+	return nil
+}
 
-	scopeSet := make(map[string]bool)
-	for _, scope := range scopes {
-		scopeSet[scope] = true
+// trackSessionClient records that the client received tokens backed by the session so it can be
+// notified when the session ends. Only the client the tokens were issued to is tracked: resource
+// servers in the audience do not hold the session. Failures are logged rather than failing the token request.
+func (s *Service) trackSessionClient(sessionID uuid.UUID, clientID string) {
+	if err := s.sessionService.TrackClient(sessionID, clientID); err != nil {
+		slog.Warn("Failed to track session client", "error", err, "session_id", sessionID.String(), "client_id", clientID)
 	}
+}
 
-	claims := make(map[string]any)
+// subjectFor returns the subject identifier ("sub") the service knows the user by, which is
+// pairwise for services with the pairwise subject type
+func (s *Service) subjectFor(userID string, service *svc.Service) (string, error) {
+	sub, err := s.authService.Subjects.For(userID, service)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive subject: %w", err)
+	}
+	return sub, nil
+}
 
-	claims["sub"] = u.ID.String()
+// GetUserInfo returns user information based on requested scopes for the client the access token
+// was issued to. Only returns claims that are allowed by the scopes or requested individually.
+func (s *Service) GetUserInfo(userID, clientID string, scopes []string, requested map[string]*ClaimRequest) (map[string]any, error) {
+	service, err := s.serviceRepo.FindByClientID(clientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find service: %w", err)
+	}
 
-	// profile scope claims
-	if scopeSet["profile"] {
-		claims["name"] = strings.TrimSpace(fmt.Sprintf("%s %s", u.FirstName, u.LastName))
-		claims["preferred_username"] = u.Username
-		claims["given_name"] = u.FirstName
-		claims["family_name"] = u.LastName
-		claims["created_at"] = u.CreatedAt
-		claims["updated_at"] = u.UpdatedAt
-		claims["active"] = u.IsActive
+	sub, err := s.subjectFor(userID, service)
+	if err != nil {
+		return nil, err
 	}
 
-	if scopeSet["email"] {
-		if u.Email != "" {
-			claims["email"] = u.Email
-			claims["email_verified"] = false
-		}
+	return s.userClaims(userID, sub, scopes, requested)
+}
+
+// userClaims returns the claims about the user allowed by the scopes plus the individually
+// requested ones, identified by sub
+func (s *Service) userClaims(userID, sub string, scopes []string, requested map[string]*ClaimRequest) (map[string]any, error) {
+	u, err := s.userService.GetUserInfo(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	available := map[string]any{
+		"name":               strings.TrimSpace(fmt.Sprintf("%s %s", u.FirstName, u.LastName)),
+		"preferred_username": u.Username,
+		"given_name":         u.FirstName,
+		"family_name":        u.LastName,
+		"created_at":         u.CreatedAt,
+		"updated_at":         u.UpdatedAt,
+		"active":             u.IsActive,
 	}
+	if u.Email != "" {
+		available["email"] = u.Email
+		available["email_verified"] = false
+	}
+
+	claims := releaseClaims(available, scopes, requested)
+	claims["sub"] = sub
 
 	return claims, nil
 }
@@ -0,0 +1,154 @@
+package oidc
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/google/uuid"
+)
+
+// Introspect reports whether a presented access or refresh token is currently active (RFC 7662)
+// The calling service must authenticate as a confidential client. Tokens that fail signature
+// verification, belong to a revoked session, or carry a stale permission version are reported
+// as inactive rather than returning an error.
+func (s *Service) Introspect(req *IntrospectionRequest) (*IntrospectionResponse, error) {
+	client, err := s.authenticateConfidentialClient(req.credentials())
+	if err != nil {
+		return nil, err
+	}
+
+	// token_type_hint is only a hint (RFC 7662 Section 2.1); the token shape is authoritative
+	if isJWT(req.Token) {
+		return s.introspectAccessToken(req.Token, client), nil
+	}
+	return s.introspectRefreshToken(req.Token, client), nil
+}
+
+// isJWT reports whether the token has the three dot-separated segments of a compact JWS
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// introspectAccessToken verifies a signed access token and checks it against session revocation and permission version.
+// Permissions are only disclosed to clients in the token's audience; other services learn whether the token is active.
+func (s *Service) introspectAccessToken(token string, client *svc.Service) *IntrospectionResponse {
+	inactive := &IntrospectionResponse{Active: false}
+
+	claims, err := s.authService.KeyStore.Verify(token)
+	if err != nil {
+		return inactive
+	}
+
+	if err := claims.Validate(s.authService.Issuer(), nil); err != nil {
+		return inactive
+	}
+
+	revoked, err := s.authService.IsTokenRevoked(claims)
+	if err != nil || revoked {
+		return inactive
+	}
+
+	sid := claims.GetSid()
+	sub := claims.Subject()
+
+	// Client credentials tokens are not tied to a user, so there is no permission version to compare
+	if sid != "service-session" {
+		userID, err := s.authService.ResolveSubject(sub)
+		if err != nil {
+			return inactive
+		}
+
+		pver, err := s.permissionService.GetPermissionVersion(userID)
+		if err != nil {
+			slog.Warn("Failed to get permission version during introspection", "error", err, "sub", sub)
+			return inactive
+		}
+		if pver != claims.GetPermissionV() {
+			return inactive
+		}
+	}
+
+	res := &IntrospectionResponse{
+		Active:    true,
+		TokenType: "access_token",
+		Scope:     strings.Join(claims.GetRequestedScopes(), " "),
+		Subject:   sub,
+		Audience:  claims.Audience(),
+		Issuer:    claims.Issuer(),
+		IssuedAt:  claims.IssuedAt().Unix(),
+		ExpiresAt: claims.Expiration().Unix(),
+		SessionID: sid,
+		ClientID:  claims.ClientID(),
+		JwtID:     claims.JwtID(),
+	}
+	if slices.Contains(claims.Audience(), client.ClientID) {
+		res.Permissions = claims.GetPermissions()
+	}
+	if jkt := claims.GetConfirmationJKT(); jkt != "" {
+		res.Confirmation = map[string]string{"jkt": jkt}
+	}
+
+	return res
+}
+
+// introspectRefreshToken validates a refresh token in the "sessionID:secret" format against its backing session
+// Only the client the token was issued to learns about it; the subject is reported as that client knows the user.
+func (s *Service) introspectRefreshToken(token string, client *svc.Service) *IntrospectionResponse {
+	inactive := &IntrospectionResponse{Active: false}
+
+	parts := strings.SplitN(token, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return inactive
+	}
+
+	sessionID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return inactive
+	}
+
+	sess, err := s.sessionService.Validate(sessionID, parts[1])
+	if err != nil {
+		return inactive
+	}
+
+	// Like revocation, introspection is limited to the client the refresh token was issued to
+	clientIDs, err := s.sessionService.ClientIDs(sessionID)
+	if err != nil {
+		slog.Warn("Failed to get session clients during introspection", "error", err, "session_id", sessionID.String())
+		return inactive
+	}
+	if !slices.Contains(clientIDs, client.ClientID) {
+		return inactive
+	}
+
+	sub, err := s.subjectFor(sess.UserID, client)
+	if err != nil {
+		slog.Warn("Failed to derive subject during introspection", "error", err, "client_id", client.ClientID)
+		return inactive
+	}
+
+	permissions, err := s.permissionService.BuildScopes(sess.UserID)
+	if err != nil {
+		slog.Warn("Failed to build permissions during introspection", "error", err, "sub", sess.UserID)
+		permissions = make(map[string]uint64)
+	}
+
+	res := &IntrospectionResponse{
+		Active:      true,
+		TokenType:   "refresh_token",
+		Scope:       sess.GrantedScopes,
+		Subject:     sub,
+		Issuer:      s.authService.Issuer(),
+		IssuedAt:    sess.CreatedAt.Unix(),
+		ExpiresAt:   sess.ExpiresAt.Unix(),
+		SessionID:   sess.ID.String(),
+		Permissions: s.filterPermissionsForClient(permissions, client.ClientID),
+	}
+	if sess.DPoPJKT != "" {
+		res.Confirmation = map[string]string{"jkt": sess.DPoPJKT}
+	}
+
+	return res
+}
@@ -0,0 +1,50 @@
+package oidc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/Anvoria/authly/internal/domain/session"
+	"github.com/Anvoria/authly/internal/domain/subject"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// clientSessions holds one session and the clients it was issued to
+type clientSessions struct {
+	session.Service
+	sess    *session.Session
+	secret  string
+	clients []string
+}
+
+func (s *clientSessions) Validate(id uuid.UUID, secret string) (*session.Session, error) {
+	if id != s.sess.ID || secret != s.secret {
+		return nil, session.ErrInvalidSession
+	}
+	return s.sess, nil
+}
+
+func (s *clientSessions) ClientIDs(uuid.UUID) ([]string, error) {
+	return s.clients, nil
+}
+
+func TestIntrospectRefreshToken(t *testing.T) {
+	sess := &session.Session{UserID: uuid.NewString(), GrantedScopes: "openid", ExpiresAt: time.Now().Add(time.Hour)}
+	sess.ID = uuid.New()
+	s := &Service{
+		authService:       auth.NewService(nil, nil, nil, nil, nil, nil, subject.NewService(nil, ""), "https://auth.example.com", nil, nil),
+		sessionService:    &clientSessions{sess: sess, secret: "secret", clients: []string{"app"}},
+		permissionService: allowAll{},
+	}
+	token := sess.ID.String() + ":secret"
+
+	res := s.introspectRefreshToken(token, &svc.Service{ClientID: "app"})
+	assert.True(t, res.Active)
+	assert.Equal(t, sess.UserID, res.Subject)
+
+	res = s.introspectRefreshToken(token, &svc.Service{ClientID: "other"})
+	assert.Equal(t, &IntrospectionResponse{Active: false}, res, "other clients learn nothing about the token")
+}
@@ -0,0 +1,132 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/Anvoria/authly/internal/domain/session"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LogoutResult is the outcome of an RP-Initiated Logout request
+type LogoutResult struct {
+	// Revoked is set when the browser session was logged out by this request
+	Revoked bool
+	// RedirectURI is the validated post-logout redirect, empty when none was requested
+	RedirectURI string
+	// ConfirmationURI is set instead when the user has to confirm the logout first
+	ConfirmationURI string
+}
+
+// Logout handles OIDC RP-Initiated Logout. The request is validated before anything is revoked;
+// the browser session is then logged out only when an id_token_hint issued for that session
+// proves the relying party is part of it, or when the user confirmed the logout. Any other
+// request is answered with the URI of the logout confirmation page, so a third-party site cannot
+// log the user out with a plain navigation.
+func (s *Service) Logout(req *LogoutRequest, sessionID *uuid.UUID, confirmed bool) (*LogoutResult, error) {
+	clientID := req.ClientID
+	hintSID := ""
+
+	if req.IDTokenHint != "" {
+		token, err := s.verifyIDTokenHint(req.IDTokenHint)
+		if err != nil {
+			return nil, err
+		}
+
+		aud, _ := token.Audience()
+		if clientID == "" {
+			if len(aud) == 0 {
+				return nil, ErrInvalidIDTokenHint
+			}
+			clientID = aud[0]
+		} else if !slices.Contains(aud, clientID) {
+			return nil, ErrInvalidIDTokenHint
+		}
+
+		_ = token.Get("sid", &hintSID)
+	}
+
+	redirectURI, err := s.postLogoutRedirectURI(req, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LogoutResult{RedirectURI: redirectURI}
+	if sessionID == nil {
+		return result, nil
+	}
+
+	if !confirmed && (hintSID == "" || hintSID != sessionID.String()) {
+		return &LogoutResult{ConfirmationURI: s.logoutConfirmationURI(req)}, nil
+	}
+
+	if err := s.sessionService.Revoke(*sessionID); err != nil && !errors.Is(err, session.ErrInvalidSession) {
+		return nil, fmt.Errorf("failed to revoke session: %w", err)
+	}
+	result.Revoked = true
+
+	return result, nil
+}
+
+// postLogoutRedirectURI validates the requested post_logout_redirect_uri against the client's
+// registered ones and appends the state. It returns an empty string when none was requested.
+func (s *Service) postLogoutRedirectURI(req *LogoutRequest, clientID string) (string, error) {
+	if req.PostLogoutRedirectURI == "" {
+		return "", nil
+	}
+
+	// A redirect can only be honoured when the client is identified, otherwise there is nothing to match it against
+	if clientID == "" {
+		return "", ErrInvalidPostLogoutRedirectURI
+	}
+
+	service, err := s.serviceRepo.FindByClientID(clientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrInvalidClientID
+		}
+		return "", fmt.Errorf("failed to find service: %w", err)
+	}
+
+	if !slices.Contains(service.PostLogoutRedirectURIs, req.PostLogoutRedirectURI) {
+		return "", ErrInvalidPostLogoutRedirectURI
+	}
+
+	u, err := url.Parse(req.PostLogoutRedirectURI)
+	if err != nil {
+		return "", ErrInvalidPostLogoutRedirectURI
+	}
+
+	if req.State != "" {
+		q := u.Query()
+		q.Set("state", req.State)
+		u.RawQuery = q.Encode()
+	}
+
+	return u.String(), nil
+}
+
+// logoutConfirmationURI returns the frontend page asking the user to confirm the logout, carrying
+// the original request parameters so the confirmation can be posted back unchanged
+func (s *Service) logoutConfirmationURI(req *LogoutRequest) string {
+	q := url.Values{}
+	for key, value := range map[string]string{
+		"id_token_hint":            req.IDTokenHint,
+		"post_logout_redirect_uri": req.PostLogoutRedirectURI,
+		"client_id":                req.ClientID,
+		"state":                    req.State,
+	} {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+
+	uri := s.authService.Issuer() + "/logout"
+	if len(q) > 0 {
+		uri += "?" + q.Encode()
+	}
+	return uri
+}
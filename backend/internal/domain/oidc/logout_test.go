@@ -0,0 +1,111 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/Anvoria/authly/internal/domain/session"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// revokingSessions records which sessions were revoked
+type revokingSessions struct {
+	session.Service
+	revoked []uuid.UUID
+}
+
+func (r *revokingSessions) Revoke(sessionID uuid.UUID) error {
+	r.revoked = append(r.revoked, sessionID)
+	return nil
+}
+
+func TestLogout(t *testing.T) {
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	key, err := auth.NewSigningKey("test", raw, "RS256")
+	require.NoError(t, err)
+	keyStore, err := auth.NewKeyStore("test", []auth.SigningKey{key}, nil, nil)
+	require.NoError(t, err)
+	authService := auth.NewService(nil, nil, nil, nil, nil, keyStore, nil, "https://auth.example.com", nil, nil)
+
+	app := &svc.Service{ClientID: "app", PostLogoutRedirectURIs: []string{"https://app.example.com/bye"}, Active: true}
+	sessions := &revokingSessions{}
+	s := &Service{
+		serviceRepo:    &serviceDirectory{services: []*svc.Service{app}},
+		authService:    authService,
+		sessionService: sessions,
+	}
+
+	browserSession := uuid.New()
+	hintFor := func(sid uuid.UUID) string {
+		idToken, err := authService.GenerateIDToken("user-1", "app", "", time.Now(), map[string]any{"sid": sid.String()}, "")
+		require.NoError(t, err)
+		return idToken
+	}
+
+	t.Run("invalid requests log nobody out", func(t *testing.T) {
+		sessions.revoked = nil
+		_, err := s.Logout(&LogoutRequest{ClientID: "app", PostLogoutRedirectURI: "https://evil.example.com"}, &browserSession, true)
+		assert.ErrorIs(t, err, ErrInvalidPostLogoutRedirectURI)
+
+		_, err = s.Logout(&LogoutRequest{IDTokenHint: "garbage"}, &browserSession, true)
+		assert.ErrorIs(t, err, ErrInvalidIDTokenHint)
+		assert.Empty(t, sessions.revoked)
+	})
+
+	t.Run("only ID tokens are honoured as hints", func(t *testing.T) {
+		sessions.revoked = nil
+		accessToken, err := authService.GenerateAccessToken("user-1", browserSession.String(), []string{"openid"}, "app", "app", nil, 1)
+		require.NoError(t, err)
+
+		// A JARM response is signed with the same keys but has no subject
+		response, err := jwt.NewBuilder().Issuer("https://auth.example.com").Audience([]string{"app"}).Claim("sid", browserSession.String()).Build()
+		require.NoError(t, err)
+		jarm, err := keyStore.SignToken(response)
+		require.NoError(t, err)
+
+		for _, hint := range []string{accessToken, jarm} {
+			_, err := s.Logout(&LogoutRequest{IDTokenHint: hint}, &browserSession, false)
+			assert.ErrorIs(t, err, ErrInvalidIDTokenHint)
+		}
+		assert.Empty(t, sessions.revoked)
+	})
+
+	t.Run("without a matching hint the user confirms", func(t *testing.T) {
+		sessions.revoked = nil
+		for _, req := range []*LogoutRequest{
+			{},
+			{IDTokenHint: hintFor(uuid.New()), State: "xyz"},
+		} {
+			res, err := s.Logout(req, &browserSession, false)
+			require.NoError(t, err)
+			assert.False(t, res.Revoked)
+			assert.Contains(t, res.ConfirmationURI, "https://auth.example.com/logout")
+		}
+		assert.Empty(t, sessions.revoked)
+	})
+
+	t.Run("a hint for the current session logs out at once", func(t *testing.T) {
+		sessions.revoked = nil
+		res, err := s.Logout(&LogoutRequest{IDTokenHint: hintFor(browserSession), PostLogoutRedirectURI: "https://app.example.com/bye", State: "xyz"}, &browserSession, false)
+		require.NoError(t, err)
+		assert.True(t, res.Revoked)
+		assert.Equal(t, "https://app.example.com/bye?state=xyz", res.RedirectURI)
+		assert.Equal(t, []uuid.UUID{browserSession}, sessions.revoked)
+	})
+
+	t.Run("a confirmed logout", func(t *testing.T) {
+		sessions.revoked = nil
+		res, err := s.Logout(&LogoutRequest{}, &browserSession, true)
+		require.NoError(t, err)
+		assert.True(t, res.Revoked)
+		assert.Equal(t, []uuid.UUID{browserSession}, sessions.revoked)
+	})
+}
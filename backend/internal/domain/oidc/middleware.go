@@ -68,6 +68,7 @@ func SessionMiddleware(sessionService session.Service, permissionService permiss
 			SessionID:   sess.ID.String(),
 			PermissionV: pver,
 			Scopes:      scopes,
+			AuthTime:    sess.CreatedAt,
 		}
 
 		c.Locals(auth.IdentityKey, identity)
@@ -8,29 +8,69 @@ import (
 )
 
 // AuthorizeRequest represents the OAuth2/OIDC authorization request
+// The same parameters can be pushed ahead of time as a form body (RFC 9126) and are persisted as JSON
 type AuthorizeRequest struct {
-	ResponseType        string `query:"response_type" validate:"required,oneof=code"`
-	ClientID            string `query:"client_id" validate:"required"`
-	RedirectURI         string `query:"redirect_uri" validate:"required,url"`
-	Scope               string `query:"scope" validate:"required"`
-	State               string `query:"state"`
-	Nonce               string `query:"nonce"`
-	CodeChallenge       string `query:"code_challenge"`
-	CodeChallengeMethod string `query:"code_challenge_method" validate:"omitempty,oneof=S256"`
+	ResponseType        string   `query:"response_type" form:"response_type" json:"response_type" validate:"required,oneof=code"`
+	ClientID            string   `query:"client_id" form:"client_id" json:"client_id" validate:"required"`
+	RedirectURI         string   `query:"redirect_uri" form:"redirect_uri" json:"redirect_uri" validate:"required,url"`
+	Scope               string   `query:"scope" form:"scope" json:"scope" validate:"required"`
+	State               string   `query:"state" form:"state" json:"state,omitempty"`
+	Nonce               string   `query:"nonce" form:"nonce" json:"nonce,omitempty"`
+	CodeChallenge       string   `query:"code_challenge" form:"code_challenge" json:"code_challenge,omitempty"`
+	CodeChallengeMethod string   `query:"code_challenge_method" form:"code_challenge_method" json:"code_challenge_method,omitempty" validate:"omitempty,oneof=S256"`
+	ResponseMode        string   `query:"response_mode" form:"response_mode" json:"response_mode,omitempty"`
+	Claims              string   `query:"claims" form:"claims" json:"claims,omitempty"`
+	Resource            []string `query:"resource" form:"resource" json:"resource,omitempty"`
+
+	// Authentication request parameters (OIDC Core Section 3.1.2.1)
+	Prompt      string `query:"prompt" form:"prompt" json:"prompt,omitempty"`
+	MaxAge      string `query:"max_age" form:"max_age" json:"max_age,omitempty"`
+	LoginHint   string `query:"login_hint" form:"login_hint" json:"login_hint,omitempty"`
+	IDTokenHint string `query:"id_token_hint" form:"id_token_hint" json:"id_token_hint,omitempty"`
+
+	// RequestURI references a pushed authorization request that replaces the parameters above
+	RequestURI string `query:"request_uri" form:"request_uri" json:"-"`
+
+	// pushed is set once the parameters come from a pushed authorization request
+	pushed bool
+	// startedAt is when a stored request was created; prompt=login needs a login after it
+	startedAt time.Time
 }
 
 // AuthorizeResponse represents the response from authorization
 type AuthorizeResponse struct {
-	Code  string `json:"code"`
-	State string `json:"state,omitempty"`
+	Code         string   `json:"code"`
+	State        string   `json:"state,omitempty"`
+	RedirectURI  string   `json:"-"` // the redirect_uri the code is bound to
+	ResponseMode string   `json:"-"`
+	ClientID     string   `json:"-"`
+	Scopes       []string `json:"-"` // the scopes the code was issued for
+}
+
+// PushedAuthorizationRequest represents a pushed authorization request (RFC 9126 Section 2.1)
+type PushedAuthorizationRequest struct {
+	AuthorizeRequest
+
+	ClientSecret        string `form:"client_secret"`
+	ClientAssertionType string `form:"client_assertion_type"`
+	ClientAssertion     string `form:"client_assertion"`
+	ClientAuthMethod    string `form:"-"`
+}
+
+// PushedAuthorizationResponse represents a pushed authorization response (RFC 9126 Section 2.2)
+type PushedAuthorizationResponse struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int    `json:"expires_in"`
 }
 
 // TokenRequest represents the OAuth2 token request
 type TokenRequest struct {
-	GrantType    string `form:"grant_type" validate:"required,oneof=authorization_code refresh_token password client_credentials"`
+	GrantType    string `form:"grant_type" validate:"required,oneof=authorization_code refresh_token password client_credentials urn:ietf:params:oauth:grant-type:device_code urn:ietf:params:oauth:grant-type:token-exchange urn:openid:params:grant-type:ciba"`
 	Code         string `form:"code"`
+	DeviceCode   string `form:"device_code"`
+	AuthReqID    string `form:"auth_req_id"`
 	RedirectURI  string `form:"redirect_uri"`
-	ClientID     string `form:"client_id" validate:"required"`
+	ClientID     string `form:"client_id"`
 	ClientSecret string `form:"client_secret"`
 	CodeVerifier string `form:"code_verifier"`
 	RefreshToken string `form:"refresh_token"`
@@ -39,6 +79,24 @@ type TokenRequest struct {
 	Password     string `form:"password"`
 	UserAgent    string `form:"-"` // Populated from request header
 	IPAddress    string `form:"-"` // Populated from request remote address
+
+	// JWT client authentication (RFC 7523 Section 2.2)
+	ClientAssertionType string `form:"client_assertion_type"`
+	ClientAssertion     string `form:"client_assertion"`
+	ClientAuthMethod    string `form:"-"` // Populated from how the handler received the credentials
+
+	// DPoP (RFC 9449)
+	DPoPProof string `form:"-"` // Populated from the DPoP request header
+	DPoPJKT   string `form:"-"` // Thumbprint of the verified proof key
+
+	// Token exchange (RFC 8693)
+	SubjectToken       string `form:"subject_token"`
+	SubjectTokenType   string `form:"subject_token_type"`
+	RequestedTokenType string `form:"requested_token_type"`
+	Audience           string `form:"audience"`
+
+	// Resource indicators (RFC 8707)
+	Resource []string `form:"resource"`
 }
 
 // TokenResponse represents the OAuth2 token response
@@ -49,40 +107,192 @@ type TokenResponse struct {
 	RefreshToken string `json:"refresh_token,omitempty"`
 	Scope        string `json:"scope,omitempty"`
 	IDToken      string `json:"id_token,omitempty"`
+
+	// IssuedTokenType is only set for token exchange (RFC 8693 Section 2.2.1)
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
 }
 
-// ConfirmAuthorizationRequest represents the request to confirm authorization
-type ConfirmAuthorizationRequest struct {
-	ClientID            string `json:"client_id" validate:"required"`
-	RedirectURI         string `json:"redirect_uri" validate:"required,url"`
-	ResponseType        string `json:"response_type" validate:"required,oneof=code"`
-	Scope               string `json:"scope" validate:"required"`
-	State               string `json:"state"`
-	Nonce               string `json:"nonce"`
-	CodeChallenge       string `json:"code_challenge"`
-	CodeChallengeMethod string `json:"code_challenge_method" validate:"omitempty,oneof=s256 S256"`
+// IntrospectionRequest represents the OAuth2 token introspection request (RFC 7662)
+type IntrospectionRequest struct {
+	Token               string `form:"token" validate:"required"`
+	TokenTypeHint       string `form:"token_type_hint" validate:"omitempty,oneof=access_token refresh_token"`
+	ClientID            string `form:"client_id"`
+	ClientSecret        string `form:"client_secret"`
+	ClientAssertionType string `form:"client_assertion_type"`
+	ClientAssertion     string `form:"client_assertion"`
+	ClientAuthMethod    string `form:"-"`
 }
 
-// ConfirmAuthorizationResponse represents the response from authorization confirmation
-type ConfirmAuthorizationResponse struct {
+// IntrospectionResponse represents the OAuth2 token introspection response (RFC 7662)
+// Only Active is returned for inactive, unknown, or revoked tokens
+type IntrospectionResponse struct {
+	Active      bool              `json:"active"`
+	TokenType   string            `json:"token_type,omitempty"`
+	Scope       string            `json:"scope,omitempty"`
+	Subject     string            `json:"sub,omitempty"`
+	Audience    []string          `json:"aud,omitempty"`
+	Issuer      string            `json:"iss,omitempty"`
+	IssuedAt    int64             `json:"iat,omitempty"`
+	ExpiresAt   int64             `json:"exp,omitempty"`
+	SessionID   string            `json:"sid,omitempty"`
+	ClientID    string            `json:"client_id,omitempty"`
+	JwtID       string            `json:"jti,omitempty"`
+	Permissions map[string]uint64 `json:"permissions,omitempty"`
+
+	// Confirmation carries the DPoP key thumbprint of sender-constrained tokens (RFC 9449 Section 6.2)
+	Confirmation map[string]string `json:"cnf,omitempty"`
+}
+
+// RevocationRequest represents the OAuth2 token revocation request (RFC 7009)
+type RevocationRequest struct {
+	Token               string `form:"token" validate:"required"`
+	TokenTypeHint       string `form:"token_type_hint" validate:"omitempty,oneof=access_token refresh_token"`
+	ClientID            string `form:"client_id"`
+	ClientSecret        string `form:"client_secret"`
+	ClientAssertionType string `form:"client_assertion_type"`
+	ClientAssertion     string `form:"client_assertion"`
+	ClientAuthMethod    string `form:"-"`
+}
+
+// LogoutRequest represents the OIDC RP-Initiated Logout request
+// Parameters may arrive in the query string (GET) or a form body (POST); the confirmation page
+// posts them back as JSON
+type LogoutRequest struct {
+	IDTokenHint           string `query:"id_token_hint" form:"id_token_hint" json:"id_token_hint"`
+	PostLogoutRedirectURI string `query:"post_logout_redirect_uri" form:"post_logout_redirect_uri" json:"post_logout_redirect_uri"`
+	ClientID              string `query:"client_id" form:"client_id" json:"client_id"`
+	State                 string `query:"state" form:"state" json:"state"`
+}
+
+// ConfirmLogoutResponse represents the response to a logout the user confirmed
+type ConfirmLogoutResponse struct {
 	Success          bool   `json:"success"`
 	RedirectURI      string `json:"redirect_uri,omitempty"`
 	Error            string `json:"error,omitempty"`
 	ErrorDescription string `json:"error_description,omitempty"`
 }
 
+// DeviceAuthorizationRequest represents the device authorization request (RFC 8628 Section 3.1)
+type DeviceAuthorizationRequest struct {
+	ClientID            string `form:"client_id"`
+	ClientSecret        string `form:"client_secret"`
+	ClientAssertionType string `form:"client_assertion_type"`
+	ClientAssertion     string `form:"client_assertion"`
+	ClientAuthMethod    string `form:"-"`
+	Scope               string `form:"scope"`
+}
+
+// DeviceAuthorizationResponse represents the device authorization response (RFC 8628 Section 3.2)
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceVerificationResponse describes a pending device authorization to the user approving it
+type DeviceVerificationResponse struct {
+	Valid            bool        `json:"valid"`
+	Client           *ClientInfo `json:"client,omitempty"`
+	Scopes           []string    `json:"scopes,omitempty"`
+	Error            string      `json:"error,omitempty"`
+	ErrorDescription string      `json:"error_description,omitempty"`
+}
+
+// ConfirmDeviceRequest represents the user's decision on a device authorization
+type ConfirmDeviceRequest struct {
+	UserCode string `json:"user_code" validate:"required"`
+	Approve  bool   `json:"approve"`
+}
+
+// ConfirmDeviceResponse represents the response from a device authorization decision
+type ConfirmDeviceResponse struct {
+	Success          bool   `json:"success"`
+	Error            string `json:"error,omitempty"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// BackchannelAuthenticationRequest represents the backchannel authentication request (CIBA Core Section 7.1)
+type BackchannelAuthenticationRequest struct {
+	ClientID            string   `form:"client_id"`
+	ClientSecret        string   `form:"client_secret"`
+	ClientAssertionType string   `form:"client_assertion_type"`
+	ClientAssertion     string   `form:"client_assertion"`
+	ClientAuthMethod    string   `form:"-"`
+	Scope               string   `form:"scope"`
+	LoginHint           string   `form:"login_hint"`
+	BindingMessage      string   `form:"binding_message"`
+	RequestedExpiry     int      `form:"requested_expiry"`
+	Resource            []string `form:"resource"`
+}
+
+// BackchannelAuthenticationResponse acknowledges a backchannel authentication request (CIBA Core Section 7.3)
+type BackchannelAuthenticationResponse struct {
+	AuthReqID string `json:"auth_req_id"`
+	ExpiresIn int    `json:"expires_in"`
+	Interval  int    `json:"interval"`
+}
+
+// PendingBackchannelAuthentication describes a backchannel authentication request awaiting the user's decision
+type PendingBackchannelAuthentication struct {
+	ID             string      `json:"id"`
+	Client         *ClientInfo `json:"client"`
+	Scopes         []string    `json:"scopes"`
+	BindingMessage string      `json:"binding_message,omitempty"`
+	ExpiresAt      time.Time   `json:"expires_at"`
+}
+
+// ConfirmBackchannelAuthenticationRequest represents the user's decision on a backchannel authentication request
+type ConfirmBackchannelAuthenticationRequest struct {
+	ID      string `json:"id" validate:"required"`
+	Approve bool   `json:"approve"`
+}
+
+// ConfirmBackchannelAuthenticationResponse represents the response from a backchannel authentication decision
+type ConfirmBackchannelAuthenticationResponse struct {
+	Success          bool   `json:"success"`
+	Error            string `json:"error,omitempty"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// ConfirmAuthorizationRequest represents the request to confirm authorization
+// The parameters are not sent again: request_uri references the request stored when it was validated,
+// so prompt, max_age and the hints are enforced as the client sent them
+type ConfirmAuthorizationRequest struct {
+	RequestURI string `json:"request_uri" validate:"required"`
+	ClientID   string `json:"client_id" validate:"required"`
+}
+
+// ConfirmAuthorizationResponse represents the response from authorization confirmation
+// For form_post response modes, RedirectURI is the form action and Parameters must be POSTed to it.
+type ConfirmAuthorizationResponse struct {
+	Success          bool              `json:"success"`
+	RedirectURI      string            `json:"redirect_uri,omitempty"`
+	FormPost         bool              `json:"form_post,omitempty"`
+	Parameters       map[string]string `json:"parameters,omitempty"`
+	Error            string            `json:"error,omitempty"`
+	ErrorDescription string            `json:"error_description,omitempty"`
+}
+
 // AuthorizationCode represents an OAuth2 authorization code
 type AuthorizationCode struct {
 	database.BaseModel
 
-	Code          string    `gorm:"column:code;type:varchar(255);uniqueIndex;not null"`
-	ClientID      string    `gorm:"column:client_id;type:varchar(255);not null;index"`
-	UserID        uuid.UUID `gorm:"column:user_id;type:uuid;not null;index"`
+	Code     string    `gorm:"column:code;type:varchar(255);uniqueIndex;not null"`
+	ClientID string    `gorm:"column:client_id;type:varchar(255);not null;index"`
+	UserID   uuid.UUID `gorm:"column:user_id;type:uuid;not null;index"`
+	// SessionID is the browser session the user approved the request in; the code is only redeemable while it is active
+	SessionID     uuid.UUID `gorm:"column:session_id;type:uuid"`
+	AuthTime      time.Time `gorm:"column:auth_time"`
 	RedirectURI   string    `gorm:"column:redirect_uri;type:text;not null"`
 	Scopes        string    `gorm:"column:scopes;type:text;not null"` // space-separated
 	Nonce         string    `gorm:"column:nonce;type:text"`
 	CodeChallenge string    `gorm:"column:code_challenge;type:varchar(255)"`
 	ChallengeMeth string    `gorm:"column:challenge_meth;type:varchar(10)"`
+	Claims        string    `gorm:"column:claims;type:text"`    // JSON claims request (OIDC Core Section 5.5)
+	Resources     string    `gorm:"column:resources;type:text"` // space-separated client_ids of resource servers (RFC 8707)
 	ExpiresAt     time.Time `gorm:"column:expires_at;not null;index"`
 	Used          bool      `gorm:"column:used;default:false;index"`
 }
@@ -90,3 +300,75 @@ type AuthorizationCode struct {
 func (AuthorizationCode) TableName() string {
 	return "authorization_codes"
 }
+
+// PushedAuthorization is an authorization request pushed by a client and referenced by request_uri
+type PushedAuthorization struct {
+	database.BaseModel
+
+	RequestURI string    `gorm:"column:request_uri;type:varchar(255);uniqueIndex;not null"`
+	ClientID   string    `gorm:"column:client_id;type:varchar(255);not null"`
+	Parameters string    `gorm:"column:parameters;type:text;not null"` // AuthorizeRequest as JSON
+	Used       bool      `gorm:"column:used;not null;default:false"`
+	ExpiresAt  time.Time `gorm:"column:expires_at;not null;index"`
+
+	// Interactive marks a request stored for the consent screen rather than pushed by the client
+	Interactive bool `gorm:"column:interactive;not null;default:false"`
+}
+
+func (PushedAuthorization) TableName() string {
+	return "pushed_authorization_requests"
+}
+
+// DeviceCodeStatus is the state of a device authorization
+type DeviceCodeStatus string
+
+const (
+	// DeviceCodePending means the user has not yet acted on the user code
+	DeviceCodePending DeviceCodeStatus = "pending"
+	// DeviceCodeApproved means the user approved the device and tokens can be issued
+	DeviceCodeApproved DeviceCodeStatus = "approved"
+	// DeviceCodeDenied means the user rejected the device
+	DeviceCodeDenied DeviceCodeStatus = "denied"
+	// DeviceCodeConsumed means tokens have already been issued for the device code
+	DeviceCodeConsumed DeviceCodeStatus = "consumed"
+)
+
+// DeviceCode represents an OAuth2 device authorization (RFC 8628)
+type DeviceCode struct {
+	database.BaseModel
+
+	DeviceCode   string           `gorm:"column:device_code;type:varchar(255);uniqueIndex;not null"`
+	UserCode     string           `gorm:"column:user_code;type:varchar(16);uniqueIndex;not null"` // normalized, without separator
+	ClientID     string           `gorm:"column:client_id;type:varchar(255);not null;index"`
+	Scopes       string           `gorm:"column:scopes;type:text;not null"` // space-separated
+	UserID       *uuid.UUID       `gorm:"column:user_id;type:uuid"`
+	Status       DeviceCodeStatus `gorm:"column:status;type:varchar(20);not null;default:pending"`
+	Interval     int              `gorm:"column:poll_interval;not null"` // minimum polling interval in seconds
+	LastPolledAt *time.Time       `gorm:"column:last_polled_at"`
+	ExpiresAt    time.Time        `gorm:"column:expires_at;not null;index"`
+}
+
+func (DeviceCode) TableName() string {
+	return "device_codes"
+}
+
+// BackchannelAuthentication represents a client-initiated backchannel authentication request (CIBA).
+// It goes through the same statuses as a device authorization, but the user is known from the start.
+type BackchannelAuthentication struct {
+	database.BaseModel
+
+	AuthReqID      string           `gorm:"column:auth_req_id;type:varchar(255);uniqueIndex;not null"`
+	ClientID       string           `gorm:"column:client_id;type:varchar(255);not null;index"`
+	UserID         uuid.UUID        `gorm:"column:user_id;type:uuid;not null;index"`
+	Scopes         string           `gorm:"column:scopes;type:text;not null"` // space-separated
+	Resources      string           `gorm:"column:resources;type:text"`       // space-separated client_ids of resource servers (RFC 8707)
+	BindingMessage string           `gorm:"column:binding_message;type:text"` // shown on both the consumption and authentication device
+	Status         DeviceCodeStatus `gorm:"column:status;type:varchar(20);not null;default:pending"`
+	Interval       int              `gorm:"column:poll_interval;not null"` // minimum polling interval in seconds
+	LastPolledAt   *time.Time       `gorm:"column:last_polled_at"`
+	ExpiresAt      time.Time        `gorm:"column:expires_at;not null;index"`
+}
+
+func (BackchannelAuthentication) TableName() string {
+	return "backchannel_authentications"
+}
@@ -0,0 +1,127 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// requestURIPrefix is the URN prefix of request_uri values issued by the PAR endpoint (RFC 9126 Section 2.2)
+	requestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+	// parLifetime leaves time for the user to sign in before the request_uri expires
+	parLifetime = 5 * time.Minute
+)
+
+// PushAuthorizationRequest authenticates the client, validates its authorization request and stores
+// it server-side under a single-use request_uri (RFC 9126). The browser then only carries the
+// client_id and request_uri, so parameters such as the PKCE challenge and nonce cannot be tampered with.
+func (s *Service) PushAuthorizationRequest(req *PushedAuthorizationRequest) (*PushedAuthorizationResponse, error) {
+	service, err := s.authenticateClient(req.credentials())
+	if err != nil {
+		return nil, err
+	}
+
+	authReq := req.AuthorizeRequest
+	if authReq.ClientID != "" && authReq.ClientID != service.ClientID {
+		return nil, ErrInvalidClientID
+	}
+	authReq.ClientID = service.ClientID
+	authReq.pushed = true
+
+	if res := s.validateAuthorizationRequest(&authReq, nil, time.Time{}, false); !res.Valid {
+		return nil, OIDCError{Code: res.Error, Description: res.ErrorDescription, StatusCode: http.StatusBadRequest}
+	}
+
+	parameters, err := json.Marshal(&authReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode authorization request: %w", err)
+	}
+
+	reference, err := s.generateAuthorizationCode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate request_uri: %w", err)
+	}
+
+	par := &PushedAuthorization{
+		RequestURI: requestURIPrefix + reference,
+		ClientID:   service.ClientID,
+		Parameters: string(parameters),
+		ExpiresAt:  time.Now().Add(parLifetime),
+	}
+	if err := s.codeRepo.CreatePushedAuthorization(par); err != nil {
+		return nil, fmt.Errorf("failed to save pushed authorization request: %w", err)
+	}
+
+	return &PushedAuthorizationResponse{
+		RequestURI: par.RequestURI,
+		ExpiresIn:  int(parLifetime.Seconds()),
+	}, nil
+}
+
+// resolvePushedRequest replaces an authorization request that references a request_uri with the
+// parameters pushed for it. Requests without a request_uri are returned unchanged.
+func (s *Service) resolvePushedRequest(req *AuthorizeRequest) (*AuthorizeRequest, error) {
+	if req.RequestURI == "" {
+		return req, nil
+	}
+
+	par, err := s.codeRepo.FindPushedAuthorization(req.RequestURI)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRequestURI
+		}
+		return nil, fmt.Errorf("failed to find pushed authorization request: %w", err)
+	}
+
+	// The client_id sent through the browser must match the client that pushed the request (RFC 9126 Section 4)
+	if req.ClientID != par.ClientID {
+		return nil, ErrInvalidRequestURI
+	}
+
+	var pushed AuthorizeRequest
+	if err := json.Unmarshal([]byte(par.Parameters), &pushed); err != nil {
+		return nil, fmt.Errorf("failed to decode pushed authorization request: %w", err)
+	}
+	pushed.RequestURI = req.RequestURI
+	pushed.pushed = !par.Interactive
+	pushed.startedAt = par.CreatedAt
+
+	return &pushed, nil
+}
+
+// storeAuthorizationRequest keeps a validated authorization request server-side for the consent
+// screen, which confirms it by request_uri. The browser can then neither drop nor alter prompt,
+// max_age or the hints between validation and confirmation, and a login is only fresh enough for
+// prompt=login when it happened after the request was stored.
+func (s *Service) storeAuthorizationRequest(req *AuthorizeRequest) error {
+	parameters, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode authorization request: %w", err)
+	}
+
+	reference, err := s.generateAuthorizationCode()
+	if err != nil {
+		return fmt.Errorf("failed to generate request_uri: %w", err)
+	}
+
+	par := &PushedAuthorization{
+		RequestURI:  requestURIPrefix + reference,
+		ClientID:    req.ClientID,
+		Parameters:  string(parameters),
+		ExpiresAt:   time.Now().Add(parLifetime),
+		Interactive: true,
+	}
+	if err := s.codeRepo.CreatePushedAuthorization(par); err != nil {
+		return fmt.Errorf("failed to save authorization request: %w", err)
+	}
+
+	req.RequestURI = par.RequestURI
+	req.startedAt = par.CreatedAt
+	return nil
+}
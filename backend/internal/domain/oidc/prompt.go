@@ -0,0 +1,191 @@
+package oidc
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v3/jwt"
+)
+
+// Values of the prompt parameter (OIDC Core Section 3.1.2.1)
+const (
+	PromptNone          = "none"
+	PromptLogin         = "login"
+	PromptConsent       = "consent"
+	PromptSelectAccount = "select_account"
+)
+
+// authenticationRequirements are the parsed prompt and max_age parameters of an authorization request
+type authenticationRequirements struct {
+	prompt map[string]bool
+	maxAge time.Duration // negative when max_age was not sent
+}
+
+// parseAuthenticationRequirements validates prompt and max_age. prompt=none cannot be combined with other values.
+func parseAuthenticationRequirements(req *AuthorizeRequest) (*authenticationRequirements, error) {
+	reqs := &authenticationRequirements{prompt: make(map[string]bool), maxAge: -1}
+
+	for _, value := range strings.Fields(req.Prompt) {
+		switch value {
+		case PromptNone, PromptLogin, PromptConsent, PromptSelectAccount:
+			reqs.prompt[value] = true
+		default:
+			return nil, ErrInvalidPrompt
+		}
+	}
+	if reqs.prompt[PromptNone] && len(reqs.prompt) > 1 {
+		return nil, ErrInvalidPrompt
+	}
+
+	if req.MaxAge != "" {
+		seconds, err := strconv.Atoi(req.MaxAge)
+		if err != nil || seconds < 0 {
+			return nil, ErrInvalidMaxAge
+		}
+		reqs.maxAge = time.Duration(seconds) * time.Second
+	}
+
+	return reqs, nil
+}
+
+// noInteraction reports whether the client asked for the request to be answered without showing any UI
+func (r *authenticationRequirements) noInteraction() bool {
+	return r.prompt[PromptNone]
+}
+
+// freshLoginRequired reports whether the user must authenticate again regardless of their session.
+// There is only ever one account per browser session, so select_account is treated as a new login.
+func (r *authenticationRequirements) freshLoginRequired() bool {
+	return r.prompt[PromptLogin] || r.prompt[PromptSelectAccount] || r.maxAge == 0
+}
+
+// checkAuthentication decides whether the user's session satisfies the request. userID is uuid.Nil
+// when there is no session. It returns ErrLoginRequired when the user must (re-)authenticate.
+// A fresh login has to happen after the request began, which for a request that was not stored
+// is now, so prompt=login can only be satisfied through the consent screen.
+func (s *Service) checkAuthentication(req *AuthorizeRequest, reqs *authenticationRequirements, clientID string, userID uuid.UUID, authTime time.Time) error {
+	if userID == uuid.Nil {
+		return ErrLoginRequired
+	}
+
+	startedAt := req.startedAt
+	if startedAt.IsZero() {
+		startedAt = time.Now()
+	}
+	if reqs.freshLoginRequired() && !authTime.After(startedAt) {
+		return ErrLoginRequired
+	}
+	if reqs.maxAge > 0 && time.Since(authTime) > reqs.maxAge {
+		return ErrLoginRequired
+	}
+
+	if req.IDTokenHint != "" {
+		token, err := s.verifyIDTokenHint(req.IDTokenHint)
+		if err != nil {
+			return err
+		}
+		if aud, _ := token.Audience(); !slices.Contains(aud, clientID) {
+			return ErrInvalidIDTokenHint
+		}
+		// The hint names another user than the one signed in
+		hinted, _ := token.Subject()
+		if hinted, err = s.authService.ResolveSubject(hinted); err != nil || hinted != userID.String() {
+			return ErrLoginRequired
+		}
+	}
+
+	if req.LoginHint != "" {
+		u, err := s.userService.GetUserInfo(userID.String())
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if !strings.EqualFold(req.LoginHint, u.Username) && !strings.EqualFold(req.LoginHint, u.Email) {
+			return ErrLoginRequired
+		}
+	}
+
+	return nil
+}
+
+// checkConsent decides whether a code can be issued without showing the consent screen.
+// consented is true when the user just approved the request on the consent screen; any other
+// request needs a stored consent covering the scopes, or the user is sent to the consent screen.
+func (s *Service) checkConsent(reqs *authenticationRequirements, clientID string, userID uuid.UUID, scopes []string, consented bool) error {
+	if consented {
+		return nil
+	}
+	if reqs.prompt[PromptConsent] {
+		return ErrConsentRequired
+	}
+
+	covered, err := s.consentService.Covers(userID, clientID, scopes)
+	if err != nil {
+		return fmt.Errorf("failed to check consent: %w", err)
+	}
+	if !covered {
+		return ErrConsentRequired
+	}
+
+	return nil
+}
+
+// verifyIDTokenHint checks that an id_token_hint is an ID token signed by this server. Expired tokens are accepted.
+// Other JWTs this server signs are refused: access and logout tokens by their "typ" header, and
+// JARM responses, which carry neither, because they have no subject.
+func (s *Service) verifyIDTokenHint(hint string) (jwt.Token, error) {
+	token, err := s.authService.KeyStore.VerifySignature(hint)
+	if err != nil {
+		return nil, ErrInvalidIDTokenHint
+	}
+
+	if typ := auth.TokenType(hint); typ != "" && !strings.EqualFold(typ, "JWT") {
+		return nil, ErrInvalidIDTokenHint
+	}
+	if iss, _ := token.Issuer(); iss != s.authService.Issuer() {
+		return nil, ErrInvalidIDTokenHint
+	}
+	if aud, _ := token.Audience(); len(aud) == 0 {
+		return nil, ErrInvalidIDTokenHint
+	}
+	if sub, _ := token.Subject(); sub == "" {
+		return nil, ErrInvalidIDTokenHint
+	}
+
+	return token, nil
+}
+
+// AuthorizationRedirectError is an authorization error reported to the client's already validated
+// redirect_uri instead of the user agent (RFC 6749 Section 4.1.2.1)
+type AuthorizationRedirectError struct {
+	Err          error
+	ClientID     string
+	RedirectURI  string
+	ResponseMode string
+	State        string
+}
+
+func (e *AuthorizationRedirectError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AuthorizationRedirectError) Unwrap() error {
+	return e.Err
+}
+
+// Parameters are the error response parameters to encode for the client's response_mode
+func (e *AuthorizationRedirectError) Parameters() url.Values {
+	oidcErr := MapErrorToOIDC(e.Err)
+	params := url.Values{}
+	params.Set("error", oidcErr.Code)
+	params.Set("error_description", oidcErr.Description)
+	if e.State != "" {
+		params.Set("state", e.State)
+	}
+	return params
+}
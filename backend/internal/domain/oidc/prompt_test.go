@@ -0,0 +1,137 @@
+package oidc
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Anvoria/authly/internal/domain/consent"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAuthenticationRequirements(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		reqs, err := parseAuthenticationRequirements(&AuthorizeRequest{})
+		require.NoError(t, err)
+		assert.False(t, reqs.noInteraction())
+		assert.False(t, reqs.freshLoginRequired())
+	})
+
+	t.Run("login and consent", func(t *testing.T) {
+		reqs, err := parseAuthenticationRequirements(&AuthorizeRequest{Prompt: "login consent"})
+		require.NoError(t, err)
+		assert.True(t, reqs.freshLoginRequired())
+		assert.True(t, reqs.prompt[PromptConsent])
+	})
+
+	t.Run("none cannot be combined", func(t *testing.T) {
+		_, err := parseAuthenticationRequirements(&AuthorizeRequest{Prompt: "none login"})
+		assert.ErrorIs(t, err, ErrInvalidPrompt)
+	})
+
+	t.Run("unknown prompt", func(t *testing.T) {
+		_, err := parseAuthenticationRequirements(&AuthorizeRequest{Prompt: "create"})
+		assert.ErrorIs(t, err, ErrInvalidPrompt)
+	})
+
+	t.Run("max_age", func(t *testing.T) {
+		reqs, err := parseAuthenticationRequirements(&AuthorizeRequest{MaxAge: "300"})
+		require.NoError(t, err)
+		assert.Equal(t, 5*time.Minute, reqs.maxAge)
+		assert.False(t, reqs.freshLoginRequired())
+	})
+
+	t.Run("max_age zero acts as prompt=login", func(t *testing.T) {
+		reqs, err := parseAuthenticationRequirements(&AuthorizeRequest{MaxAge: "0"})
+		require.NoError(t, err)
+		assert.True(t, reqs.freshLoginRequired())
+	})
+
+	t.Run("invalid max_age", func(t *testing.T) {
+		_, err := parseAuthenticationRequirements(&AuthorizeRequest{MaxAge: "-1"})
+		assert.ErrorIs(t, err, ErrInvalidMaxAge)
+	})
+}
+
+func TestAuthorizationRedirectErrorParameters(t *testing.T) {
+	redirectErr := &AuthorizationRedirectError{
+		Err:         ErrLoginRequired,
+		RedirectURI: "https://app.example.com/cb",
+		State:       "xyz",
+	}
+
+	params := redirectErr.Parameters()
+	assert.Equal(t, ErrorCodeLoginRequired, params.Get("error"))
+	assert.Equal(t, "xyz", params.Get("state"))
+	assert.Equal(t, ErrorCodeLoginRequired, MapErrorToOIDC(redirectErr).Code)
+}
+
+func TestCheckAuthenticationFreshLogin(t *testing.T) {
+	s := &Service{}
+	userID := uuid.New()
+	startedAt := time.Now().Add(-time.Minute)
+	reqs, err := parseAuthenticationRequirements(&AuthorizeRequest{Prompt: PromptLogin})
+	require.NoError(t, err)
+
+	t.Run("a login before the request began is not fresh", func(t *testing.T) {
+		req := &AuthorizeRequest{startedAt: startedAt}
+		err := s.checkAuthentication(req, reqs, "app", userID, startedAt.Add(-time.Second))
+		assert.ErrorIs(t, err, ErrLoginRequired)
+	})
+
+	t.Run("a login after the request began", func(t *testing.T) {
+		req := &AuthorizeRequest{startedAt: startedAt}
+		assert.NoError(t, s.checkAuthentication(req, reqs, "app", userID, startedAt.Add(time.Second)))
+	})
+
+	t.Run("a request that was not stored begins now", func(t *testing.T) {
+		err := s.checkAuthentication(&AuthorizeRequest{}, reqs, "app", userID, time.Now().Add(-time.Second))
+		assert.ErrorIs(t, err, ErrLoginRequired)
+	})
+}
+
+// consentStore answers Covers from the scopes each client was granted
+type consentStore struct {
+	consent.Service
+	granted map[string][]string
+}
+
+func (c *consentStore) Covers(_ uuid.UUID, clientID string, scopes []string) (bool, error) {
+	for _, scope := range scopes {
+		if !slices.Contains(c.granted[clientID], scope) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func TestCheckConsent(t *testing.T) {
+	s := &Service{consentService: &consentStore{granted: map[string][]string{"app": {"openid", "profile"}}}}
+	userID := uuid.New()
+	requirements := func(prompt string) *authenticationRequirements {
+		reqs, err := parseAuthenticationRequirements(&AuthorizeRequest{Prompt: prompt})
+		require.NoError(t, err)
+		return reqs
+	}
+
+	t.Run("interactive requests need a stored consent", func(t *testing.T) {
+		err := s.checkConsent(requirements(""), "registered", userID, []string{"openid"}, false)
+		assert.ErrorIs(t, err, ErrConsentRequired)
+
+		err = s.checkConsent(requirements(""), "app", userID, []string{"openid", "email"}, false)
+		assert.ErrorIs(t, err, ErrConsentRequired)
+	})
+
+	t.Run("a stored consent covering the scopes skips the consent screen", func(t *testing.T) {
+		assert.NoError(t, s.checkConsent(requirements(""), "app", userID, []string{"openid"}, false))
+		assert.NoError(t, s.checkConsent(requirements(PromptNone), "app", userID, []string{"profile"}, false))
+	})
+
+	t.Run("prompt=consent asks again unless the user just approved", func(t *testing.T) {
+		err := s.checkConsent(requirements(PromptConsent), "app", userID, []string{"openid"}, false)
+		assert.ErrorIs(t, err, ErrConsentRequired)
+		assert.NoError(t, s.checkConsent(requirements(PromptConsent), "registered", userID, []string{"openid"}, true))
+	})
+}
@@ -3,6 +3,7 @@ package oidc
 import (
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -12,6 +13,23 @@ type Repository interface {
 	FindByCode(code string) (*AuthorizationCode, error)
 	MarkAsUsed(code string) error
 	DeleteExpired() error
+
+	CreateDeviceCode(code *DeviceCode) error
+	FindDeviceCode(deviceCode string) (*DeviceCode, error)
+	FindDeviceCodeByUserCode(userCode string) (*DeviceCode, error)
+	UpdateDeviceCodeStatus(id uuid.UUID, from, to DeviceCodeStatus, userID *uuid.UUID) (bool, error)
+	UpdateDeviceCodePoll(id uuid.UUID, polledAt time.Time, interval int) error
+
+	CreateBackchannelAuthentication(req *BackchannelAuthentication) error
+	FindBackchannelAuthentication(authReqID string) (*BackchannelAuthentication, error)
+	FindBackchannelAuthenticationByID(id uuid.UUID) (*BackchannelAuthentication, error)
+	FindPendingBackchannelAuthentications(userID uuid.UUID) ([]*BackchannelAuthentication, error)
+	UpdateBackchannelAuthenticationStatus(id, userID uuid.UUID, from, to DeviceCodeStatus) (bool, error)
+	UpdateBackchannelAuthenticationPoll(id uuid.UUID, polledAt time.Time, interval int) error
+
+	CreatePushedAuthorization(par *PushedAuthorization) error
+	FindPushedAuthorization(requestURI string) (*PushedAuthorization, error)
+	MarkPushedAuthorizationUsed(requestURI string) error
 }
 
 // repository struct for authorization code operations
@@ -60,3 +78,149 @@ func (r *repository) MarkAsUsed(code string) error {
 func (r *repository) DeleteExpired() error {
 	return r.db.Where("expires_at < ?", time.Now()).Delete(&AuthorizationCode{}).Error
 }
+
+// CreateDeviceCode creates a new device authorization
+func (r *repository) CreateDeviceCode(code *DeviceCode) error {
+	return r.db.Create(code).Error
+}
+
+// FindDeviceCode finds a device authorization by its device_code
+func (r *repository) FindDeviceCode(deviceCode string) (*DeviceCode, error) {
+	var code DeviceCode
+	err := r.db.Where("device_code = ?", deviceCode).First(&code).Error
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// FindDeviceCodeByUserCode finds an unexpired device authorization by its normalized user_code
+func (r *repository) FindDeviceCodeByUserCode(userCode string) (*DeviceCode, error) {
+	var code DeviceCode
+	err := r.db.Where("user_code = ? AND expires_at > ?", userCode, time.Now()).First(&code).Error
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// UpdateDeviceCodeStatus moves a device authorization from one status to another
+// It reports false when the authorization was no longer in the expected status
+func (r *repository) UpdateDeviceCodeStatus(id uuid.UUID, from, to DeviceCodeStatus, userID *uuid.UUID) (bool, error) {
+	updates := map[string]any{"status": to}
+	if userID != nil {
+		updates["user_id"] = *userID
+	}
+
+	result := r.db.Model(&DeviceCode{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// UpdateDeviceCodePoll records a token endpoint poll and the interval the device must respect from now on
+func (r *repository) UpdateDeviceCodePoll(id uuid.UUID, polledAt time.Time, interval int) error {
+	return r.db.Model(&DeviceCode{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"last_polled_at": polledAt,
+			"poll_interval":  interval,
+		}).Error
+}
+
+// CreateBackchannelAuthentication creates a new backchannel authentication request
+func (r *repository) CreateBackchannelAuthentication(req *BackchannelAuthentication) error {
+	return r.db.Create(req).Error
+}
+
+// FindBackchannelAuthentication finds a backchannel authentication request by its auth_req_id
+func (r *repository) FindBackchannelAuthentication(authReqID string) (*BackchannelAuthentication, error) {
+	var req BackchannelAuthentication
+	err := r.db.Where("auth_req_id = ?", authReqID).First(&req).Error
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// FindBackchannelAuthenticationByID finds a backchannel authentication request by its ID
+func (r *repository) FindBackchannelAuthenticationByID(id uuid.UUID) (*BackchannelAuthentication, error) {
+	var req BackchannelAuthentication
+	err := r.db.Where("id = ?", id).First(&req).Error
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// FindPendingBackchannelAuthentications lists the unexpired requests awaiting the user's decision, newest first
+func (r *repository) FindPendingBackchannelAuthentications(userID uuid.UUID) ([]*BackchannelAuthentication, error) {
+	var reqs []*BackchannelAuthentication
+	err := r.db.Where("user_id = ? AND status = ? AND expires_at > ?", userID, DeviceCodePending, time.Now()).
+		Order("created_at DESC").
+		Find(&reqs).Error
+	if err != nil {
+		return nil, err
+	}
+	return reqs, nil
+}
+
+// UpdateBackchannelAuthenticationStatus moves the user's backchannel authentication request from one status to another
+// It reports false when the request does not belong to the user, has expired, or was no longer in the expected status
+func (r *repository) UpdateBackchannelAuthenticationStatus(id, userID uuid.UUID, from, to DeviceCodeStatus) (bool, error) {
+	result := r.db.Model(&BackchannelAuthentication{}).
+		Where("id = ? AND user_id = ? AND status = ? AND expires_at > ?", id, userID, from, time.Now()).
+		Update("status", to)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// UpdateBackchannelAuthenticationPoll records a token endpoint poll and the interval the client must respect from now on
+func (r *repository) UpdateBackchannelAuthenticationPoll(id uuid.UUID, polledAt time.Time, interval int) error {
+	return r.db.Model(&BackchannelAuthentication{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"last_polled_at": polledAt,
+			"poll_interval":  interval,
+		}).Error
+}
+
+// CreatePushedAuthorization stores a pushed authorization request
+func (r *repository) CreatePushedAuthorization(par *PushedAuthorization) error {
+	return r.db.Create(par).Error
+}
+
+// FindPushedAuthorization finds an unused, unexpired pushed authorization request by request_uri
+func (r *repository) FindPushedAuthorization(requestURI string) (*PushedAuthorization, error) {
+	var par PushedAuthorization
+	err := r.db.Where("request_uri = ? AND used = false AND expires_at > ?", requestURI, time.Now()).First(&par).Error
+	if err != nil {
+		return nil, err
+	}
+	return &par, nil
+}
+
+// MarkPushedAuthorizationUsed marks a pushed authorization request as used so its request_uri
+// cannot authorize a second code
+func (r *repository) MarkPushedAuthorizationUsed(requestURI string) error {
+	result := r.db.Model(&PushedAuthorization{}).
+		Where("request_uri = ? AND used = false AND expires_at > ?", requestURI, time.Now()).
+		Update("used", true)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
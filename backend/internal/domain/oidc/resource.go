@@ -0,0 +1,92 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"gorm.io/gorm"
+)
+
+// resolveResources maps the resource parameters of a request (RFC 8707) to the client_ids of the
+// target services. A resource names a service by client_id or by domain, given either bare or as
+// an absolute URI. Every target must be active and allowed for the client. The client itself is
+// always part of the audience and is left out of the result.
+func (s *Service) resolveResources(client *svc.Service, resources []string) ([]string, error) {
+	var resolved []string
+	for _, resource := range resources {
+		target, err := s.findResource(resource)
+		if err != nil {
+			return nil, err
+		}
+		if !target.Active || !client.AllowsResource(target.ClientID) {
+			return nil, ErrInvalidTarget
+		}
+		if target.ClientID != client.ClientID && !slices.Contains(resolved, target.ClientID) {
+			resolved = append(resolved, target.ClientID)
+		}
+	}
+	return resolved, nil
+}
+
+// audienceOf returns the client followed by the resource servers a token is addressed to
+func audienceOf(clientID string, resources []string) []string {
+	return append([]string{clientID}, resources...)
+}
+
+// findResource looks up the service a resource indicator refers to
+func (s *Service) findResource(resource string) (*svc.Service, error) {
+	if resource == "" {
+		return nil, ErrInvalidTarget
+	}
+
+	target, err := s.serviceRepo.FindByClientID(resource)
+	if err == nil {
+		return target, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to find resource service: %w", err)
+	}
+
+	// Resource indicators must not carry a fragment (RFC 8707 Section 2)
+	domain := resource
+	if u, err := url.Parse(resource); err == nil && u.Host != "" {
+		if u.Fragment != "" {
+			return nil, ErrInvalidTarget
+		}
+		domain = u.Hostname()
+	}
+
+	target, err = s.serviceRepo.FindByDomain(domain)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidTarget
+		}
+		return nil, fmt.Errorf("failed to find resource service: %w", err)
+	}
+	return target, nil
+}
+
+// narrowResources applies the resource parameters of a token request to the resources granted
+// earlier, by authorization or with the refresh token. Without parameters the whole grant applies;
+// otherwise every requested resource must have been granted (RFC 8707 Section 2.2).
+func (s *Service) narrowResources(client *svc.Service, granted string, requested []string) ([]string, error) {
+	grantedResources := strings.Fields(granted)
+	if len(requested) == 0 {
+		return grantedResources, nil
+	}
+
+	resolved, err := s.resolveResources(client, requested)
+	if err != nil {
+		return nil, err
+	}
+	for _, resource := range resolved {
+		if !slices.Contains(grantedResources, resource) {
+			return nil, ErrInvalidTarget
+		}
+	}
+	return resolved, nil
+}
@@ -0,0 +1,72 @@
+package oidc
+
+import (
+	"testing"
+
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// serviceDirectory is an in-memory service repository keyed by client_id
+type serviceDirectory struct {
+	svc.Repository
+	services []*svc.Service
+}
+
+func (d *serviceDirectory) FindByClientID(clientID string) (*svc.Service, error) {
+	for _, service := range d.services {
+		if service.ClientID == clientID {
+			return service, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (d *serviceDirectory) FindByDomain(domain string) (*svc.Service, error) {
+	for _, service := range d.services {
+		if service.Domain == domain {
+			return service, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func TestResolveResources(t *testing.T) {
+	billing := &svc.Service{ClientID: "billing", Domain: "billing.example.com", Active: true}
+	reports := &svc.Service{ClientID: "reports", Domain: "reports.example.com", Active: true}
+	legacy := &svc.Service{ClientID: "legacy", Active: false}
+	spa := &svc.Service{ClientID: "spa", Active: true, AllowedResources: []string{"billing", "reports", "legacy"}}
+
+	s := &Service{serviceRepo: &serviceDirectory{services: []*svc.Service{billing, reports, legacy, spa}}}
+
+	t.Run("by client_id and domain", func(t *testing.T) {
+		resources, err := s.resolveResources(spa, []string{"billing", "https://reports.example.com/api", "spa", "billing.example.com"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"billing", "reports"}, resources)
+	})
+
+	t.Run("unknown, inactive and not allowed targets", func(t *testing.T) {
+		for _, resource := range []string{"unknown", "legacy", "https://billing.example.com#frag"} {
+			_, err := s.resolveResources(spa, []string{resource})
+			assert.ErrorIs(t, err, ErrInvalidTarget, resource)
+		}
+
+		_, err := s.resolveResources(reports, []string{"billing"})
+		assert.ErrorIs(t, err, ErrInvalidTarget)
+	})
+
+	t.Run("token requests narrow the grant", func(t *testing.T) {
+		resources, err := s.narrowResources(spa, "billing reports", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"billing", "reports"}, resources)
+
+		resources, err = s.narrowResources(spa, "billing reports", []string{"reports"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"reports"}, resources)
+
+		_, err = s.narrowResources(spa, "billing", []string{"reports"})
+		assert.ErrorIs(t, err, ErrInvalidTarget)
+	})
+}
@@ -0,0 +1,154 @@
+package oidc
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v3/jwt"
+)
+
+// Response modes for authorization responses (OAuth 2.0 Multiple Response Types, OAuth 2.0 Form Post
+// Response Mode, and JWT Secured Authorization Response Mode (JARM))
+const (
+	ResponseModeQuery       = "query"
+	ResponseModeFragment    = "fragment"
+	ResponseModeFormPost    = "form_post"
+	ResponseModeJWT         = "jwt"
+	ResponseModeQueryJWT    = "query.jwt"
+	ResponseModeFragmentJWT = "fragment.jwt"
+	ResponseModeFormPostJWT = "form_post.jwt"
+)
+
+// SupportedResponseModes lists every response_mode accepted on authorization requests
+var SupportedResponseModes = []string{
+	ResponseModeQuery,
+	ResponseModeFragment,
+	ResponseModeFormPost,
+	ResponseModeJWT,
+	ResponseModeQueryJWT,
+	ResponseModeFragmentJWT,
+	ResponseModeFormPostJWT,
+}
+
+// EncodedAuthorizationResponse is an authorization response ready to be sent to the client
+type EncodedAuthorizationResponse struct {
+	// RedirectURI is where the user agent is sent. For form_post modes it is the form action and
+	// carries no parameters.
+	RedirectURI string
+	// FormPost is set when Parameters must be POSTed to RedirectURI by the user agent
+	FormPost   bool
+	Parameters url.Values
+}
+
+// Parameters are the authorization response parameters to encode for the client's response_mode
+func (r *AuthorizeResponse) Parameters() url.Values {
+	params := url.Values{}
+	params.Set("code", r.Code)
+	if r.State != "" {
+		params.Set("state", r.State)
+	}
+	return params
+}
+
+// validateResponseMode rejects unknown response modes. An empty mode means the default for the code flow.
+func validateResponseMode(mode string) error {
+	if mode != "" && !slices.Contains(SupportedResponseModes, mode) {
+		return ErrUnsupportedResponseMode
+	}
+	return nil
+}
+
+// EncodeAuthorizationResponse encodes the parameters of an authorization response (code and state,
+// or an error) for the client's response_mode. JWT modes wrap the parameters in a response JWT
+// signed by the server and audienced to the client (JARM Section 2.1).
+func (s *Service) EncodeAuthorizationResponse(clientID, redirectURI, responseMode string, params url.Values) (*EncodedAuthorizationResponse, error) {
+	if err := validateResponseMode(responseMode); err != nil {
+		return nil, err
+	}
+
+	// "jwt" selects the default JWT mode of the response type, which is query.jwt for code
+	if responseMode == ResponseModeJWT {
+		responseMode = ResponseModeQueryJWT
+	}
+
+	if strings.HasSuffix(responseMode, ".jwt") {
+		response, err := s.signAuthorizationResponse(clientID, params)
+		if err != nil {
+			return nil, err
+		}
+		params = url.Values{"response": {response}}
+		responseMode = strings.TrimSuffix(responseMode, ".jwt")
+	}
+
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return nil, ErrInvalidRedirectURI
+	}
+
+	switch responseMode {
+	case ResponseModeFormPost:
+		return &EncodedAuthorizationResponse{RedirectURI: u.String(), FormPost: true, Parameters: params}, nil
+	case ResponseModeFragment:
+		u.Fragment = ""
+		return &EncodedAuthorizationResponse{RedirectURI: u.String() + "#" + params.Encode()}, nil
+	default:
+		q := u.Query()
+		for name, values := range params {
+			q[name] = values
+		}
+		u.RawQuery = q.Encode()
+		return &EncodedAuthorizationResponse{RedirectURI: u.String()}, nil
+	}
+}
+
+// signAuthorizationResponse builds the JARM response JWT carrying the authorization response parameters
+func (s *Service) signAuthorizationResponse(clientID string, params url.Values) (string, error) {
+	now := time.Now()
+	builder := jwt.NewBuilder().
+		Issuer(s.authService.Issuer()).
+		Audience([]string{clientID}).
+		IssuedAt(now).
+		Expiration(now.Add(s.codeLifetime))
+
+	for name := range params {
+		builder.Claim(name, params.Get(name))
+	}
+
+	token, err := builder.Build()
+	if err != nil {
+		return "", fmt.Errorf("failed to build authorization response: %w", err)
+	}
+
+	signed, err := s.authService.KeyStore.SignToken(token)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign authorization response: %w", err)
+	}
+
+	return signed, nil
+}
+
+// formPostTemplate auto-submits the authorization response to the client (OAuth 2.0 Form Post Response Mode Section 2)
+var formPostTemplate = template.Must(template.New("form_post").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Submit This Form</title></head>
+<body onload="javascript:document.forms[0].submit()">
+<form method="post" action="{{.Action}}">
+{{range $name, $values := .Parameters}}{{range $values}}<input type="hidden" name="{{$name}}" value="{{.}}"/>
+{{end}}{{end}}<noscript><button type="submit">Continue</button></noscript>
+</form>
+</body>
+</html>
+`))
+
+// renderFormPost writes the auto-submitting HTML form for a form_post response
+func renderFormPost(w io.Writer, res *EncodedAuthorizationResponse) error {
+	return formPostTemplate.Execute(w, struct {
+		Action     string
+		Parameters url.Values
+	}{res.RedirectURI, res.Parameters})
+}
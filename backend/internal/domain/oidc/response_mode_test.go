@@ -0,0 +1,45 @@
+package oidc
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeAuthorizationResponse(t *testing.T) {
+	s := &Service{}
+	params := url.Values{"code": {"abc"}, "state": {"x y"}}
+
+	t.Run("query keeps existing parameters", func(t *testing.T) {
+		res, err := s.EncodeAuthorizationResponse("app", "https://app.example.com/cb?tenant=1", "", params)
+		require.NoError(t, err)
+		assert.False(t, res.FormPost)
+		assert.Equal(t, "https://app.example.com/cb?code=abc&state=x+y&tenant=1", res.RedirectURI)
+	})
+
+	t.Run("fragment", func(t *testing.T) {
+		res, err := s.EncodeAuthorizationResponse("app", "https://app.example.com/cb", ResponseModeFragment, params)
+		require.NoError(t, err)
+		assert.Equal(t, "https://app.example.com/cb#code=abc&state=x+y", res.RedirectURI)
+	})
+
+	t.Run("form_post", func(t *testing.T) {
+		res, err := s.EncodeAuthorizationResponse("app", "https://app.example.com/cb", ResponseModeFormPost, params)
+		require.NoError(t, err)
+		assert.True(t, res.FormPost)
+		assert.Equal(t, "https://app.example.com/cb", res.RedirectURI)
+
+		var body bytes.Buffer
+		require.NoError(t, renderFormPost(&body, res))
+		assert.Contains(t, body.String(), `action="https://app.example.com/cb"`)
+		assert.Contains(t, body.String(), `name="code" value="abc"`)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := s.EncodeAuthorizationResponse("app", "https://app.example.com/cb", "web_message", params)
+		assert.ErrorIs(t, err, ErrUnsupportedResponseMode)
+	})
+}
@@ -0,0 +1,84 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+	"github.com/Anvoria/authly/internal/domain/session"
+	"github.com/google/uuid"
+)
+
+// Revoke revokes a refresh token or an access token together with the session backing it (RFC 7009)
+// Access tokens are also revoked by their jti, so that tokens without a session stop working too.
+// Unknown, malformed, or already revoked tokens are not an error: the endpoint must respond
+// identically so that callers cannot probe for valid tokens.
+func (s *Service) Revoke(req *RevocationRequest) error {
+	client, err := s.authenticateClient(req.credentials())
+	if err != nil {
+		return err
+	}
+
+	var sessionID uuid.UUID
+	if isJWT(req.Token) {
+		claims, err := s.authService.KeyStore.Verify(req.Token)
+		if err != nil {
+			return nil
+		}
+
+		// The token must have been issued to the client asking for its revocation
+		if claims.ClientID() != client.ClientID {
+			return ErrUnauthorizedClient
+		}
+
+		if err := s.authService.RevokeAccessToken(claims); err != nil && !errors.Is(err, auth.ErrInvalidToken) {
+			return fmt.Errorf("failed to revoke access token: %w", err)
+		}
+
+		sid := claims.GetSid()
+		if sid == "service-session" {
+			// Client credentials tokens have no backing session
+			return nil
+		}
+
+		sessionID, err = uuid.Parse(sid)
+		if err != nil {
+			return nil
+		}
+	} else {
+		parts := strings.SplitN(req.Token, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil
+		}
+
+		sessionID, err = uuid.Parse(parts[0])
+		if err != nil {
+			return nil
+		}
+
+		// Prove possession of the refresh secret before revoking
+		if _, err := s.sessionService.Validate(sessionID, parts[1]); err != nil {
+			return nil
+		}
+
+		// The refresh token must have been issued to the client asking for its revocation (RFC 7009 Section 2.1)
+		clientIDs, err := s.sessionService.ClientIDs(sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to get session clients: %w", err)
+		}
+		if !slices.Contains(clientIDs, client.ClientID) {
+			return ErrUnauthorizedClient
+		}
+	}
+
+	if err := s.sessionService.Revoke(sessionID); err != nil {
+		if errors.Is(err, session.ErrInvalidSession) {
+			return nil
+		}
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return nil
+}
@@ -3,10 +3,13 @@ package oidc
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
+	"github.com/Anvoria/authly/internal/cache"
 	"github.com/Anvoria/authly/internal/domain/auth"
+	"github.com/Anvoria/authly/internal/domain/consent"
 	"github.com/Anvoria/authly/internal/domain/permission"
 	svc "github.com/Anvoria/authly/internal/domain/service"
 	"github.com/Anvoria/authly/internal/domain/session"
@@ -19,6 +22,11 @@ import (
 type ValidateAuthorizationRequestResponse struct {
 	Valid            bool        `json:"valid"`
 	Client           *ClientInfo `json:"client,omitempty"`
+	Scopes           []string    `json:"scopes,omitempty"` // requested scopes, for requests made by request_uri
+	ConsentGranted   bool        `json:"consent_granted"`  // the user already approved every requested scope
+	LoginRequired    bool        `json:"login_required"`   // the user must authenticate again before confirming
+	LoginHint        string      `json:"login_hint,omitempty"`
+	RequestURI       string      `json:"request_uri,omitempty"` // the stored request to confirm
 	Error            string      `json:"error,omitempty"`
 	ErrorDescription string      `json:"error_description,omitempty"`
 }
@@ -35,13 +43,29 @@ type ClientInfo struct {
 
 // ServiceInterface defines the interface for OIDC operations
 type ServiceInterface interface {
-	Authorize(req *AuthorizeRequest, userID uuid.UUID) (*AuthorizeResponse, error)
-	ExchangeCode(req *TokenRequest, sessionID uuid.UUID, refreshSecret string) (*TokenResponse, error)
+	Authorize(req *AuthorizeRequest, userID, sessionID uuid.UUID, authTime time.Time) (*AuthorizeResponse, error)
+	ConfirmAuthorization(req *AuthorizeRequest, userID, sessionID uuid.UUID, authTime time.Time) (*AuthorizeResponse, error)
+	ExchangeCode(req *TokenRequest) (*TokenResponse, error)
 	RefreshToken(req *TokenRequest) (*TokenResponse, error)
 	ClientCredentialsGrant(req *TokenRequest) (*TokenResponse, error)
 	PasswordGrant(req *TokenRequest) (*TokenResponse, error)
-	GetUserInfo(userID string, scopes []string) (map[string]any, error)
-	ValidateAuthorizationRequest(req *AuthorizeRequest, userID *string) *ValidateAuthorizationRequestResponse
+	GetUserInfo(userID, clientID string, scopes []string, requested map[string]*ClaimRequest) (map[string]any, error)
+	ValidateAuthorizationRequest(req *AuthorizeRequest, userID *string, authTime time.Time) *ValidateAuthorizationRequestResponse
+	Introspect(req *IntrospectionRequest) (*IntrospectionResponse, error)
+	Revoke(req *RevocationRequest) error
+	Logout(req *LogoutRequest, sessionID *uuid.UUID, confirmed bool) (*LogoutResult, error)
+	DeviceAuthorization(req *DeviceAuthorizationRequest) (*DeviceAuthorizationResponse, error)
+	GetDeviceVerification(userCode string) *DeviceVerificationResponse
+	ConfirmDevice(userCode string, userID uuid.UUID, approve bool) error
+	DeviceCodeGrant(req *TokenRequest) (*TokenResponse, error)
+	BackchannelAuthentication(req *BackchannelAuthenticationRequest) (*BackchannelAuthenticationResponse, error)
+	ListBackchannelAuthentications(userID uuid.UUID) ([]*PendingBackchannelAuthentication, error)
+	ConfirmBackchannelAuthentication(requestID string, userID uuid.UUID, approve bool) error
+	CIBAGrant(req *TokenRequest) (*TokenResponse, error)
+	TokenExchangeGrant(req *TokenRequest) (*TokenResponse, error)
+	PushAuthorizationRequest(req *PushedAuthorizationRequest) (*PushedAuthorizationResponse, error)
+	EncodeAuthorizationResponse(clientID, redirectURI, responseMode string, params url.Values) (*EncodedAuthorizationResponse, error)
+	VerifyDPoP(req *TokenRequest) error
 }
 
 // Service handles OIDC operations
@@ -53,11 +77,16 @@ type Service struct {
 	sessionService    session.Service
 	permissionService permission.ServiceInterface
 	userService       user.Service
+	consentService    consent.Service
+	replayCache       *cache.ReplayCache
+	clientKeys        *clientKeyCache
+	notifier          BackchannelNotifier
 }
 
 // NewService creates a new ServiceInterface wired with the provided repositories and supporting services.
 // The returned Service is configured with a 10-minute authorization code lifetime.
-func NewService(serviceRepo svc.Repository, codeRepo Repository, authService *auth.Service, sessionService session.Service, permissionService permission.ServiceInterface, userService user.Service) ServiceInterface {
+// notifier delivers backchannel authentication requests to users.
+func NewService(serviceRepo svc.Repository, codeRepo Repository, authService *auth.Service, sessionService session.Service, permissionService permission.ServiceInterface, userService user.Service, consentService consent.Service, replayCache *cache.ReplayCache, notifier BackchannelNotifier) ServiceInterface {
 	return &Service{
 		serviceRepo:       serviceRepo,
 		codeRepo:          codeRepo,
@@ -66,11 +95,29 @@ func NewService(serviceRepo svc.Repository, codeRepo Repository, authService *au
 		sessionService:    sessionService,
 		permissionService: permissionService,
 		userService:       userService,
+		consentService:    consentService,
+		replayCache:       replayCache,
+		clientKeys:        newClientKeyCache(),
+		notifier:          notifier,
 	}
 }
 
-// Authorize validates the authorization request and generates an authorization code
-func (s *Service) Authorize(req *AuthorizeRequest, userID uuid.UUID) (*AuthorizeResponse, error) {
+// Authorize validates the authorization request and generates an authorization code without
+// showing the consent screen. userID is uuid.Nil when the user agent has no session; sessionID is
+// that session, which the code is bound to, and authTime is when its user authenticated. Without
+// a stored consent covering the scopes it returns ErrConsentRequired. With prompt=none,
+// login_required and consent_required are returned as an *AuthorizationRedirectError.
+func (s *Service) Authorize(req *AuthorizeRequest, userID, sessionID uuid.UUID, authTime time.Time) (*AuthorizeResponse, error) {
+	return s.authorize(req, userID, sessionID, authTime, false)
+}
+
+// authorize implements Authorize and ConfirmAuthorization; consented skips the consent requirements
+func (s *Service) authorize(req *AuthorizeRequest, userID, sessionID uuid.UUID, authTime time.Time, consented bool) (*AuthorizeResponse, error) {
+	req, err := s.resolvePushedRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate response_type
 	if req.ResponseType != "code" {
 		return nil, ErrInvalidResponseType
@@ -90,6 +137,14 @@ func (s *Service) Authorize(req *AuthorizeRequest, userID uuid.UUID) (*Authorize
 		return nil, ErrClientNotActive
 	}
 
+	if service.RequirePAR && !req.pushed {
+		return nil, ErrPushedAuthorizationRequired
+	}
+
+	if !service.AllowsGrantType("authorization_code") {
+		return nil, ErrUnauthorizedClient
+	}
+
 	// Validate redirect_uri
 	if !s.isValidRedirectURI(service.RedirectURIs, req.RedirectURI) {
 		return nil, ErrInvalidRedirectURI
@@ -101,14 +156,59 @@ func (s *Service) Authorize(req *AuthorizeRequest, userID uuid.UUID) (*Authorize
 		return nil, ErrInvalidScope
 	}
 
+	if err := validateResponseMode(req.ResponseMode); err != nil {
+		return nil, err
+	}
+
+	claims, err := parseClaimsRequest(req.Claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := claims.restrict(service.AllowedScopes); err != nil {
+		return nil, err
+	}
+
+	resources, err := s.resolveResources(service, req.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	reqs, err := parseAuthenticationRequirements(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without interaction the client learns about missing login or consent on its redirect_uri
+	interactionError := func(err error) error {
+		if reqs.noInteraction() && (errors.Is(err, ErrLoginRequired) || errors.Is(err, ErrConsentRequired)) {
+			return &AuthorizationRedirectError{
+				Err:          err,
+				ClientID:     service.ClientID,
+				RedirectURI:  req.RedirectURI,
+				ResponseMode: req.ResponseMode,
+				State:        req.State,
+			}
+		}
+		return err
+	}
+
+	if err := s.checkAuthentication(req, reqs, service.ClientID, userID, authTime); err != nil {
+		return nil, interactionError(err)
+	}
+	if err := s.checkRequestedSubject(claims, service, userID); err != nil {
+		return nil, interactionError(err)
+	}
+
 	// Check if user has any permissions for this service
 	// If no permissions are found, deny access
-	hasPerm, err := s.permissionService.HasAnyPermission(userID.String(), service.ID.String())
-	if err != nil {
-		return nil, fmt.Errorf("failed to check user permissions: %w", err)
+	if err := s.checkUserAccess(userID.String(), service); err != nil {
+		return nil, err
 	}
-	if !hasPerm {
-		return nil, ErrUserAccessDenied
+
+	// Individually requested claims need the same consent as their scopes
+	consentScopes := withClaimScopes(requestedScopes, claims)
+	if err := s.checkConsent(reqs, service.ClientID, userID, consentScopes, consented); err != nil {
+		return nil, interactionError(err)
 	}
 
 	// Validate PKCE if provided
@@ -118,6 +218,21 @@ func (s *Service) Authorize(req *AuthorizeRequest, userID uuid.UUID) (*Authorize
 		}
 	}
 
+	// A request_uri authorizes exactly one code
+	if req.RequestURI != "" {
+		if err := s.codeRepo.MarkPushedAuthorizationUsed(req.RequestURI); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidRequestURI
+			}
+			return nil, fmt.Errorf("failed to mark pushed authorization request as used: %w", err)
+		}
+	}
+
+	encodedClaims, err := claims.encode()
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate authorization code
 	code, err := s.generateAuthorizationCode()
 	if err != nil {
@@ -129,9 +244,13 @@ func (s *Service) Authorize(req *AuthorizeRequest, userID uuid.UUID) (*Authorize
 		Code:          code,
 		ClientID:      req.ClientID,
 		UserID:        userID,
+		SessionID:     sessionID,
+		AuthTime:      authTime,
 		RedirectURI:   req.RedirectURI,
 		Scopes:        strings.Join(requestedScopes, " "),
 		Nonce:         req.Nonce,
+		Claims:        encodedClaims,
+		Resources:     strings.Join(resources, " "),
 		CodeChallenge: req.CodeChallenge,
 		ChallengeMeth: req.CodeChallengeMethod,
 		ExpiresAt:     time.Now().Add(s.codeLifetime),
@@ -143,7 +262,11 @@ func (s *Service) Authorize(req *AuthorizeRequest, userID uuid.UUID) (*Authorize
 	}
 
 	return &AuthorizeResponse{
-		Code:  code,
-		State: req.State,
+		Code:         code,
+		State:        req.State,
+		RedirectURI:  req.RedirectURI,
+		ResponseMode: req.ResponseMode,
+		ClientID:     req.ClientID,
+		Scopes:       consentScopes,
 	}, nil
 }
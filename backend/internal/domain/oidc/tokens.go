@@ -0,0 +1,89 @@
+package oidc
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/google/uuid"
+)
+
+// issueUserTokens mints the access token, refresh token and (for the openid scope) ID token
+// for a user session that has already been authorized for the client. It is shared by the
+// grants that establish a session out-of-band, such as the device authorization grant.
+// A non-empty dpopJKT binds both the access token and the refresh session to that DPoP key.
+// The access token is also addressed to the resource servers in resources (RFC 8707).
+func (s *Service) issueUserTokens(userID, sessionID uuid.UUID, refreshSecret string, service *svc.Service, scopes, resources []string, nonce string, authTime time.Time, dpopJKT string) (*TokenResponse, error) {
+	if err := s.bindSessionDPoP(sessionID, dpopJKT); err != nil {
+		return nil, err
+	}
+	if err := s.sessionService.UpdateResources(sessionID, resources); err != nil {
+		return nil, fmt.Errorf("failed to update session resources: %w", err)
+	}
+
+	clientID := service.ClientID
+	sub, err := s.subjectFor(userID.String(), service)
+	if err != nil {
+		return nil, err
+	}
+
+	var idToken string
+	if slices.Contains(scopes, "openid") {
+		userInfo, err := s.userClaims(userID.String(), sub, scopes, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user info for id token: %w", err)
+		}
+		userInfo["sid"] = sessionID.String()
+
+		idToken, err = s.authService.GenerateIDToken(sub, clientID, nonce, authTime, userInfo, service.IDTokenSignedResponseAlg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate id token: %w", err)
+		}
+	}
+
+	permissions, err := s.permissionService.BuildScopes(userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to build permissions: %w", err)
+	}
+
+	clientPermissions := s.filterPermissionsForClient(permissions, audienceOf(clientID, resources)...)
+
+	pver, err := s.permissionService.GetPermissionVersion(userID.String())
+	if err != nil {
+		pver = 1
+	}
+
+	accessToken, err := s.authService.GenerateAccessToken(
+		sub,
+		sessionID.String(),
+		scopes,
+		clientID,
+		clientID,
+		clientPermissions,
+		pver,
+		append(dpopOptions(dpopJKT), auth.WithResources(resources), passwordAuthentication(authTime))...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	s.trackSessionClient(sessionID, clientID)
+
+	return &TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    tokenType(dpopJKT),
+		ExpiresIn:    900, // 15 minutes in seconds
+		RefreshToken: fmt.Sprintf("%s:%s", sessionID.String(), refreshSecret),
+		Scope:        strings.Join(scopes, " "),
+		IDToken:      idToken,
+	}, nil
+}
+
+// passwordAuthentication records in an access token that the user signed in with their password at
+// authTime. Users only authenticate with passwords, so every user token carries this "amr".
+func passwordAuthentication(authTime time.Time) auth.AccessTokenOption {
+	return auth.WithAuthentication(authTime, "", []string{auth.AMRPassword})
+}
@@ -3,15 +3,36 @@ package oidc
 import (
 	"encoding/base64"
 	"errors"
+	"log/slog"
 	"slices"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 // ValidateAuthorizationRequest validates an OAuth2/OIDC authorization request without requiring authentication
 // Returns a response indicating if the request is valid and includes client information if valid
-func (s *Service) ValidateAuthorizationRequest(req *AuthorizeRequest, userID *string) *ValidateAuthorizationRequestResponse {
+// authTime is when the session's user authenticated and is ignored without a user.
+func (s *Service) ValidateAuthorizationRequest(req *AuthorizeRequest, userID *string, authTime time.Time) *ValidateAuthorizationRequestResponse {
+	return s.validateAuthorizationRequest(req, userID, authTime, true)
+}
+
+// validateAuthorizationRequest implements ValidateAuthorizationRequest. Pushed authorization requests
+// are validated before there is a browser session, so checkSession skips login and consent checks.
+func (s *Service) validateAuthorizationRequest(req *AuthorizeRequest, userID *string, authTime time.Time, checkSession bool) *ValidateAuthorizationRequestResponse {
+	// Swap a request_uri for the parameters pushed behind it
+	req, err := s.resolvePushedRequest(req)
+	if err != nil {
+		oidcErr := MapErrorToOIDC(err)
+		return &ValidateAuthorizationRequestResponse{
+			Valid:            false,
+			Error:            oidcErr.Code,
+			ErrorDescription: oidcErr.Description,
+		}
+	}
+
 	// Validate response_type
 	if req.ResponseType == "" {
 		return &ValidateAuthorizationRequestResponse{
@@ -70,6 +91,22 @@ func (s *Service) ValidateAuthorizationRequest(req *AuthorizeRequest, userID *st
 		}
 	}
 
+	if service.RequirePAR && !req.pushed {
+		return &ValidateAuthorizationRequestResponse{
+			Valid:            false,
+			Error:            "invalid_request",
+			ErrorDescription: "This client must use pushed authorization requests",
+		}
+	}
+
+	if !service.AllowsGrantType("authorization_code") {
+		return &ValidateAuthorizationRequestResponse{
+			Valid:            false,
+			Error:            "unauthorized_client",
+			ErrorDescription: "This client is not registered for the authorization_code grant",
+		}
+	}
+
 	// Check if user has permissions (if user context is available)
 	if userID != nil {
 		hasPerm, err := s.permissionService.HasAnyPermission(*userID, service.ID.String())
@@ -126,6 +163,22 @@ func (s *Service) ValidateAuthorizationRequest(req *AuthorizeRequest, userID *st
 		}
 	}
 
+	if err := validateResponseMode(req.ResponseMode); err != nil {
+		oidcErr := MapErrorToOIDC(err)
+		return &ValidateAuthorizationRequestResponse{
+			Valid:            false,
+			Error:            oidcErr.Code,
+			ErrorDescription: oidcErr.Description,
+			Client: &ClientInfo{
+				ID:            service.ID.String(),
+				Name:          service.Name,
+				RedirectURIs:  service.RedirectURIs,
+				AllowedScopes: service.AllowedScopes,
+				Active:        service.Active,
+			},
+		}
+	}
+
 	// Validate scopes
 	if req.Scope == "" {
 		return &ValidateAuthorizationRequestResponse{
@@ -157,6 +210,43 @@ func (s *Service) ValidateAuthorizationRequest(req *AuthorizeRequest, userID *st
 		}
 	}
 
+	claims, err := parseClaimsRequest(req.Claims)
+	if err == nil {
+		err = claims.restrict(service.AllowedScopes)
+	}
+	if err != nil {
+		oidcErr := MapErrorToOIDC(err)
+		return &ValidateAuthorizationRequestResponse{
+			Valid:            false,
+			Error:            oidcErr.Code,
+			ErrorDescription: oidcErr.Description,
+			Client: &ClientInfo{
+				ID:            service.ID.String(),
+				Name:          service.Name,
+				RedirectURIs:  service.RedirectURIs,
+				AllowedScopes: service.AllowedScopes,
+				Active:        service.Active,
+			},
+		}
+	}
+	requestedScopes = withClaimScopes(requestedScopes, claims)
+
+	if _, err := s.resolveResources(service, req.Resource); err != nil {
+		oidcErr := MapErrorToOIDC(err)
+		return &ValidateAuthorizationRequestResponse{
+			Valid:            false,
+			Error:            oidcErr.Code,
+			ErrorDescription: oidcErr.Description,
+			Client: &ClientInfo{
+				ID:            service.ID.String(),
+				Name:          service.Name,
+				RedirectURIs:  service.RedirectURIs,
+				AllowedScopes: service.AllowedScopes,
+				Active:        service.Active,
+			},
+		}
+	}
+
 	// Validate PKCE if provided
 	if req.CodeChallenge != "" {
 		if err := s.validatePKCE(req.CodeChallenge, req.CodeChallengeMethod); err != nil {
@@ -188,9 +278,97 @@ func (s *Service) ValidateAuthorizationRequest(req *AuthorizeRequest, userID *st
 		}
 	}
 
+	clientInfo := &ClientInfo{
+		ID:            service.ID.String(),
+		Name:          service.Name,
+		RedirectURIs:  service.RedirectURIs,
+		AllowedScopes: service.AllowedScopes,
+		Active:        service.Active,
+	}
+
+	reqs, err := parseAuthenticationRequirements(req)
+	if err != nil {
+		oidcErr := MapErrorToOIDC(err)
+		return &ValidateAuthorizationRequestResponse{
+			Valid:            false,
+			Error:            oidcErr.Code,
+			ErrorDescription: oidcErr.Description,
+			Client:           clientInfo,
+		}
+	}
+
+	if !checkSession {
+		return &ValidateAuthorizationRequestResponse{
+			Valid:  true,
+			Scopes: requestedScopes,
+			Client: clientInfo,
+		}
+	}
+
+	// The consent screen confirms the request by request_uri, so it is stored before the session is checked
+	if req.RequestURI == "" {
+		if err := s.storeAuthorizationRequest(req); err != nil {
+			slog.Error("Failed to store authorization request", "error", err, "client_id", service.ClientID)
+			return &ValidateAuthorizationRequestResponse{
+				Valid:            false,
+				Error:            "server_error",
+				ErrorDescription: "Failed to store authorization request",
+				Client:           clientInfo,
+			}
+		}
+	}
+
+	uid := uuid.Nil
+	if userID != nil {
+		if parsed, err := uuid.Parse(*userID); err == nil {
+			uid = parsed
+		}
+	}
+
+	// A missing or stale session is reported so the frontend sends the user to log in first,
+	// unless the client asked for no interaction at all
+	loginRequired := false
+	if err := s.checkAuthentication(req, reqs, service.ClientID, uid, authTime); err != nil {
+		if !errors.Is(err, ErrLoginRequired) || reqs.noInteraction() {
+			oidcErr := MapErrorToOIDC(err)
+			return &ValidateAuthorizationRequestResponse{
+				Valid:            false,
+				Error:            oidcErr.Code,
+				ErrorDescription: oidcErr.Description,
+				Client:           clientInfo,
+			}
+		}
+		loginRequired = true
+	}
+
+	// Let the frontend skip the consent screen when the user already approved these scopes
+	consentGranted := false
+	if !loginRequired {
+		covered, err := s.consentService.Covers(uid, service.ClientID, requestedScopes)
+		if err != nil {
+			slog.Warn("Failed to check consent", "error", err, "user_id", uid, "client_id", service.ClientID)
+		}
+		consentGranted = covered && !reqs.prompt[PromptConsent]
+
+		if reqs.noInteraction() && !consentGranted {
+			oidcErr := MapErrorToOIDC(ErrConsentRequired)
+			return &ValidateAuthorizationRequestResponse{
+				Valid:            false,
+				Error:            oidcErr.Code,
+				ErrorDescription: oidcErr.Description,
+				Client:           clientInfo,
+			}
+		}
+	}
+
 	// All validations passed
 	return &ValidateAuthorizationRequestResponse{
-		Valid: true,
+		Valid:          true,
+		Scopes:         requestedScopes,
+		ConsentGranted: consentGranted,
+		LoginRequired:  loginRequired,
+		LoginHint:      req.LoginHint,
+		RequestURI:     req.RequestURI,
 		Client: &ClientInfo{
 			ID:            service.ID.String(),
 			Name:          service.Name,
@@ -0,0 +1,27 @@
+package registration
+
+import "errors"
+
+// Error codes for dynamic client registration (RFC 7591 Section 3.2.2, RFC 6750 Section 3.1)
+const (
+	ErrorCodeInvalidRedirectURI    = "invalid_redirect_uri"
+	ErrorCodeInvalidClientMetadata = "invalid_client_metadata"
+	ErrorCodeInvalidToken          = "invalid_token"
+)
+
+var (
+	// ErrRegistrationDisabled is returned when no initial access tokens are configured
+	ErrRegistrationDisabled = errors.New("dynamic client registration is disabled")
+
+	// ErrInvalidInitialAccessToken is returned when the initial access token is missing or unknown
+	ErrInvalidInitialAccessToken = errors.New("invalid initial access token")
+
+	// ErrInvalidRegistrationToken is returned when the registration access token does not match the client
+	ErrInvalidRegistrationToken = errors.New("invalid registration access token")
+
+	// ErrInvalidRedirectURI is returned when a redirect URI is missing or not acceptable
+	ErrInvalidRedirectURI = errors.New("invalid redirect_uri")
+
+	// ErrInvalidClientMetadata is returned when any other metadata value is invalid
+	ErrInvalidClientMetadata = errors.New("invalid client metadata")
+)
@@ -0,0 +1,104 @@
+package registration
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/Anvoria/authly/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Handler serves the dynamic client registration and client configuration endpoints
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a Handler backed by the given registration Service
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Register handles POST /v1/oauth/register (RFC 7591 Section 3.1)
+func (h *Handler) Register(c *fiber.Ctx) error {
+	var metadata ClientMetadata
+	if err := c.BodyParser(&metadata); err != nil {
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidClientMetadata, "malformed client metadata")
+	}
+
+	info, err := h.service.Register(bearerToken(c), &metadata)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	setNoStore(c)
+	return c.Status(fiber.StatusCreated).JSON(info)
+}
+
+// Read handles GET /v1/oauth/register/:client_id (RFC 7592 Section 2.1)
+func (h *Handler) Read(c *fiber.Ctx) error {
+	info, err := h.service.Read(c.Params("client_id"), bearerToken(c))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	setNoStore(c)
+	return c.Status(fiber.StatusOK).JSON(info)
+}
+
+// Update handles PUT /v1/oauth/register/:client_id (RFC 7592 Section 2.2)
+func (h *Handler) Update(c *fiber.Ctx) error {
+	var req UpdateClientRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidClientMetadata, "malformed client metadata")
+	}
+
+	info, err := h.service.Update(c.Params("client_id"), bearerToken(c), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	setNoStore(c)
+	return c.Status(fiber.StatusOK).JSON(info)
+}
+
+// Delete handles DELETE /v1/oauth/register/:client_id (RFC 7592 Section 2.3)
+func (h *Handler) Delete(c *fiber.Ctx) error {
+	if err := h.service.Delete(c.Params("client_id"), bearerToken(c)); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// handleError writes the RFC 7591 error response for err
+func (h *Handler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrRegistrationDisabled):
+		return utils.OIDCErrorResponse(c, "access_denied", err.Error(), fiber.StatusForbidden)
+	case errors.Is(err, ErrInvalidInitialAccessToken), errors.Is(err, ErrInvalidRegistrationToken):
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidToken, err.Error(), fiber.StatusUnauthorized)
+	case errors.Is(err, ErrInvalidRedirectURI):
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidRedirectURI, err.Error())
+	case errors.Is(err, ErrInvalidClientMetadata):
+		return utils.OIDCErrorResponse(c, ErrorCodeInvalidClientMetadata, err.Error())
+	default:
+		slog.Error("Client registration failed", "error", err)
+		return utils.OIDCErrorResponse(c, "server_error", "Internal server error", fiber.StatusInternalServerError)
+	}
+}
+
+// bearerToken extracts the token of a Bearer Authorization header
+func bearerToken(c *fiber.Ctx) string {
+	scheme, token, ok := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+func setNoStore(c *fiber.Ctx) {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+}
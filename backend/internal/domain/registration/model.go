@@ -0,0 +1,75 @@
+package registration
+
+import (
+	"encoding/json"
+	"strings"
+
+	svc "github.com/Anvoria/authly/internal/domain/service"
+)
+
+// ClientMetadata is the metadata a client registers or updates (RFC 7591 Section 2)
+type ClientMetadata struct {
+	RedirectURIs             []string        `json:"redirect_uris,omitempty"`
+	PostLogoutRedirectURIs   []string        `json:"post_logout_redirect_uris,omitempty"`
+	GrantTypes               []string        `json:"grant_types,omitempty"`
+	ResponseTypes            []string        `json:"response_types,omitempty"`
+	TokenEndpointAuthMethod  string          `json:"token_endpoint_auth_method,omitempty"`
+	JWKSURI                  string          `json:"jwks_uri,omitempty"`
+	JWKS                     json.RawMessage `json:"jwks,omitempty"`
+	ClientName               string          `json:"client_name,omitempty"`
+	LogoURI                  string          `json:"logo_uri,omitempty"`
+	Scope                    string          `json:"scope,omitempty"`
+	BackchannelLogoutURI     string          `json:"backchannel_logout_uri,omitempty"`
+	SubjectType              string          `json:"subject_type,omitempty"`
+	IDTokenSignedResponseAlg string          `json:"id_token_signed_response_alg,omitempty"`
+}
+
+// UpdateClientRequest replaces the metadata of a registered client (RFC 7592 Section 2.2)
+type UpdateClientRequest struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	ClientMetadata
+}
+
+// ClientInformation is returned on registration and by the client configuration endpoint
+// (RFC 7591 Section 3.2.1, RFC 7592 Section 3)
+type ClientInformation struct {
+	ClientID                string `json:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64  `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   int64  `json:"client_secret_expires_at"`
+	RegistrationAccessToken string `json:"registration_access_token"`
+	RegistrationClientURI   string `json:"registration_client_uri"`
+	ClientMetadata
+}
+
+// newClientInformation describes a registered service in RFC 7591 terms
+func newClientInformation(service *svc.Service, registrationToken, registrationURI string) *ClientInformation {
+	info := &ClientInformation{
+		ClientID:                service.ClientID,
+		ClientSecret:            service.ClientSecret,
+		ClientIDIssuedAt:        service.CreatedAt.Unix(),
+		ClientSecretExpiresAt:   0, // secrets do not expire
+		RegistrationAccessToken: registrationToken,
+		RegistrationClientURI:   registrationURI,
+		ClientMetadata: ClientMetadata{
+			RedirectURIs:             service.RedirectURIs,
+			PostLogoutRedirectURIs:   service.PostLogoutRedirectURIs,
+			GrantTypes:               service.GrantTypes,
+			ResponseTypes:            []string{"code"},
+			TokenEndpointAuthMethod:  service.TokenEndpointAuthMethod,
+			JWKSURI:                  service.JWKSURI,
+			ClientName:               service.Name,
+			LogoURI:                  service.LogoURI,
+			Scope:                    strings.Join(service.AllowedScopes, " "),
+			BackchannelLogoutURI:     service.BackchannelLogoutURI,
+			SubjectType:              service.SubjectType,
+			IDTokenSignedResponseAlg: service.IDTokenSignedResponseAlg,
+		},
+	}
+	if service.JWKS != "" {
+		info.JWKS = json.RawMessage(service.JWKS)
+	}
+
+	return info
+}
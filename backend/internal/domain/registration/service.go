@@ -0,0 +1,336 @@
+package registration
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	svc "github.com/Anvoria/authly/internal/domain/service"
+)
+
+// supportedScopes are the scopes a dynamically registered client may ask for
+var supportedScopes = []string{"openid", "profile", "email"}
+
+// Service creates and manages clients through dynamic client registration (RFC 7591) and the
+// client configuration endpoint (RFC 7592). Registration requires one of the configured initial
+// access tokens; each registered client is then managed with its own registration access token,
+// which is rotated on every read and update because only its hash is stored.
+type Service struct {
+	services      svc.ServiceInterface
+	initialTokens []string
+	issuer        string
+}
+
+// NewService creates a registration Service. An empty initialTokens disables registration.
+func NewService(services svc.ServiceInterface, initialTokens []string, issuer string) *Service {
+	return &Service{
+		services:      services,
+		initialTokens: initialTokens,
+		issuer:        issuer,
+	}
+}
+
+// Register creates a client from the given metadata
+func (s *Service) Register(initialToken string, metadata *ClientMetadata) (*ClientInformation, error) {
+	if len(s.initialTokens) == 0 {
+		return nil, ErrRegistrationDisabled
+	}
+	if !s.isInitialToken(initialToken) {
+		return nil, ErrInvalidInitialAccessToken
+	}
+
+	if err := normalizeMetadata(metadata); err != nil {
+		return nil, err
+	}
+
+	token, hash, err := generateRegistrationToken()
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := s.services.Create(&svc.CreateServiceRequest{
+		Slug:                        slugify(metadata.ClientName),
+		Name:                        metadata.ClientName,
+		RedirectURIs:                metadata.RedirectURIs,
+		PostLogoutRedirectURIs:      metadata.PostLogoutRedirectURIs,
+		AllowedScopes:               strings.Fields(metadata.Scope),
+		GrantTypes:                  metadata.GrantTypes,
+		LogoURI:                     metadata.LogoURI,
+		BackchannelLogoutURI:        metadata.BackchannelLogoutURI,
+		TokenEndpointAuthMethod:     metadata.TokenEndpointAuthMethod,
+		JWKSURI:                     metadata.JWKSURI,
+		JWKS:                        metadata.JWKS,
+		SubjectType:                 metadata.SubjectType,
+		IDTokenSignedResponseAlg:    metadata.IDTokenSignedResponseAlg,
+		RegistrationAccessTokenHash: hash,
+	})
+	if err != nil {
+		return nil, mapServiceError(err)
+	}
+
+	return newClientInformation(service, token, s.clientURI(service.ClientID)), nil
+}
+
+// Read returns the current configuration of a client and a fresh registration access token
+func (s *Service) Read(clientID, registrationToken string) (*ClientInformation, error) {
+	service, err := s.findClient(clientID, registrationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.rotate(service, &svc.UpdateServiceRequest{})
+}
+
+// Update replaces the metadata of a client. Omitted fields are cleared (RFC 7592 Section 2.2).
+func (s *Service) Update(clientID, registrationToken string, req *UpdateClientRequest) (*ClientInformation, error) {
+	service, err := s.findClient(clientID, registrationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ClientID != service.ClientID {
+		return nil, fmt.Errorf("%w: client_id does not match", ErrInvalidClientMetadata)
+	}
+	if req.ClientSecret != "" && subtle.ConstantTimeCompare([]byte(req.ClientSecret), []byte(service.ClientSecret)) != 1 {
+		return nil, fmt.Errorf("%w: client_secret does not match", ErrInvalidClientMetadata)
+	}
+
+	metadata := &req.ClientMetadata
+	if err := normalizeMetadata(metadata); err != nil {
+		return nil, err
+	}
+
+	scopes := strings.Fields(metadata.Scope)
+	jwks := metadata.JWKS
+	return s.rotate(service, &svc.UpdateServiceRequest{
+		Name:                     &metadata.ClientName,
+		LogoURI:                  &metadata.LogoURI,
+		RedirectURIs:             &metadata.RedirectURIs,
+		PostLogoutRedirectURIs:   &metadata.PostLogoutRedirectURIs,
+		AllowedScopes:            &scopes,
+		GrantTypes:               &metadata.GrantTypes,
+		BackchannelLogoutURI:     &metadata.BackchannelLogoutURI,
+		TokenEndpointAuthMethod:  &metadata.TokenEndpointAuthMethod,
+		JWKSURI:                  &metadata.JWKSURI,
+		JWKS:                     &jwks,
+		SubjectType:              &metadata.SubjectType,
+		IDTokenSignedResponseAlg: &metadata.IDTokenSignedResponseAlg,
+	})
+}
+
+// Delete deregisters a client (RFC 7592 Section 2.3)
+func (s *Service) Delete(clientID, registrationToken string) error {
+	service, err := s.findClient(clientID, registrationToken)
+	if err != nil {
+		return err
+	}
+
+	return s.services.Delete(service.ID.String())
+}
+
+// rotate applies an update together with a new registration access token
+func (s *Service) rotate(service *svc.Service, req *svc.UpdateServiceRequest) (*ClientInformation, error) {
+	token, hash, err := generateRegistrationToken()
+	if err != nil {
+		return nil, err
+	}
+	req.RegistrationAccessTokenHash = &hash
+
+	updated, err := s.services.Update(service.ID.String(), req)
+	if err != nil {
+		return nil, mapServiceError(err)
+	}
+
+	return newClientInformation(updated, token, s.clientURI(updated.ClientID)), nil
+}
+
+// findClient resolves a client by its client_id and registration access token. Unknown clients
+// and clients that were not dynamically registered are indistinguishable from a wrong token.
+func (s *Service) findClient(clientID, registrationToken string) (*svc.Service, error) {
+	if clientID == "" || registrationToken == "" {
+		return nil, ErrInvalidRegistrationToken
+	}
+
+	service, err := s.services.FindByClientID(clientID)
+	if err != nil {
+		if errors.Is(err, svc.ErrServiceNotFound) {
+			return nil, ErrInvalidRegistrationToken
+		}
+		return nil, err
+	}
+
+	if service.RegistrationAccessTokenHash == "" || service.IsSystem {
+		return nil, ErrInvalidRegistrationToken
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(registrationToken)), []byte(service.RegistrationAccessTokenHash)) != 1 {
+		return nil, ErrInvalidRegistrationToken
+	}
+
+	return service, nil
+}
+
+// isInitialToken compares the token against every configured initial access token in constant time
+func (s *Service) isInitialToken(token string) bool {
+	if token == "" {
+		return false
+	}
+
+	match := 0
+	for _, candidate := range s.initialTokens {
+		match |= subtle.ConstantTimeCompare([]byte(candidate), []byte(token))
+	}
+	return match == 1
+}
+
+// clientURI is the client configuration endpoint of a client
+func (s *Service) clientURI(clientID string) string {
+	return s.issuer + "/v1/oauth/register/" + url.PathEscape(clientID)
+}
+
+// normalizeMetadata validates client metadata and fills in the defaults of RFC 7591 Section 2
+func normalizeMetadata(metadata *ClientMetadata) error {
+	if len(metadata.GrantTypes) == 0 {
+		metadata.GrantTypes = []string{"authorization_code"}
+	}
+	if metadata.TokenEndpointAuthMethod == "" {
+		metadata.TokenEndpointAuthMethod = svc.AuthMethodClientSecretBasic
+	}
+	if metadata.SubjectType == "" {
+		metadata.SubjectType = svc.SubjectTypePublic
+	}
+	for _, responseType := range metadata.ResponseTypes {
+		if responseType != "code" {
+			return fmt.Errorf("%w: unsupported response_type %q", ErrInvalidClientMetadata, responseType)
+		}
+	}
+	metadata.ResponseTypes = nil
+
+	if slices.Contains(metadata.GrantTypes, "authorization_code") && len(metadata.RedirectURIs) == 0 {
+		return fmt.Errorf("%w: redirect_uris is required for the authorization_code grant", ErrInvalidRedirectURI)
+	}
+	for _, uri := range metadata.RedirectURIs {
+		if !isValidRedirectURI(uri) {
+			return fmt.Errorf("%w: %s", ErrInvalidRedirectURI, uri)
+		}
+	}
+	for _, uri := range metadata.PostLogoutRedirectURIs {
+		if !isValidRedirectURI(uri) {
+			return fmt.Errorf("%w: post_logout_redirect_uri %s", ErrInvalidClientMetadata, uri)
+		}
+	}
+
+	for name, uri := range map[string]string{"logo_uri": metadata.LogoURI, "backchannel_logout_uri": metadata.BackchannelLogoutURI} {
+		if uri != "" && !isHTTPSURI(uri) {
+			return fmt.Errorf("%w: %s must be an https URL", ErrInvalidClientMetadata, name)
+		}
+	}
+
+	scopes := strings.Fields(metadata.Scope)
+	if len(scopes) == 0 {
+		scopes = supportedScopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(supportedScopes, scope) {
+			return fmt.Errorf("%w: unsupported scope %q", ErrInvalidClientMetadata, scope)
+		}
+	}
+	metadata.Scope = strings.Join(scopes, " ")
+
+	if metadata.ClientName == "" {
+		metadata.ClientName = "Dynamic client"
+	}
+
+	return nil
+}
+
+// isValidRedirectURI accepts absolute URIs without a fragment. Plain http is only allowed for
+// loopback addresses, which native apps use (RFC 8252 Section 7.3).
+func isValidRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		host := u.Hostname()
+		if host == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	default:
+		// Private-use URI schemes of native apps (RFC 8252 Section 7.1)
+		return strings.Contains(u.Scheme, ".")
+	}
+}
+
+func isHTTPSURI(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
+
+// mapServiceError turns metadata validation failures of the service package into registration errors
+func mapServiceError(err error) error {
+	switch {
+	case errors.Is(err, svc.ErrInvalidTokenEndpointAuthMethod),
+		errors.Is(err, svc.ErrInvalidJWKS),
+		errors.Is(err, svc.ErrInvalidGrantType),
+		errors.Is(err, svc.ErrInvalidSubjectType),
+		errors.Is(err, svc.ErrInvalidIDTokenSigningAlg):
+		return fmt.Errorf("%w: %s", ErrInvalidClientMetadata, err.Error())
+	case errors.Is(err, svc.ErrInvalidSectorIdentifier):
+		// Registered clients have no sector_identifier_uri, so their redirect URIs must share a host
+		return fmt.Errorf("%w: pairwise clients need redirect_uris on a single host", ErrInvalidRedirectURI)
+	default:
+		return err
+	}
+}
+
+// slugify derives the client_id slug from the client name
+func slugify(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+		if b.Len() >= 24 {
+			break
+		}
+	}
+
+	slug := strings.Trim(b.String(), "-")
+	if slug == "" {
+		return "client"
+	}
+	return slug
+}
+
+// generateRegistrationToken returns a new registration access token and the hash that is stored
+func generateRegistrationToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate registration access token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,62 @@
+package registration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeMetadata(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		metadata := &ClientMetadata{RedirectURIs: []string{"https://app.example.com/cb"}}
+
+		require.NoError(t, normalizeMetadata(metadata))
+		assert.Equal(t, []string{"authorization_code"}, metadata.GrantTypes)
+		assert.Equal(t, "client_secret_basic", metadata.TokenEndpointAuthMethod)
+		assert.Equal(t, "openid profile email", metadata.Scope)
+	})
+
+	t.Run("authorization_code requires redirect_uris", func(t *testing.T) {
+		err := normalizeMetadata(&ClientMetadata{})
+		assert.ErrorIs(t, err, ErrInvalidRedirectURI)
+	})
+
+	t.Run("client_credentials needs no redirect_uris", func(t *testing.T) {
+		err := normalizeMetadata(&ClientMetadata{GrantTypes: []string{"client_credentials"}})
+		assert.NoError(t, err)
+	})
+
+	t.Run("unsupported scope", func(t *testing.T) {
+		err := normalizeMetadata(&ClientMetadata{
+			RedirectURIs: []string{"https://app.example.com/cb"},
+			Scope:        "openid admin",
+		})
+		assert.ErrorIs(t, err, ErrInvalidClientMetadata)
+	})
+
+	t.Run("implicit response type", func(t *testing.T) {
+		err := normalizeMetadata(&ClientMetadata{
+			RedirectURIs:  []string{"https://app.example.com/cb"},
+			ResponseTypes: []string{"token"},
+		})
+		assert.ErrorIs(t, err, ErrInvalidClientMetadata)
+	})
+}
+
+func TestIsValidRedirectURI(t *testing.T) {
+	assert.True(t, isValidRedirectURI("https://app.example.com/cb"))
+	assert.True(t, isValidRedirectURI("http://127.0.0.1:8080/cb"))
+	assert.True(t, isValidRedirectURI("http://localhost/cb"))
+	assert.True(t, isValidRedirectURI("com.example.app:/cb"))
+	assert.False(t, isValidRedirectURI("http://app.example.com/cb"))
+	assert.False(t, isValidRedirectURI("https://app.example.com/cb#frag"))
+	assert.False(t, isValidRedirectURI("/relative"))
+	assert.False(t, isValidRedirectURI("javascript:alert(1)"))
+}
+
+func TestSlugify(t *testing.T) {
+	assert.Equal(t, "my-cool-app", slugify("My Cool App!"))
+	assert.Equal(t, "client", slugify("***"))
+	assert.LessOrEqual(t, len(slugify("a very long application name that keeps going")), 24)
+}
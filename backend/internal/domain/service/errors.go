@@ -17,4 +17,22 @@ var (
 
 	// ErrCannotUpdateSystemService is returned when trying to update critical fields of a system service
 	ErrCannotUpdateSystemService = errors.New("cannot update system service")
+
+	// ErrInvalidTokenEndpointAuthMethod is returned when the token endpoint auth method is not supported
+	ErrInvalidTokenEndpointAuthMethod = errors.New("unsupported token_endpoint_auth_method")
+
+	// ErrInvalidJWKS is returned when private_key_jwt keys are missing, ambiguous, or malformed
+	ErrInvalidJWKS = errors.New("private_key_jwt requires exactly one of a valid jwks or an https jwks_uri")
+
+	// ErrInvalidGrantType is returned when a registered grant type is not supported by the token endpoint
+	ErrInvalidGrantType = errors.New("unsupported grant_type")
+
+	// ErrInvalidSubjectType is returned when the subject type is neither public nor pairwise
+	ErrInvalidSubjectType = errors.New("subject_type must be public or pairwise")
+
+	// ErrInvalidSectorIdentifier is returned when a pairwise service has no usable sector identifier
+	ErrInvalidSectorIdentifier = errors.New("pairwise services with redirect_uris on several hosts need a sector_identifier host")
+
+	// ErrInvalidIDTokenSigningAlg is returned when the ID token signing algorithm is not supported
+	ErrInvalidIDTokenSigningAlg = errors.New("id_token_signed_response_alg is not provided by any signing key")
 )
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"errors"
+
 	"github.com/Anvoria/authly/internal/utils"
 	"github.com/gofiber/fiber/v2"
 )
@@ -14,16 +16,19 @@ func NewHandler(s ServiceInterface) *Handler {
 	return &Handler{serviceService: s}
 }
 
+// isValidationError reports whether err rejects the client metadata of a create or update request
+func isValidationError(err error) bool {
+	return errors.Is(err, ErrInvalidTokenEndpointAuthMethod) ||
+		errors.Is(err, ErrInvalidJWKS) ||
+		errors.Is(err, ErrInvalidGrantType) ||
+		errors.Is(err, ErrInvalidSubjectType) ||
+		errors.Is(err, ErrInvalidSectorIdentifier) ||
+		errors.Is(err, ErrInvalidIDTokenSigningAlg)
+}
+
 // CreateService handles the creation of a new service
 func (h *Handler) CreateService(c *fiber.Ctx) error {
-	var req struct {
-		Slug          string   `json:"slug"`
-		Name          string   `json:"name"`
-		Description   string   `json:"description"`
-		Domain        string   `json:"domain"`
-		RedirectURIs  []string `json:"redirect_uris"`
-		AllowedScopes []string `json:"allowed_scopes"`
-	}
+	var req CreateServiceRequest
 
 	if err := c.BodyParser(&req); err != nil {
 		return utils.ErrorResponse(c, utils.NewAPIError("INVALID_BODY", "Invalid request body", fiber.StatusBadRequest))
@@ -36,11 +41,14 @@ func (h *Handler) CreateService(c *fiber.Ctx) error {
 		return utils.ErrorResponse(c, utils.NewAPIError("VALIDATION_ERROR", "Name is required", fiber.StatusBadRequest))
 	}
 
-	svc, err := h.serviceService.Create(req.Slug, req.Name, req.Description, req.Domain, req.RedirectURIs, req.AllowedScopes)
+	svc, err := h.serviceService.Create(&req)
 	if err != nil {
 		if err == ErrServiceClientIDExists || err == ErrServiceDomainExists {
 			return utils.ErrorResponse(c, utils.NewAPIError("DUPLICATE_RESOURCE", err.Error(), fiber.StatusConflict))
 		}
+		if isValidationError(err) {
+			return utils.ErrorResponse(c, utils.NewAPIError("VALIDATION_ERROR", err.Error(), fiber.StatusBadRequest))
+		}
 		return utils.ErrorResponse(c, utils.NewAPIError("INTERNAL_SERVER_ERROR", err.Error(), fiber.StatusInternalServerError))
 	}
 
@@ -124,22 +132,20 @@ func (h *Handler) UpdateService(c *fiber.Ctx) error {
 		return utils.ErrorResponse(c, utils.NewAPIError("VALIDATION_ERROR", "ID is required", fiber.StatusBadRequest))
 	}
 
-	var req struct {
-		Name        *string `json:"name"`
-		Description *string `json:"description"`
-		Domain      *string `json:"domain"`
-		Active      *bool   `json:"active"`
-	}
+	var req UpdateServiceRequest
 
 	if err := c.BodyParser(&req); err != nil {
 		return utils.ErrorResponse(c, utils.NewAPIError("INVALID_BODY", "Invalid request body", fiber.StatusBadRequest))
 	}
 
-	svc, err := h.serviceService.Update(id, req.Name, req.Description, req.Domain, req.Active)
+	svc, err := h.serviceService.Update(id, &req)
 	if err != nil {
 		if err == ErrServiceNotFound {
 			return utils.ErrorResponse(c, utils.NewAPIError("RESOURCE_NOT_FOUND", err.Error(), fiber.StatusNotFound))
 		}
+		if isValidationError(err) {
+			return utils.ErrorResponse(c, utils.NewAPIError("VALIDATION_ERROR", err.Error(), fiber.StatusBadRequest))
+		}
 		if err == ErrCannotUpdateSystemService {
 			return utils.ErrorResponse(c, utils.NewAPIError("FORBIDDEN", err.Error(), fiber.StatusForbidden))
 		}
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"encoding/json"
+	"net/url"
+	"slices"
 	"time"
 
 	"github.com/Anvoria/authly/internal/database"
@@ -14,6 +17,36 @@ const DefaultAuthlyServiceID = "00000000-0000-0000-0000-000000000001"
 // DefaultAuthlyClientID is the client_id for the default authly service
 const DefaultAuthlyClientID = "authly_authly_00000000"
 
+// Client authentication methods at the token endpoint (OIDC Core Section 9)
+const (
+	AuthMethodNone              = "none"
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+	AuthMethodClientSecretJWT   = "client_secret_jwt"
+	AuthMethodPrivateKeyJWT     = "private_key_jwt"
+)
+
+// Subject identifier types (OIDC Core Section 8)
+const (
+	SubjectTypePublic   = "public"
+	SubjectTypePairwise = "pairwise"
+)
+
+// SupportedIDTokenSigningAlgs are the algorithms a service may ask its ID tokens to be signed with
+// A service may only pick one that a loaded signing key provides
+var SupportedIDTokenSigningAlgs = []string{"RS256", "PS256", "ES256", "ES384", "EdDSA"}
+
+// SupportedGrantTypes are the grant types a service may be registered for
+var SupportedGrantTypes = []string{
+	"authorization_code",
+	"refresh_token",
+	"password",
+	"client_credentials",
+	"urn:ietf:params:oauth:grant-type:device_code",
+	"urn:ietf:params:oauth:grant-type:token-exchange",
+	"urn:openid:params:grant-type:ciba",
+}
+
 // Service represents a service in the system
 type Service struct {
 	database.BaseModel
@@ -25,11 +58,38 @@ type Service struct {
 	// Metadata
 	Name        string `gorm:"column:name;not null;size:255"`
 	Description string `gorm:"column:description;type:text"`
+	LogoURI     string `gorm:"column:logo_uri;type:text"`
 
 	// Security
-	Domain        string         `gorm:"column:domain;unique;size:255"`
-	RedirectURIs  pq.StringArray `gorm:"type:text[]"`
-	AllowedScopes pq.StringArray `gorm:"type:text[]"`
+	Domain                 string         `gorm:"column:domain;unique;size:255"`
+	RedirectURIs           pq.StringArray `gorm:"type:text[]"`
+	PostLogoutRedirectURIs pq.StringArray `gorm:"column:post_logout_redirect_uris;type:text[]"`
+	AllowedScopes          pq.StringArray `gorm:"type:text[]"`
+	GrantTypes             pq.StringArray `gorm:"column:grant_types;type:text[]"` // empty allows every grant
+
+	// AllowedResources are the client_ids of the resource servers the service may request access tokens for (RFC 8707)
+	AllowedResources pq.StringArray `gorm:"column:allowed_resources;type:text[]"`
+
+	// Logout
+	BackchannelLogoutURI string `gorm:"column:backchannel_logout_uri;type:text"`
+
+	// Client authentication
+	TokenEndpointAuthMethod string `gorm:"column:token_endpoint_auth_method;type:varchar(50);not null;default:client_secret_basic"`
+	JWKSURI                 string `gorm:"column:jwks_uri;type:text"`
+	JWKS                    string `gorm:"column:jwks;type:text"` // inline JWK Set (JSON) for private_key_jwt
+
+	// Subject identifiers: pairwise services get a per-sector sub instead of the user ID (OIDC Core Section 8.1)
+	SubjectType      string `gorm:"column:subject_type;type:varchar(20);not null;default:public"`
+	SectorIdentifier string `gorm:"column:sector_identifier;type:varchar(255)"`
+
+	// IDTokenSignedResponseAlg selects the algorithm ID tokens are signed with; empty uses the active key (OIDC Registration Section 2)
+	IDTokenSignedResponseAlg string `gorm:"column:id_token_signed_response_alg;type:varchar(10)"`
+
+	// RequirePAR rejects authorization requests not pushed through the PAR endpoint (RFC 9126)
+	RequirePAR bool `gorm:"column:require_pushed_authorization_requests;not null;default:false"`
+
+	// RegistrationAccessTokenHash is the SHA-256 of the token that manages a dynamically registered client (RFC 7592)
+	RegistrationAccessTokenHash string `gorm:"column:registration_access_token_hash;type:varchar(64)"`
 
 	// Flags
 	Active   bool `gorm:"column:active;default:true"`
@@ -40,36 +100,161 @@ func (Service) TableName() string {
 	return "services"
 }
 
+// AllowsGrantType reports whether the service may use the grant type at the token endpoint
+// Services without registered grant types keep accepting every grant
+func (s *Service) AllowsGrantType(grantType string) bool {
+	return len(s.GrantTypes) == 0 || slices.Contains(s.GrantTypes, grantType)
+}
+
+// AllowsResource reports whether the service may request access tokens for the resource server
+// with the client_id. Every service may address itself.
+func (s *Service) AllowsResource(clientID string) bool {
+	return clientID == s.ClientID || slices.Contains(s.AllowedResources, clientID)
+}
+
+// IsPairwise reports whether the service receives pairwise subject identifiers
+func (s *Service) IsPairwise() bool {
+	return s.SubjectType == SubjectTypePairwise
+}
+
+// Sector returns the sector identifier pairwise subjects are derived from: the configured
+// sector_identifier, or else the host shared by the redirect URIs. Services without redirect URIs
+// form a sector of their own.
+func (s *Service) Sector() string {
+	if s.SectorIdentifier != "" {
+		return s.SectorIdentifier
+	}
+	if hosts := redirectHosts(s.RedirectURIs); len(hosts) > 0 {
+		return hosts[0]
+	}
+	return s.ClientID
+}
+
+// redirectHosts returns the distinct hosts of the redirect URIs
+func redirectHosts(redirectURIs []string) []string {
+	var hosts []string
+	for _, uri := range redirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		if !slices.Contains(hosts, u.Host) {
+			hosts = append(hosts, u.Host)
+		}
+	}
+	return hosts
+}
+
 // ServiceResponse represents a safe service response
 type ServiceResponse struct {
-	ID            uuid.UUID      `json:"id"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	ClientID      string         `json:"client_id"`
-	ClientSecret  string         `json:"client_secret"`
-	RedirectURIs  pq.StringArray `json:"redirect_uris"`
-	AllowedScopes pq.StringArray `json:"allowed_scopes"`
-	Active        bool           `json:"active"`
-	IsSystem      bool           `json:"is_system"`
-	Name          string         `json:"name"`
-	Description   string         `json:"description"`
-	Domain        string         `json:"domain"`
+	ID                       uuid.UUID       `json:"id"`
+	CreatedAt                time.Time       `json:"created_at"`
+	UpdatedAt                time.Time       `json:"updated_at"`
+	ClientID                 string          `json:"client_id"`
+	ClientSecret             string          `json:"client_secret"`
+	RedirectURIs             pq.StringArray  `json:"redirect_uris"`
+	PostLogoutRedirectURIs   pq.StringArray  `json:"post_logout_redirect_uris"`
+	AllowedScopes            pq.StringArray  `json:"allowed_scopes"`
+	GrantTypes               pq.StringArray  `json:"grant_types"`
+	AllowedResources         pq.StringArray  `json:"allowed_resources"`
+	BackchannelLogoutURI     string          `json:"backchannel_logout_uri,omitempty"`
+	TokenEndpointAuthMethod  string          `json:"token_endpoint_auth_method"`
+	JWKSURI                  string          `json:"jwks_uri,omitempty"`
+	JWKS                     json.RawMessage `json:"jwks,omitempty"`
+	RequirePAR               bool            `json:"require_pushed_authorization_requests"`
+	SubjectType              string          `json:"subject_type"`
+	SectorIdentifier         string          `json:"sector_identifier,omitempty"`
+	IDTokenSignedResponseAlg string          `json:"id_token_signed_response_alg,omitempty"`
+	Active                   bool            `json:"active"`
+	IsSystem                 bool            `json:"is_system"`
+	Name                     string          `json:"name"`
+	Description              string          `json:"description"`
+	LogoURI                  string          `json:"logo_uri,omitempty"`
+	Domain                   string          `json:"domain"`
 }
 
 // ToResponse converts a Service to ServiceResponse
 func (s *Service) ToResponse() *ServiceResponse {
-	return &ServiceResponse{
-		ID:            s.ID,
-		CreatedAt:     s.CreatedAt,
-		UpdatedAt:     s.UpdatedAt,
-		ClientID:      s.ClientID,
-		ClientSecret:  s.ClientSecret,
-		RedirectURIs:  s.RedirectURIs,
-		AllowedScopes: s.AllowedScopes,
-		Name:          s.Name,
-		Description:   s.Description,
-		Domain:        s.Domain,
-		Active:        s.Active,
-		IsSystem:      s.IsSystem,
+	res := &ServiceResponse{
+		ID:                       s.ID,
+		CreatedAt:                s.CreatedAt,
+		UpdatedAt:                s.UpdatedAt,
+		ClientID:                 s.ClientID,
+		ClientSecret:             s.ClientSecret,
+		RedirectURIs:             s.RedirectURIs,
+		PostLogoutRedirectURIs:   s.PostLogoutRedirectURIs,
+		AllowedScopes:            s.AllowedScopes,
+		GrantTypes:               s.GrantTypes,
+		AllowedResources:         s.AllowedResources,
+		BackchannelLogoutURI:     s.BackchannelLogoutURI,
+		TokenEndpointAuthMethod:  s.TokenEndpointAuthMethod,
+		JWKSURI:                  s.JWKSURI,
+		RequirePAR:               s.RequirePAR,
+		SubjectType:              s.SubjectType,
+		SectorIdentifier:         s.SectorIdentifier,
+		IDTokenSignedResponseAlg: s.IDTokenSignedResponseAlg,
+		Name:                     s.Name,
+		Description:              s.Description,
+		LogoURI:                  s.LogoURI,
+		Domain:                   s.Domain,
+		Active:                   s.Active,
+		IsSystem:                 s.IsSystem,
 	}
+
+	if s.JWKS != "" {
+		res.JWKS = json.RawMessage(s.JWKS)
+	}
+
+	return res
+}
+
+// CreateServiceRequest holds the fields accepted when registering a service
+type CreateServiceRequest struct {
+	Slug                     string          `json:"slug"`
+	Name                     string          `json:"name"`
+	Description              string          `json:"description"`
+	Domain                   string          `json:"domain"`
+	RedirectURIs             []string        `json:"redirect_uris"`
+	PostLogoutRedirectURIs   []string        `json:"post_logout_redirect_uris"`
+	AllowedScopes            []string        `json:"allowed_scopes"`
+	GrantTypes               []string        `json:"grant_types"`
+	AllowedResources         []string        `json:"allowed_resources"`
+	LogoURI                  string          `json:"logo_uri"`
+	BackchannelLogoutURI     string          `json:"backchannel_logout_uri"`
+	TokenEndpointAuthMethod  string          `json:"token_endpoint_auth_method"`
+	JWKSURI                  string          `json:"jwks_uri"`
+	JWKS                     json.RawMessage `json:"jwks"`
+	RequirePAR               bool            `json:"require_pushed_authorization_requests"`
+	SubjectType              string          `json:"subject_type"`
+	SectorIdentifier         string          `json:"sector_identifier"`
+	IDTokenSignedResponseAlg string          `json:"id_token_signed_response_alg"`
+
+	// RegistrationAccessTokenHash is set for clients created through dynamic registration
+	RegistrationAccessTokenHash string `json:"-"`
+}
+
+// UpdateServiceRequest holds the fields that can be changed on a service
+// Only non-nil fields are updated
+type UpdateServiceRequest struct {
+	Name                     *string          `json:"name"`
+	Description              *string          `json:"description"`
+	Domain                   *string          `json:"domain"`
+	LogoURI                  *string          `json:"logo_uri"`
+	RedirectURIs             *[]string        `json:"redirect_uris"`
+	PostLogoutRedirectURIs   *[]string        `json:"post_logout_redirect_uris"`
+	AllowedScopes            *[]string        `json:"allowed_scopes"`
+	GrantTypes               *[]string        `json:"grant_types"`
+	AllowedResources         *[]string        `json:"allowed_resources"`
+	BackchannelLogoutURI     *string          `json:"backchannel_logout_uri"`
+	TokenEndpointAuthMethod  *string          `json:"token_endpoint_auth_method"`
+	JWKSURI                  *string          `json:"jwks_uri"`
+	JWKS                     *json.RawMessage `json:"jwks"`
+	RequirePAR               *bool            `json:"require_pushed_authorization_requests"`
+	SubjectType              *string          `json:"subject_type"`
+	SectorIdentifier         *string          `json:"sector_identifier"`
+	IDTokenSignedResponseAlg *string          `json:"id_token_signed_response_alg"`
+	Active                   *bool            `json:"active"`
+
+	// RegistrationAccessTokenHash replaces the token that manages a dynamically registered client
+	RegistrationAccessTokenHash *string `json:"-"`
 }
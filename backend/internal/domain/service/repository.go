@@ -88,10 +88,31 @@ func (r *repository) Update(service *Service) error {
 	}
 
 	updates := map[string]any{
-		"name":        service.Name,
-		"description": service.Description,
-		"domain":      service.Domain,
-		"active":      service.Active,
+		"name":                   service.Name,
+		"description":            service.Description,
+		"domain":                 service.Domain,
+		"backchannel_logout_uri": service.BackchannelLogoutURI,
+		"logo_uri":               service.LogoURI,
+		"active":                 service.Active,
+
+		"redirect_uris":             service.RedirectURIs,
+		"post_logout_redirect_uris": service.PostLogoutRedirectURIs,
+		"allowed_scopes":            service.AllowedScopes,
+		"grant_types":               service.GrantTypes,
+		"allowed_resources":         service.AllowedResources,
+
+		"token_endpoint_auth_method": service.TokenEndpointAuthMethod,
+		"jwks_uri":                   service.JWKSURI,
+		"jwks":                       service.JWKS,
+		"client_secret":              service.ClientSecret,
+
+		"require_pushed_authorization_requests": service.RequirePAR,
+		"registration_access_token_hash":        service.RegistrationAccessTokenHash,
+
+		"subject_type":      service.SubjectType,
+		"sector_identifier": service.SectorIdentifier,
+
+		"id_token_signed_response_alg": service.IDTokenSignedResponseAlg,
 	}
 
 	if !existing.IsSystem {
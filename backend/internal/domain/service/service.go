@@ -5,8 +5,12 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/url"
+	"slices"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v3/jwk"
 	"gorm.io/gorm"
 )
 
@@ -17,32 +21,63 @@ type CacheInvalidator interface {
 
 // ServiceInterface defines the interface for service operations
 type ServiceInterface interface {
-	Create(slug, name, description, domain string, redirectURIs []string, allowedScopes []string) (*Service, error)
+	Create(req *CreateServiceRequest) (*Service, error)
 	FindByID(id string) (*Service, error)
 	FindByClientID(clientID string) (*Service, error)
 	FindByDomain(domain string) (*Service, error)
 	FindAll() ([]*Service, error)
 	FindActive() ([]*Service, error)
-	Update(id string, name, description, domain *string, active *bool) (*Service, error)
+	Update(id string, req *UpdateServiceRequest) (*Service, error)
 	Delete(id string) error
 }
 
+// SigningAlgorithms reports the algorithms the loaded signing keys can sign with, such as the key store
+type SigningAlgorithms interface {
+	Algorithms() []string
+}
+
 // service implements ServiceInterface
 type serviceImpl struct {
-	repo  Repository
-	cache CacheInvalidator
+	repo       Repository
+	cache      CacheInvalidator
+	algorithms SigningAlgorithms
 }
 
 // NewService creates a ServiceInterface that uses the provided repository and optional cache invalidator.
 // NewService constructs a ServiceInterface that uses the provided repository and optional cache invalidator.
 // If cache is nil, cache invalidation is disabled for the returned service.
-func NewService(repo Repository, cache CacheInvalidator) ServiceInterface {
-	return &serviceImpl{repo: repo, cache: cache}
+// algorithms limits id_token_signed_response_alg to the algorithms of the loaded keys; if nil, any supported algorithm is accepted.
+func NewService(repo Repository, cache CacheInvalidator, algorithms SigningAlgorithms) ServiceInterface {
+	return &serviceImpl{repo: repo, cache: cache, algorithms: algorithms}
 }
 
 // Create creates a new service
-func (s *serviceImpl) Create(slug, name, description, domain string, redirectURIs []string, allowedScopes []string) (*Service, error) {
-	clientID := GenerateClientID(slug)
+func (s *serviceImpl) Create(req *CreateServiceRequest) (*Service, error) {
+	clientID := GenerateClientID(req.Slug)
+
+	authMethod := req.TokenEndpointAuthMethod
+	if authMethod == "" {
+		authMethod = AuthMethodClientSecretBasic
+	}
+
+	jwks := string(req.JWKS)
+	if err := validateClientAuth(authMethod, req.JWKSURI, jwks); err != nil {
+		return nil, err
+	}
+	if err := validateGrantTypes(req.GrantTypes); err != nil {
+		return nil, err
+	}
+
+	subjectType := req.SubjectType
+	if subjectType == "" {
+		subjectType = SubjectTypePublic
+	}
+	if err := validateSubjectType(subjectType, req.SectorIdentifier, req.RedirectURIs); err != nil {
+		return nil, err
+	}
+	if err := s.validateIDTokenSigningAlg(req.IDTokenSignedResponseAlg); err != nil {
+		return nil, err
+	}
 
 	// Check if client_id already exists
 	_, err := s.repo.FindByClientID(clientID)
@@ -54,8 +89,8 @@ func (s *serviceImpl) Create(slug, name, description, domain string, redirectURI
 	}
 
 	// Check if domain already exists
-	if domain != "" {
-		_, err := s.repo.FindByDomain(domain)
+	if req.Domain != "" {
+		_, err := s.repo.FindByDomain(req.Domain)
 		if err == nil {
 			return nil, ErrServiceDomainExists
 		}
@@ -64,16 +99,36 @@ func (s *serviceImpl) Create(slug, name, description, domain string, redirectURI
 		}
 	}
 
+	// Public clients have no secret to leak
+	clientSecret := ""
+	if authMethod != AuthMethodNone {
+		clientSecret = GenerateClientSecret()
+	}
+
 	svc := &Service{
-		ClientID:      clientID,
-		ClientSecret:  GenerateClientSecret(),
-		RedirectURIs:  redirectURIs,
-		AllowedScopes: allowedScopes,
-		Name:          name,
-		Description:   description,
-		Domain:        domain,
-		Active:        true,
-		IsSystem:      false,
+		ClientID:                 clientID,
+		ClientSecret:             clientSecret,
+		RedirectURIs:             req.RedirectURIs,
+		PostLogoutRedirectURIs:   req.PostLogoutRedirectURIs,
+		BackchannelLogoutURI:     req.BackchannelLogoutURI,
+		AllowedScopes:            req.AllowedScopes,
+		GrantTypes:               req.GrantTypes,
+		AllowedResources:         req.AllowedResources,
+		TokenEndpointAuthMethod:  authMethod,
+		JWKSURI:                  req.JWKSURI,
+		JWKS:                     jwks,
+		Name:                     req.Name,
+		Description:              req.Description,
+		LogoURI:                  req.LogoURI,
+		Domain:                   req.Domain,
+		Active:                   true,
+		RequirePAR:               req.RequirePAR,
+		SubjectType:              subjectType,
+		SectorIdentifier:         req.SectorIdentifier,
+		IDTokenSignedResponseAlg: req.IDTokenSignedResponseAlg,
+		IsSystem:                 false,
+
+		RegistrationAccessTokenHash: req.RegistrationAccessTokenHash,
 	}
 
 	if err := s.repo.Create(svc); err != nil {
@@ -131,7 +186,7 @@ func (s *serviceImpl) FindActive() ([]*Service, error) {
 
 // Update updates a service
 // Only non-nil fields will be updated
-func (s *serviceImpl) Update(id string, name, description, domain *string, active *bool) (*Service, error) {
+func (s *serviceImpl) Update(id string, req *UpdateServiceRequest) (*Service, error) {
 	svc, err := s.repo.FindByID(id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -142,17 +197,84 @@ func (s *serviceImpl) Update(id string, name, description, domain *string, activ
 
 	oldDomain := svc.Domain
 
-	if name != nil {
-		svc.Name = *name
+	if req.Name != nil {
+		svc.Name = *req.Name
+	}
+	if req.Description != nil {
+		svc.Description = *req.Description
+	}
+	if req.Domain != nil {
+		svc.Domain = *req.Domain
+	}
+	if req.LogoURI != nil {
+		svc.LogoURI = *req.LogoURI
+	}
+	if req.RedirectURIs != nil {
+		svc.RedirectURIs = *req.RedirectURIs
+	}
+	if req.PostLogoutRedirectURIs != nil {
+		svc.PostLogoutRedirectURIs = *req.PostLogoutRedirectURIs
+	}
+	if req.AllowedScopes != nil {
+		svc.AllowedScopes = *req.AllowedScopes
+	}
+	if req.GrantTypes != nil {
+		svc.GrantTypes = *req.GrantTypes
+	}
+	if req.AllowedResources != nil {
+		svc.AllowedResources = *req.AllowedResources
+	}
+	if req.BackchannelLogoutURI != nil {
+		svc.BackchannelLogoutURI = *req.BackchannelLogoutURI
+	}
+	if req.TokenEndpointAuthMethod != nil {
+		svc.TokenEndpointAuthMethod = *req.TokenEndpointAuthMethod
+	}
+	if req.JWKSURI != nil {
+		svc.JWKSURI = *req.JWKSURI
+	}
+	if req.JWKS != nil {
+		svc.JWKS = string(*req.JWKS)
+	}
+	if req.RequirePAR != nil {
+		svc.RequirePAR = *req.RequirePAR
+	}
+	if req.SubjectType != nil {
+		svc.SubjectType = *req.SubjectType
+	}
+	if req.SectorIdentifier != nil {
+		svc.SectorIdentifier = *req.SectorIdentifier
+	}
+	previousSigningAlg := svc.IDTokenSignedResponseAlg
+	if req.IDTokenSignedResponseAlg != nil {
+		svc.IDTokenSignedResponseAlg = *req.IDTokenSignedResponseAlg
+	}
+	if req.Active != nil {
+		svc.Active = *req.Active
+	}
+	if req.RegistrationAccessTokenHash != nil {
+		svc.RegistrationAccessTokenHash = *req.RegistrationAccessTokenHash
+	}
+
+	if err := validateClientAuth(svc.TokenEndpointAuthMethod, svc.JWKSURI, svc.JWKS); err != nil {
+		return nil, err
 	}
-	if description != nil {
-		svc.Description = *description
+	if err := validateGrantTypes(svc.GrantTypes); err != nil {
+		return nil, err
 	}
-	if domain != nil {
-		svc.Domain = *domain
+	if err := validateSubjectType(svc.SubjectType, svc.SectorIdentifier, svc.RedirectURIs); err != nil {
+		return nil, err
 	}
-	if active != nil {
-		svc.Active = *active
+	// An algorithm whose key has since been rotated out is kept; ID tokens fall back to the active key
+	if svc.IDTokenSignedResponseAlg != previousSigningAlg {
+		if err := s.validateIDTokenSigningAlg(svc.IDTokenSignedResponseAlg); err != nil {
+			return nil, err
+		}
+	}
+
+	// A confidential method needs a secret even if the client started out public
+	if svc.ClientSecret == "" && svc.TokenEndpointAuthMethod != AuthMethodNone && svc.TokenEndpointAuthMethod != AuthMethodPrivateKeyJWT {
+		svc.ClientSecret = GenerateClientSecret()
 	}
 
 	if err := s.repo.Update(svc); err != nil {
@@ -180,6 +302,68 @@ func (s *serviceImpl) Delete(id string) error {
 	return s.repo.Delete(id)
 }
 
+// validateClientAuth checks that the token endpoint auth method is supported and that
+// private_key_jwt clients have exactly one usable source of public keys
+func validateClientAuth(method, jwksURI, jwks string) error {
+	switch method {
+	case AuthMethodNone, AuthMethodClientSecretBasic, AuthMethodClientSecretPost, AuthMethodClientSecretJWT:
+	case AuthMethodPrivateKeyJWT:
+		if (jwksURI == "") == (jwks == "" || jwks == "null") {
+			return ErrInvalidJWKS
+		}
+	default:
+		return ErrInvalidTokenEndpointAuthMethod
+	}
+
+	if jwks != "" && jwks != "null" {
+		if _, err := jwk.ParseString(jwks); err != nil {
+			return ErrInvalidJWKS
+		}
+	}
+
+	if jwksURI != "" {
+		u, err := url.Parse(jwksURI)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return ErrInvalidJWKS
+		}
+	}
+
+	return nil
+}
+
+// validateGrantTypes checks that every registered grant type is one the token endpoint supports
+func validateGrantTypes(grantTypes []string) error {
+	for _, grantType := range grantTypes {
+		if !slices.Contains(SupportedGrantTypes, grantType) {
+			return ErrInvalidGrantType
+		}
+	}
+	return nil
+}
+
+// validateSubjectType checks the subject type and that a pairwise service has a single sector,
+// either configured as a bare host or implied by redirect URIs that share one host (OIDC Core Section 8.1)
+func validateSubjectType(subjectType, sectorIdentifier string, redirectURIs []string) error {
+	switch subjectType {
+	case "", SubjectTypePublic:
+		return nil
+	case SubjectTypePairwise:
+	default:
+		return ErrInvalidSubjectType
+	}
+
+	if sectorIdentifier != "" {
+		if strings.ContainsAny(sectorIdentifier, "/:? ") {
+			return ErrInvalidSectorIdentifier
+		}
+		return nil
+	}
+	if len(redirectHosts(redirectURIs)) > 1 {
+		return ErrInvalidSectorIdentifier
+	}
+	return nil
+}
+
 // GenerateClientID produces a client identifier in the form "authly_<slug>_<8hex>".
 // The final segment is the first eight hexadecimal characters of a newly generated UUID.
 func GenerateClientID(slug string) string {
@@ -191,3 +375,17 @@ func GenerateClientID(slug string) string {
 func GenerateClientSecret() string {
 	return base64.RawStdEncoding.EncodeToString([]byte(uuid.New().String()))[:32]
 }
+
+// validateIDTokenSigningAlg checks that a loaded key can sign ID tokens with the algorithm; empty leaves the choice to the server
+func (s *serviceImpl) validateIDTokenSigningAlg(alg string) error {
+	if alg == "" {
+		return nil
+	}
+	if !slices.Contains(SupportedIDTokenSigningAlgs, alg) {
+		return ErrInvalidIDTokenSigningAlg
+	}
+	if s.algorithms != nil && !slices.Contains(s.algorithms.Algorithms(), alg) {
+		return ErrInvalidIDTokenSigningAlg
+	}
+	return nil
+}
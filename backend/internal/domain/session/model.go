@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/Anvoria/authly/internal/database"
+	"github.com/google/uuid"
 )
 
 type Session struct {
@@ -16,6 +17,19 @@ type Session struct {
 	Revoked        bool      `gorm:"column:revoked;default:false"`
 	GrantedScopes  string    `gorm:"column:granted_scopes;type:text"` // space-separated scopes
 
+	// ParentID is the browser session a client's refresh token lineage was issued from; it is nil for browser sessions
+	ParentID *uuid.UUID `gorm:"column:parent_id;type:uuid;index"`
+	// AuthTime is when the user authenticated; child sessions inherit it from their parent
+	AuthTime time.Time `gorm:"column:auth_time"`
+
+	// RequestedClaims is the JSON claims request the userinfo endpoint honours for tokens of this session
+	RequestedClaims string `gorm:"column:requested_claims;type:text"`
+	// Resources are the client_ids of the resource servers access tokens of this session are addressed to (RFC 8707)
+	Resources string `gorm:"column:resources;type:text"` // space-separated
+
+	// DPoPJKT binds the refresh token to a DPoP key thumbprint (RFC 9449 Section 5)
+	DPoPJKT string `gorm:"column:dpop_jkt;type:varchar(64)"`
+
 	IPAddress string `gorm:"column:ip_address;type:text"`
 	UserAgent string `gorm:"column:user_agent;type:text"`
 	Device    string `gorm:"column:device;type:text"`
@@ -26,3 +40,29 @@ type Session struct {
 func (Session) TableName() string {
 	return "sessions"
 }
+
+// SessionClient records that a client (relying party) received tokens backed by a session
+type SessionClient struct {
+	database.BaseModel
+
+	SessionID uuid.UUID `gorm:"column:session_id;type:uuid;not null;uniqueIndex:idx_session_clients_session_client"`
+	ClientID  string    `gorm:"column:client_id;type:varchar(255);not null;uniqueIndex:idx_session_clients_session_client"`
+}
+
+func (SessionClient) TableName() string {
+	return "session_clients"
+}
+
+// RefreshHash is a refresh secret hash a session rotated away from. The family of a session is every
+// secret it was ever issued; presenting a retired one again means the refresh token was copied.
+type RefreshHash struct {
+	database.BaseModel
+
+	SessionID   uuid.UUID `gorm:"column:session_id;type:uuid;not null;uniqueIndex:idx_session_refresh_hashes_session_hash"`
+	RefreshHash string    `gorm:"column:refresh_hash;type:varchar(255);not null;uniqueIndex:idx_session_refresh_hashes_session_hash"`
+	RetiredAt   time.Time `gorm:"column:retired_at;not null"`
+}
+
+func (RefreshHash) TableName() string {
+	return "session_refresh_hashes"
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Repository interface {
@@ -12,10 +13,18 @@ type Repository interface {
 	FindByID(id uuid.UUID) (*Session, error)
 	FindByIDForRevoke(id uuid.UUID) (*Session, error)
 	UpdateHash(id uuid.UUID, oldHash, newHash string, newExpiry time.Time) (bool, error)
-	Revoke(id uuid.UUID) error
+	FindRetiredHash(id uuid.UUID, hash string) (*RefreshHash, error)
+	Revoke(id uuid.UUID) (bool, error)
 	UpdateLastUsed(id uuid.UUID, t time.Time) error
 	FindSessionsByUserID(userID uuid.UUID) ([]Session, error)
+	FindChildIDs(parentID uuid.UUID) ([]uuid.UUID, error)
 	UpdateScopes(id uuid.UUID, scopes string) error
+	UpdateRequestedClaims(id uuid.UUID, claims string) error
+	UpdateResources(id uuid.UUID, resources string) error
+	AddClient(sessionID uuid.UUID, clientID string) error
+	FindClientIDs(sessionID uuid.UUID) ([]string, error)
+	FindSessionIDsByUserAndClient(userID uuid.UUID, clientID string) ([]uuid.UUID, error)
+	BindDPoP(id uuid.UUID, jkt string) (bool, error)
 }
 
 type repository struct {
@@ -48,26 +57,55 @@ func (r *repository) FindByIDForRevoke(id uuid.UUID) (*Session, error) {
 	return &sess, nil
 }
 
+// UpdateHash swaps the refresh hash if it is still oldHash and records oldHash as retired
 func (r *repository) UpdateHash(id uuid.UUID, oldHash, newHash string, newExpiry time.Time) (bool, error) {
-	res := r.db.Model(&Session{}).
-		Where("id = ? AND refresh_hash = ? AND revoked = false", id, oldHash).
-		Updates(map[string]any{
-			"refresh_hash": newHash,
-			"expires_at":   newExpiry,
-			"last_used_at": time.Now().UTC(),
-		})
+	updated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		res := tx.Model(&Session{}).
+			Where("id = ? AND refresh_hash = ? AND revoked = false", id, oldHash).
+			Updates(map[string]any{
+				"refresh_hash": newHash,
+				"expires_at":   newExpiry,
+				"last_used_at": now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return nil
+		}
 
-	if res.Error != nil {
-		return false, res.Error
+		updated = true
+		return tx.Create(&RefreshHash{SessionID: id, RefreshHash: oldHash, RetiredAt: now}).Error
+	})
+	if err != nil {
+		return false, err
 	}
 
-	return res.RowsAffected == 1, nil
+	return updated, nil
 }
 
-func (r *repository) Revoke(id uuid.UUID) error {
-	return r.db.Model(&Session{}).
+// FindRetiredHash looks up a refresh hash the session rotated away from
+func (r *repository) FindRetiredHash(id uuid.UUID, hash string) (*RefreshHash, error) {
+	var retired RefreshHash
+	err := r.db.Where("session_id = ? AND refresh_hash = ?", id, hash).First(&retired).Error
+	if err != nil {
+		return nil, err
+	}
+	return &retired, nil
+}
+
+// Revoke marks the session revoked and reports whether this call revoked it, so that concurrent
+// revocations can tell which of them ended the session
+func (r *repository) Revoke(id uuid.UUID) (bool, error) {
+	result := r.db.Model(&Session{}).
 		Where("id = ? AND revoked = false", id).
-		Update("revoked", true).Error
+		Update("revoked", true)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
 }
 
 func (r *repository) UpdateLastUsed(id uuid.UUID, t time.Time) error {
@@ -85,8 +123,73 @@ func (r *repository) FindSessionsByUserID(userID uuid.UUID) ([]Session, error) {
 	return sessions, nil
 }
 
+// FindChildIDs returns the active sessions issued from the parent session
+func (r *repository) FindChildIDs(parentID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&Session{}).
+		Where("parent_id = ? AND revoked = false", parentID).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
 func (r *repository) UpdateScopes(id uuid.UUID, scopes string) error {
 	return r.db.Model(&Session{}).
 		Where("id = ?", id).
 		Update("granted_scopes", scopes).Error
 }
+
+func (r *repository) UpdateRequestedClaims(id uuid.UUID, claims string) error {
+	return r.db.Model(&Session{}).
+		Where("id = ?", id).
+		Update("requested_claims", claims).Error
+}
+
+func (r *repository) UpdateResources(id uuid.UUID, resources string) error {
+	return r.db.Model(&Session{}).
+		Where("id = ?", id).
+		Update("resources", resources).Error
+}
+
+// AddClient records a client against a session; recording the same client twice is a no-op
+func (r *repository) AddClient(sessionID uuid.UUID, clientID string) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&SessionClient{SessionID: sessionID, ClientID: clientID}).Error
+}
+
+func (r *repository) FindClientIDs(sessionID uuid.UUID) ([]string, error) {
+	var clientIDs []string
+	err := r.db.Model(&SessionClient{}).
+		Where("session_id = ?", sessionID).
+		Pluck("client_id", &clientIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return clientIDs, nil
+}
+
+// FindSessionIDsByUserAndClient returns the user's active sessions that issued tokens to the client
+func (r *repository) FindSessionIDsByUserAndClient(userID uuid.UUID, clientID string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&Session{}).
+		Joins("JOIN session_clients ON session_clients.session_id = sessions.id AND session_clients.deleted_at IS NULL").
+		Where("sessions.user_id = ? AND sessions.revoked = false AND session_clients.client_id = ?", userID.String(), clientID).
+		Pluck("sessions.id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// BindDPoP binds an unbound session to a DPoP key. It reports false if the session is already bound to another key.
+func (r *repository) BindDPoP(id uuid.UUID, jkt string) (bool, error) {
+	res := r.db.Model(&Session{}).
+		Where("id = ? AND revoked = false AND (dpop_jkt IS NULL OR dpop_jkt = '' OR dpop_jkt = ?)", id, jkt).
+		Update("dpop_jkt", jkt)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
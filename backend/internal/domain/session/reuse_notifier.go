@@ -0,0 +1,119 @@
+package session
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Anvoria/authly/internal/domain/user"
+)
+
+// LogReuseNotifier writes the notification for the user to the log. It suits development setups
+// and deployments that forward the log to their own alerting.
+type LogReuseNotifier struct{}
+
+// NewLogReuseNotifier creates a notifier that only logs
+func NewLogReuseNotifier() *LogReuseNotifier {
+	return &LogReuseNotifier{}
+}
+
+// RefreshTokenReused logs the notification
+func (n *LogReuseNotifier) RefreshTokenReused(event *ReuseEvent) {
+	slog.Info("Refresh token reuse notification",
+		"user_id", event.Session.UserID,
+		"session_id", event.Session.ID.String(),
+		"ip_address", event.Session.IPAddress,
+		"user_agent", event.Session.UserAgent,
+		"detected_at", event.DetectedAt,
+	)
+}
+
+// UserDirectory looks up the user a notification is addressed to
+type UserDirectory interface {
+	GetUserInfo(userID string) (*user.User, error)
+}
+
+// SMTPSettings is the mail server the email notifier delivers through
+type SMTPSettings struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// EmailReuseNotifier mails the user that their session was signed out because a stolen refresh token
+// was used. Mail is sent in the background; users without an email address are skipped.
+type EmailReuseNotifier struct {
+	users    UserDirectory
+	settings SMTPSettings
+
+	// send delivers the message; it is smtp.SendMail outside of tests
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewEmailReuseNotifier creates a notifier that mails users through the given SMTP server
+func NewEmailReuseNotifier(users UserDirectory, settings SMTPSettings) *EmailReuseNotifier {
+	return &EmailReuseNotifier{users: users, settings: settings, send: smtp.SendMail}
+}
+
+// RefreshTokenReused sends the notification without blocking the refresh that detected the reuse
+func (n *EmailReuseNotifier) RefreshTokenReused(event *ReuseEvent) {
+	go func() {
+		if err := n.deliver(event); err != nil {
+			slog.Error("Failed to send refresh token reuse notification", "error", err, "user_id", event.Session.UserID)
+		}
+	}()
+}
+
+// deliver looks up the user's email address and mails the notification
+func (n *EmailReuseNotifier) deliver(event *ReuseEvent) error {
+	u, err := n.users.GetUserInfo(event.Session.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+	if u.Email == "" {
+		return nil
+	}
+
+	var auth smtp.Auth
+	if n.settings.Username != "" {
+		auth = smtp.PlainAuth("", n.settings.Username, n.settings.Password, n.settings.Host)
+	}
+
+	addr := net.JoinHostPort(n.settings.Host, strconv.Itoa(n.settings.Port))
+	return n.send(addr, auth, n.settings.From, []string{u.Email}, n.message(u, event))
+}
+
+// message renders the notification mail
+func (n *EmailReuseNotifier) message(u *user.User, event *ReuseEvent) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.settings.From)
+	fmt.Fprintf(&b, "To: %s\r\n", u.Email)
+	b.WriteString("Subject: A session of your account was signed out\r\n")
+	fmt.Fprintf(&b, "Date: %s\r\n", event.DetectedAt.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+
+	fmt.Fprintf(&b, "Hello %s,\r\n\r\n", u.Username)
+	b.WriteString("an outdated sign-in token of one of your sessions was used again, which suggests it was copied.\r\n")
+	b.WriteString("We signed that session out; the applications using it will ask you to sign in again.\r\n\r\n")
+	fmt.Fprintf(&b, "Session signed in from: %s\r\n", valueOrUnknown(event.Session.IPAddress))
+	fmt.Fprintf(&b, "Browser: %s\r\n", valueOrUnknown(event.Session.UserAgent))
+	fmt.Fprintf(&b, "Detected at: %s\r\n\r\n", event.DetectedAt.Format(time.RFC1123))
+	b.WriteString("If you do not recognise this, change your password.\r\n")
+
+	return []byte(b.String())
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
@@ -0,0 +1,55 @@
+package session
+
+import (
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/Anvoria/authly/internal/domain/user"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// userTable is an in-memory user directory
+type userTable map[string]*user.User
+
+func (t userTable) GetUserInfo(userID string) (*user.User, error) {
+	return t[userID], nil
+}
+
+func TestEmailReuseNotifier(t *testing.T) {
+	jane := &user.User{Username: "jane", Email: "jane@example.com"}
+	jane.ID = uuid.New()
+	noEmail := &user.User{Username: "bob"}
+	noEmail.ID = uuid.New()
+
+	var sentTo []string
+	var message string
+	n := NewEmailReuseNotifier(userTable{jane.ID.String(): jane, noEmail.ID.String(): noEmail}, SMTPSettings{
+		Host: "smtp.example.com",
+		Port: 587,
+		From: "security@example.com",
+	})
+	n.send = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, "smtp.example.com:587", addr)
+		assert.Equal(t, "security@example.com", from)
+		sentTo, message = to, string(msg)
+		return nil
+	}
+
+	event := func(u *user.User) *ReuseEvent {
+		sess := &Session{UserID: u.ID.String(), IPAddress: "203.0.113.7"}
+		sess.ID = uuid.New()
+		return &ReuseEvent{Session: sess, DetectedAt: time.Now()}
+	}
+
+	require.NoError(t, n.deliver(event(jane)))
+	assert.Equal(t, []string{"jane@example.com"}, sentTo)
+	assert.Contains(t, message, "To: jane@example.com\r\n")
+	assert.Contains(t, message, "203.0.113.7")
+
+	sentTo = nil
+	require.NoError(t, n.deliver(event(noEmail)))
+	assert.Nil(t, sentTo)
+}
@@ -24,36 +24,75 @@ var (
 	ErrInvalidSecret = errors.New("invalid session secret")
 	// ErrExpiredSession is returned when the session has expired
 	ErrExpiredSession = errors.New("session expired")
-	// ErrReplayDetected is returned when a replay attack is detected
+	// ErrReplayDetected is returned when a retired refresh secret is presented again; the session has been revoked
 	ErrReplayDetected = errors.New("replay detected")
+	// ErrDPoPKeyMismatch is returned when a session is already bound to a different DPoP key
+	ErrDPoPKeyMismatch = errors.New("session bound to a different dpop key")
 )
 
 // Service interface for session operations
 type Service interface {
 	Create(userID uuid.UUID, userAgent, ip string, scopes []string, ttl time.Duration) (sessionID uuid.UUID, secret string, err error)
+	CreateChild(parentID, userID uuid.UUID, userAgent, ip string, scopes []string, ttl time.Duration) (sessionID uuid.UUID, secret string, err error)
 	Validate(sessionID uuid.UUID, secret string) (*Session, error)
 	Rotate(sessionID uuid.UUID, oldSecret string, ttl time.Duration) (newSecret string, err error)
 	Revoke(sessionID uuid.UUID) error
 	RevokeAllUserSessions(userID uuid.UUID) error
+	RevokeClientSessions(userID uuid.UUID, clientID string) error
 	Exists(sessionID uuid.UUID) (bool, error)
 	UpdateScopes(sessionID uuid.UUID, scopes []string) error
+	UpdateRequestedClaims(sessionID uuid.UUID, claims string) error
+	UpdateResources(sessionID uuid.UUID, resources []string) error
+	TrackClient(sessionID uuid.UUID, clientID string) error
+	ClientIDs(sessionID uuid.UUID) ([]string, error)
+	BindDPoP(sessionID uuid.UUID, jkt string) error
+	AddRevocationListener(listener RevocationListener)
+	AddReuseListener(listener ReuseListener)
+	SetReuseGracePeriod(grace time.Duration)
+}
+
+// DefaultReuseGracePeriod is how long a rotated refresh secret may still be presented without being
+// treated as stolen, so that concurrent refreshes such as from parallel tabs are merely rejected
+const DefaultReuseGracePeriod = 10 * time.Second
+
+// RevocationListener is notified after a session transitions to revoked.
+// Implementations are called synchronously from Revoke and must defer slow work such as network calls.
+type RevocationListener interface {
+	SessionRevoked(sess *Session)
+}
+
+// ReuseEvent describes a retired refresh secret being presented again
+type ReuseEvent struct {
+	Session    *Session
+	RetiredAt  time.Time
+	DetectedAt time.Time
+}
+
+// ReuseListener is notified when refresh token reuse is detected, after the session has been
+// revoked, for example to tell the user their token was stolen.
+// Implementations are called synchronously and must defer slow work such as network calls.
+type ReuseListener interface {
+	RefreshTokenReused(event *ReuseEvent)
 }
 
 // service struct for session operations
 type service struct {
 	repo            Repository
 	revocationCache *cache.TokenRevocationCache
+	listeners       []RevocationListener
+	reuseListeners  []ReuseListener
+	reuseGrace      time.Duration
 }
 
 // NewService creates a session Service that uses the provided Repository and does not configure a revocation cache.
 func NewService(repo Repository) Service {
-	return &service{repo: repo}
+	return &service{repo: repo, reuseGrace: DefaultReuseGracePeriod}
 }
 
 // NewServiceWithCache creates a Service configured with the provided repository and an optional token revocation cache.
 // If revocationCache is nil the service will operate without a revocation cache.
 func NewServiceWithCache(repo Repository, revocationCache *cache.TokenRevocationCache) Service {
-	return &service{repo: repo, revocationCache: revocationCache}
+	return &service{repo: repo, revocationCache: revocationCache, reuseGrace: DefaultReuseGracePeriod}
 }
 
 // generateSecret generates a random secret for the session
@@ -74,6 +113,33 @@ func hashSecret(secret string) string {
 
 // Create creates a new session
 func (s *service) Create(userID uuid.UUID, userAgent, ip string, scopes []string, ttl time.Duration) (uuid.UUID, string, error) {
+	return s.create(userID, nil, time.Now().UTC(), userAgent, ip, scopes, ttl)
+}
+
+// CreateChild creates a session with its own secret under an active session of the user.
+// Each client redeeming an authorization code gets such a session, so its refresh token rotates
+// independently of the browser session and of other clients. Revoking the parent revokes it too.
+func (s *service) CreateChild(parentID, userID uuid.UUID, userAgent, ip string, scopes []string, ttl time.Duration) (uuid.UUID, string, error) {
+	parent, err := s.repo.FindByID(parentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, "", ErrInvalidSession
+		}
+		return uuid.Nil, "", err
+	}
+
+	if parent.Revoked || parent.UserID != userID.String() {
+		return uuid.Nil, "", ErrInvalidSession
+	}
+
+	if time.Now().UTC().After(parent.ExpiresAt) {
+		return uuid.Nil, "", ErrExpiredSession
+	}
+
+	return s.create(userID, &parent.ID, parent.AuthTime, userAgent, ip, scopes, ttl)
+}
+
+func (s *service) create(userID uuid.UUID, parentID *uuid.UUID, authTime time.Time, userAgent, ip string, scopes []string, ttl time.Duration) (uuid.UUID, string, error) {
 	secret, err := generateSecret()
 	if err != nil {
 		return uuid.Nil, "", err
@@ -81,6 +147,8 @@ func (s *service) Create(userID uuid.UUID, userAgent, ip string, scopes []string
 
 	sess := &Session{
 		UserID:        userID.String(),
+		ParentID:      parentID,
+		AuthTime:      authTime,
 		RefreshHash:   hashSecret(secret),
 		ExpiresAt:     time.Now().UTC().Add(ttl),
 		UserAgent:     userAgent,
@@ -117,7 +185,7 @@ func (s *service) Validate(id uuid.UUID, secret string) (*Session, error) {
 	}
 
 	if hashSecret(secret) != sess.RefreshHash {
-		return nil, ErrInvalidSecret
+		return nil, s.checkReuse(sess, secret)
 	}
 
 	if err := s.repo.UpdateLastUsed(id, time.Now().UTC()); err != nil {
@@ -145,12 +213,56 @@ func (s *service) Rotate(id uuid.UUID, oldSecret string, ttl time.Duration) (str
 	}
 
 	if !success {
-		return "", ErrReplayDetected
+		// A concurrent request rotated the same secret first, which retired it
+		sess, err := s.repo.FindByIDForRevoke(id)
+		if err != nil {
+			return "", err
+		}
+		return "", s.checkReuse(sess, oldSecret)
 	}
 
 	return newSecret, nil
 }
 
+// checkReuse classifies a secret that no longer matches the session. A secret that was never issued
+// is just invalid, and so is one retired within the grace period: that is a concurrent refresh which
+// lost the race. Any older retired secret was copied, so the session and the sessions issued from it
+// are revoked before the security event is emitted, and ErrReplayDetected is returned.
+func (s *service) checkReuse(sess *Session, secret string) error {
+	retired, err := s.repo.FindRetiredHash(sess.ID, hashSecret(secret))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidSecret
+		}
+		return err
+	}
+
+	now := time.Now().UTC()
+	if now.Sub(retired.RetiredAt) <= s.reuseGrace {
+		return ErrInvalidSecret
+	}
+
+	if err := s.Revoke(sess.ID); err != nil {
+		return fmt.Errorf("failed to revoke session after refresh token reuse: %w", err)
+	}
+
+	// This warning is the audit record of the event; log-based alerting matches its message and
+	// attributes, so keep them stable. Users are told through the reuse listeners.
+	slog.Warn("Security event: refresh token reuse detected, session revoked",
+		"event", "refresh_token_reuse",
+		"session_id", sess.ID.String(),
+		"user_id", sess.UserID,
+		"retired_at", retired.RetiredAt,
+	)
+
+	event := &ReuseEvent{Session: sess, RetiredAt: retired.RetiredAt, DetectedAt: now}
+	for _, listener := range s.reuseListeners {
+		listener.RefreshTokenReused(event)
+	}
+
+	return ErrReplayDetected
+}
+
 // Revoke revokes a session
 func (s *service) Revoke(id uuid.UUID) error {
 	// Get session info before revoking to get ExpiresAt for Redis TTL
@@ -162,8 +274,9 @@ func (s *service) Revoke(id uuid.UUID) error {
 		return err
 	}
 
-	// Revoke in database
-	if err := s.repo.Revoke(id); err != nil {
+	// Revoke in database; only the call that flips the session reports it as revoked
+	revoked, err := s.repo.Revoke(id)
+	if err != nil {
 		return err
 	}
 
@@ -181,6 +294,25 @@ func (s *service) Revoke(id uuid.UUID) error {
 		}
 	}
 
+	// Only notify on the revocation that ended the session, so listeners never see it twice
+	// even when several requests revoke it concurrently
+	if revoked {
+		for _, listener := range s.listeners {
+			listener.SessionRevoked(sess)
+		}
+	}
+
+	// Logging out of the browser session ends the sessions clients were issued from it
+	children, err := s.repo.FindChildIDs(id)
+	if err != nil {
+		return fmt.Errorf("failed to get child sessions of %s: %w", id, err)
+	}
+	for _, childID := range children {
+		if err := s.Revoke(childID); err != nil {
+			slog.Warn("Failed to revoke child session", "error", err, "session_id", childID.String(), "parent_id", id.String())
+		}
+	}
+
 	return nil
 }
 
@@ -200,6 +332,22 @@ func (s *service) RevokeAllUserSessions(userID uuid.UUID) error {
 	return nil
 }
 
+// RevokeClientSessions revokes every active session of the user that issued tokens to the client
+func (s *service) RevokeClientSessions(userID uuid.UUID, clientID string) error {
+	ids, err := s.repo.FindSessionIDsByUserAndClient(userID, clientID)
+	if err != nil {
+		return fmt.Errorf("failed to get sessions for user %s and client %s: %w", userID, clientID, err)
+	}
+
+	for _, id := range ids {
+		if err := s.Revoke(id); err != nil {
+			slog.Warn("Failed to revoke session", "error", err, "session_id", id.String(), "client_id", clientID)
+		}
+	}
+
+	return nil
+}
+
 // Exists checks if a session exists and is valid (not revoked, not expired)
 func (s *service) Exists(id uuid.UUID) (bool, error) {
 	sess, err := s.repo.FindByID(id)
@@ -225,3 +373,53 @@ func (s *service) Exists(id uuid.UUID) (bool, error) {
 func (s *service) UpdateScopes(sessionID uuid.UUID, scopes []string) error {
 	return s.repo.UpdateScopes(sessionID, strings.Join(scopes, " "))
 }
+
+// UpdateRequestedClaims stores the claims request that tokens refreshed from the session carry
+func (s *service) UpdateRequestedClaims(sessionID uuid.UUID, claims string) error {
+	return s.repo.UpdateRequestedClaims(sessionID, claims)
+}
+
+// UpdateResources stores the resource servers that tokens refreshed from the session are addressed to
+func (s *service) UpdateResources(sessionID uuid.UUID, resources []string) error {
+	return s.repo.UpdateResources(sessionID, strings.Join(resources, " "))
+}
+
+// TrackClient records that tokens backed by the session were issued to the client
+func (s *service) TrackClient(sessionID uuid.UUID, clientID string) error {
+	return s.repo.AddClient(sessionID, clientID)
+}
+
+// ClientIDs returns the client IDs that received tokens backed by the session
+func (s *service) ClientIDs(sessionID uuid.UUID) ([]string, error) {
+	return s.repo.FindClientIDs(sessionID)
+}
+
+// BindDPoP binds the session's refresh token to a DPoP key so it can only be refreshed with proofs from that key
+func (s *service) BindDPoP(sessionID uuid.UUID, jkt string) error {
+	bound, err := s.repo.BindDPoP(sessionID, jkt)
+	if err != nil {
+		return err
+	}
+	if !bound {
+		return ErrDPoPKeyMismatch
+	}
+	return nil
+}
+
+// AddRevocationListener registers a listener to be notified when a session is revoked
+// It is not safe to call concurrently with Revoke and is intended for use during wiring
+func (s *service) AddRevocationListener(listener RevocationListener) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// AddReuseListener registers a listener to be notified when refresh token reuse is detected
+// It is not safe to call concurrently with Validate and is intended for use during wiring
+func (s *service) AddReuseListener(listener ReuseListener) {
+	s.reuseListeners = append(s.reuseListeners, listener)
+}
+
+// SetReuseGracePeriod sets how long a rotated refresh secret is tolerated before reuse revokes the session
+// It is not safe to call concurrently with Validate and is intended for use during wiring
+func (s *service) SetReuseGracePeriod(grace time.Duration) {
+	s.reuseGrace = grace
+}
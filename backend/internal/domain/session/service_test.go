@@ -0,0 +1,244 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryRepository keeps sessions and their retired refresh hashes in memory
+type memoryRepository struct {
+	Repository
+	sessions map[uuid.UUID]*Session
+	retired  []*RefreshHash
+}
+
+func (r *memoryRepository) Create(sess *Session) error {
+	r.sessions[sess.ID] = sess
+	return nil
+}
+
+func (r *memoryRepository) FindByID(id uuid.UUID) (*Session, error) {
+	if sess, ok := r.sessions[id]; ok && !sess.Revoked {
+		copied := *sess
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) FindByIDForRevoke(id uuid.UUID) (*Session, error) {
+	if sess, ok := r.sessions[id]; ok {
+		copied := *sess
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) UpdateHash(id uuid.UUID, oldHash, newHash string, newExpiry time.Time) (bool, error) {
+	sess := r.sessions[id]
+	if sess.Revoked || sess.RefreshHash != oldHash {
+		return false, nil
+	}
+	sess.RefreshHash = newHash
+	sess.ExpiresAt = newExpiry
+	r.retired = append(r.retired, &RefreshHash{SessionID: id, RefreshHash: oldHash, RetiredAt: time.Now().UTC()})
+	return true, nil
+}
+
+func (r *memoryRepository) FindRetiredHash(id uuid.UUID, hash string) (*RefreshHash, error) {
+	for _, retired := range r.retired {
+		if retired.SessionID == id && retired.RefreshHash == hash {
+			return retired, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) Revoke(id uuid.UUID) (bool, error) {
+	sess := r.sessions[id]
+	if sess.Revoked {
+		return false, nil
+	}
+	sess.Revoked = true
+	return true, nil
+}
+
+func (r *memoryRepository) UpdateLastUsed(uuid.UUID, time.Time) error {
+	return nil
+}
+
+func (r *memoryRepository) FindChildIDs(parentID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	for id, sess := range r.sessions {
+		if sess.ParentID != nil && *sess.ParentID == parentID && !sess.Revoked {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+type reuseRecorder struct {
+	events []*ReuseEvent
+}
+
+func (r *reuseRecorder) RefreshTokenReused(event *ReuseEvent) {
+	r.events = append(r.events, event)
+}
+
+func TestRotateReuseDetection(t *testing.T) {
+	userID := uuid.New()
+	newService := func() (Service, *memoryRepository, *reuseRecorder) {
+		repo := &memoryRepository{sessions: map[uuid.UUID]*Session{}}
+		recorder := &reuseRecorder{}
+		s := NewService(repo)
+		s.AddReuseListener(recorder)
+		return s, repo, recorder
+	}
+
+	t.Run("concurrent refresh within the grace period is only rejected", func(t *testing.T) {
+		s, repo, recorder := newService()
+		id, secret, err := s.Create(userID, "", "", nil, time.Hour)
+		require.NoError(t, err)
+
+		_, err = s.Rotate(id, secret, time.Hour)
+		require.NoError(t, err)
+
+		_, err = s.Rotate(id, secret, time.Hour)
+		assert.ErrorIs(t, err, ErrInvalidSecret)
+		assert.False(t, repo.sessions[id].Revoked)
+		assert.Empty(t, recorder.events)
+	})
+
+	t.Run("an older retired secret revokes the family", func(t *testing.T) {
+		s, repo, recorder := newService()
+		s.SetReuseGracePeriod(0)
+
+		parentID, _, err := s.Create(userID, "", "", nil, time.Hour)
+		require.NoError(t, err)
+		id, first, err := s.CreateChild(parentID, userID, "", "", nil, time.Hour)
+		require.NoError(t, err)
+
+		second, err := s.Rotate(id, first, time.Hour)
+		require.NoError(t, err)
+		_, err = s.Rotate(id, second, time.Hour)
+		require.NoError(t, err)
+
+		// Two rotations old
+		_, err = s.Rotate(id, first, time.Hour)
+		assert.ErrorIs(t, err, ErrReplayDetected)
+		assert.True(t, repo.sessions[id].Revoked)
+		assert.False(t, repo.sessions[parentID].Revoked, "other clients of the browser session stay signed in")
+
+		require.Len(t, recorder.events, 1)
+		assert.Equal(t, id, recorder.events[0].Session.ID)
+	})
+
+	t.Run("a secret that was never issued is just invalid", func(t *testing.T) {
+		s, repo, recorder := newService()
+		id, _, err := s.Create(userID, "", "", nil, time.Hour)
+		require.NoError(t, err)
+
+		_, err = s.Rotate(id, "guessed", time.Hour)
+		assert.ErrorIs(t, err, ErrInvalidSecret)
+		assert.False(t, repo.sessions[id].Revoked)
+		assert.Empty(t, recorder.events)
+	})
+}
+
+type revocationRecorder struct {
+	revoked []uuid.UUID
+}
+
+func (r *revocationRecorder) SessionRevoked(sess *Session) {
+	r.revoked = append(r.revoked, sess.ID)
+}
+
+// staleRepository reads every session as still active, like a revocation racing another one
+type staleRepository struct {
+	*memoryRepository
+}
+
+func (r staleRepository) FindByIDForRevoke(id uuid.UUID) (*Session, error) {
+	sess, err := r.memoryRepository.FindByIDForRevoke(id)
+	if err == nil {
+		sess.Revoked = false
+	}
+	return sess, err
+}
+
+func TestRevokeNotifiesOnce(t *testing.T) {
+	repo := &memoryRepository{sessions: map[uuid.UUID]*Session{}}
+	s := NewService(staleRepository{repo})
+	recorder := &revocationRecorder{}
+	s.AddRevocationListener(recorder)
+
+	id, _, err := s.Create(uuid.New(), "", "", nil, time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Revoke(id))
+	require.NoError(t, s.Revoke(id))
+	assert.Equal(t, []uuid.UUID{id}, recorder.revoked, "only the revocation that ended the session notifies")
+}
+
+func TestCreateChild(t *testing.T) {
+	userID := uuid.New()
+	newService := func(t *testing.T) (Service, *memoryRepository, uuid.UUID) {
+		repo := &memoryRepository{sessions: map[uuid.UUID]*Session{}}
+		s := NewService(repo)
+		parentID, _, err := s.Create(userID, "", "", nil, time.Hour)
+		require.NoError(t, err)
+		return s, repo, parentID
+	}
+
+	t.Run("each client rotates its own refresh token", func(t *testing.T) {
+		s, repo, parentID := newService(t)
+		firstID, firstSecret, err := s.CreateChild(parentID, userID, "", "", nil, time.Hour)
+		require.NoError(t, err)
+		secondID, secondSecret, err := s.CreateChild(parentID, userID, "", "", nil, time.Hour)
+		require.NoError(t, err)
+		assert.NotEqual(t, firstID, secondID)
+
+		_, err = s.Rotate(firstID, firstSecret, time.Hour)
+		require.NoError(t, err)
+
+		_, err = s.Validate(secondID, secondSecret)
+		assert.NoError(t, err, "rotating one client leaves the other's refresh token valid")
+		_, err = s.Validate(firstID, secondSecret)
+		assert.ErrorIs(t, err, ErrInvalidSecret, "a client cannot use another client's refresh token")
+		assert.Equal(t, parentID, *repo.sessions[firstID].ParentID)
+	})
+
+	t.Run("revoking the parent revokes its children", func(t *testing.T) {
+		s, repo, parentID := newService(t)
+		firstID, _, err := s.CreateChild(parentID, userID, "", "", nil, time.Hour)
+		require.NoError(t, err)
+		secondID, _, err := s.CreateChild(parentID, userID, "", "", nil, time.Hour)
+		require.NoError(t, err)
+		otherID, _, err := s.Create(userID, "", "", nil, time.Hour)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Revoke(parentID))
+		assert.True(t, repo.sessions[firstID].Revoked)
+		assert.True(t, repo.sessions[secondID].Revoked)
+		assert.False(t, repo.sessions[otherID].Revoked, "other browser sessions of the user stay signed in")
+	})
+
+	t.Run("a revoked parent cannot issue children", func(t *testing.T) {
+		s, _, parentID := newService(t)
+		require.NoError(t, s.Revoke(parentID))
+
+		_, _, err := s.CreateChild(parentID, userID, "", "", nil, time.Hour)
+		assert.ErrorIs(t, err, ErrInvalidSession)
+	})
+
+	t.Run("the parent must belong to the user", func(t *testing.T) {
+		s, _, parentID := newService(t)
+
+		_, _, err := s.CreateChild(parentID, uuid.New(), "", "", nil, time.Hour)
+		assert.ErrorIs(t, err, ErrInvalidSession)
+	})
+}
@@ -0,0 +1,57 @@
+package signingkey
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+)
+
+// KeyEncryptionKey seals private keys with AES-256-GCM
+type KeyEncryptionKey struct {
+	aead cipher.AEAD
+}
+
+// ParseKeyEncryptionKey decodes a base64-encoded 32-byte key-encryption key
+func ParseKeyEncryptionKey(encoded string) (*KeyEncryptionKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(raw) != 32 {
+		return nil, ErrInvalidKeyEncryptionKey
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyEncryptionKey{aead: aead}, nil
+}
+
+// Seal encrypts plaintext. The key ID is bound as additional data, so a sealed key cannot be
+// moved to another row. The nonce is prepended to the ciphertext.
+func (k *KeyEncryptionKey) Seal(kid string, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(nonce, nonce, plaintext, []byte(kid)), nil
+}
+
+// Open decrypts what Seal encrypted for the key ID
+func (k *KeyEncryptionKey) Open(kid string, sealed []byte) ([]byte, error) {
+	nonceSize := k.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, ErrDecryptionFailed
+	}
+
+	plaintext, err := k.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(kid))
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,23 @@
+package signingkey
+
+import "errors"
+
+var (
+	// ErrKeyNotFound is returned when no signing key has the key ID
+	ErrKeyNotFound = errors.New("signing key not found")
+
+	// ErrKeyExists is returned when a signing key with the key ID already exists
+	ErrKeyExists = errors.New("signing key already exists")
+
+	// ErrNoActiveKey is returned when the database has no active signing key
+	ErrNoActiveKey = errors.New("no active signing key in the database")
+
+	// ErrKeyRemoved is returned when activating or retiring a key whose private key was removed
+	ErrKeyRemoved = errors.New("signing key has been removed")
+
+	// ErrInvalidKeyEncryptionKey is returned when the key-encryption key is not 32 base64-encoded bytes
+	ErrInvalidKeyEncryptionKey = errors.New("key encryption key must be 32 bytes, base64 encoded")
+
+	// ErrDecryptionFailed is returned when a private key cannot be decrypted, usually because of a wrong key-encryption key
+	ErrDecryptionFailed = errors.New("failed to decrypt signing key; is the key encryption key correct?")
+)
@@ -0,0 +1,29 @@
+package signingkey
+
+import (
+	"time"
+
+	"github.com/Anvoria/authly/internal/database"
+	"github.com/Anvoria/authly/internal/domain/auth"
+)
+
+// SigningKey is a signing key kept in the database. The private key is encrypted with the
+// key-encryption key; the public key is stored in the clear so the JWKS can be exported without it.
+type SigningKey struct {
+	database.BaseModel
+
+	KID       string        `gorm:"column:kid;type:varchar(64);not null;uniqueIndex"`
+	Algorithm string        `gorm:"column:algorithm;type:varchar(10);not null"`
+	State     auth.KeyState `gorm:"column:state;type:varchar(10);not null"`
+
+	// EncryptedPrivateKey is the PKCS #8 private key sealed with AES-256-GCM; removed keys have none
+	EncryptedPrivateKey []byte `gorm:"column:encrypted_private_key;type:bytea"`
+	PublicKey           string `gorm:"column:public_key;type:text;not null"` // PKIX PEM
+
+	ActivatedAt *time.Time `gorm:"column:activated_at"`
+	RetiredAt   *time.Time `gorm:"column:retired_at"`
+}
+
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
@@ -0,0 +1,107 @@
+package signingkey
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+	"gorm.io/gorm"
+)
+
+// Repository interface for signing key operations
+type Repository interface {
+	Create(key *SigningKey) error
+	FindByKID(kid string) (*SigningKey, error)
+	FindAll() ([]*SigningKey, error)
+	Activate(kid string, now time.Time) error
+	Retire(kid string, now time.Time) error
+	Remove(kid string, now time.Time) error
+	Fingerprint() (string, error)
+}
+
+// repository struct for signing key operations
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository returns a Repository backed by the provided GORM DB handle.
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+// Create stores a new signing key
+func (r *repository) Create(key *SigningKey) error {
+	return r.db.Create(key).Error
+}
+
+// FindByKID returns the signing key with the key ID
+func (r *repository) FindByKID(kid string) (*SigningKey, error) {
+	var key SigningKey
+	if err := r.db.Where("kid = ?", kid).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// FindAll returns every signing key, oldest first
+func (r *repository) FindAll() ([]*SigningKey, error) {
+	var keys []*SigningKey
+	if err := r.db.Order("created_at ASC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Activate makes the key the active key and retires the previous one in a single transaction,
+// so readers never see two active keys or none
+func (r *repository) Activate(kid string, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&SigningKey{}).
+			Where("state = ? AND kid <> ?", auth.KeyStateActive, kid).
+			Updates(map[string]any{"state": auth.KeyStateRetired, "retired_at": now}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&SigningKey{}).
+			Where("kid = ? AND state <> ?", kid, auth.KeyStateRemoved).
+			Updates(map[string]any{"state": auth.KeyStateActive, "activated_at": now, "retired_at": nil})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// Retire stops the key from signing; it stays published
+func (r *repository) Retire(kid string, now time.Time) error {
+	return r.db.Model(&SigningKey{}).
+		Where("kid = ? AND state = ?", kid, auth.KeyStateNext).
+		Updates(map[string]any{"state": auth.KeyStateRetired, "retired_at": now}).Error
+}
+
+// Remove erases the encrypted private key and unpublishes the key; the row is kept as history
+func (r *repository) Remove(kid string, now time.Time) error {
+	return r.db.Model(&SigningKey{}).
+		Where("kid = ? AND state <> ?", kid, auth.KeyStateActive).
+		Updates(map[string]any{"state": auth.KeyStateRemoved, "encrypted_private_key": nil, "retired_at": gorm.Expr("COALESCE(retired_at, ?)", now)}).Error
+}
+
+// Fingerprint summarizes the table so that instances notice changes without loading the keys
+func (r *repository) Fingerprint() (string, error) {
+	var result struct {
+		Count   int64
+		Updated *time.Time
+	}
+	if err := r.db.Model(&SigningKey{}).Select("COUNT(*) AS count, MAX(updated_at) AS updated").Scan(&result).Error; err != nil {
+		return "", err
+	}
+
+	updated := ""
+	if result.Updated != nil {
+		updated = result.Updated.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("%d:%s", result.Count, updated), nil
+}
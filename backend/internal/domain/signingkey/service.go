@@ -0,0 +1,255 @@
+package signingkey
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	"gorm.io/gorm"
+)
+
+// Service manages signing keys stored in the database and serves them as an auth.KeySource,
+// so every instance sharing the database signs with the same keys
+type Service interface {
+	auth.KeySource
+
+	Generate(kid, alg string, bits int) (*SigningKey, error)
+	Import(kid string, privateKeyPEM []byte) (*SigningKey, error)
+	Activate(kid string) error
+	Retire(kid string, remove bool) error
+	List() ([]*SigningKey, error)
+	ExportJWKS() (jwk.Set, error)
+}
+
+// service struct for signing key operations
+type service struct {
+	repo Repository
+
+	// kek is nil for services that only read public keys
+	kek *KeyEncryptionKey
+}
+
+// NewService creates a new signing key service. kek may be nil when only List and ExportJWKS are used.
+func NewService(repo Repository, kek *KeyEncryptionKey) Service {
+	return &service{repo: repo, kek: kek}
+}
+
+// Generate creates a key for the algorithm. The first key becomes active; later keys are
+// published as next keys until they are activated.
+func (s *service) Generate(kid, alg string, bits int) (*SigningKey, error) {
+	priv, err := auth.GenerateSigningKey(alg, bits)
+	if err != nil {
+		return nil, err
+	}
+	return s.store(kid, priv, alg)
+}
+
+// Import stores an existing private key in the format of the keys directory
+func (s *service) Import(kid string, privateKeyPEM []byte) (*SigningKey, error) {
+	priv, alg, err := auth.ParsePrivateKeyPEM(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return s.store(kid, priv, alg)
+}
+
+func (s *service) store(kid string, priv crypto.Signer, alg string) (*SigningKey, error) {
+	if s.kek == nil {
+		return nil, ErrInvalidKeyEncryptionKey
+	}
+	if _, err := auth.NewSigningKey(kid, priv, alg); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.FindByKID(kid); err == nil {
+		return nil, ErrKeyExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	encrypted, err := s.kek.Seal(kid, der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	keys, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{
+		KID:                 kid,
+		Algorithm:           alg,
+		State:               auth.KeyStateNext,
+		EncryptedPrivateKey: encrypted,
+		PublicKey:           string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes})),
+	}
+	if !slices.ContainsFunc(keys, func(k *SigningKey) bool { return k.State == auth.KeyStateActive }) {
+		now := time.Now()
+		key.State = auth.KeyStateActive
+		key.ActivatedAt = &now
+	}
+
+	if err := s.repo.Create(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Activate makes the key sign new tokens; the previously active key is retired but stays published
+func (s *service) Activate(kid string) error {
+	key, err := s.find(kid)
+	if err != nil {
+		return err
+	}
+	if key.State == auth.KeyStateActive {
+		return nil
+	}
+	return s.repo.Activate(kid, time.Now())
+}
+
+// Retire stops a next key from signing, or with remove erases the private key of any key
+// other than the active one and unpublishes it
+func (s *service) Retire(kid string, remove bool) error {
+	key, err := s.find(kid)
+	if err != nil {
+		return err
+	}
+	if key.State == auth.KeyStateActive {
+		return auth.ErrCannotRetireActiveKey
+	}
+
+	if remove {
+		return s.repo.Remove(kid, time.Now())
+	}
+	return s.repo.Retire(kid, time.Now())
+}
+
+// find returns a key that has not been removed
+func (s *service) find(kid string) (*SigningKey, error) {
+	key, err := s.repo.FindByKID(kid)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+	if key.State == auth.KeyStateRemoved {
+		return nil, ErrKeyRemoved
+	}
+	return key, nil
+}
+
+// List returns every key, including removed ones
+func (s *service) List() ([]*SigningKey, error) {
+	return s.repo.FindAll()
+}
+
+// ExportJWKS returns the published public keys; it does not need the key-encryption key
+func (s *service) ExportJWKS() (jwk.Set, error) {
+	keys, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	keySet := jwk.NewSet()
+	for _, key := range keys {
+		if key.State == auth.KeyStateRemoved {
+			continue
+		}
+
+		block, _ := pem.Decode([]byte(key.PublicKey))
+		if block == nil {
+			return nil, fmt.Errorf("signing key %s: invalid public key", key.KID)
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", key.KID, err)
+		}
+
+		jwkKey, err := jwk.Import(pub)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", key.KID, err)
+		}
+		if err := jwkKey.Set(jwk.KeyIDKey, fmt.Sprintf("key-%s", key.KID)); err != nil {
+			return nil, err
+		}
+		if err := jwkKey.Set(jwk.AlgorithmKey, key.Algorithm); err != nil {
+			return nil, err
+		}
+		if err := keySet.AddKey(jwkKey); err != nil {
+			return nil, err
+		}
+	}
+	return keySet, nil
+}
+
+// Load decrypts the keys into a key store that reloads from the database
+func (s *service) Load() (*auth.KeyStore, error) {
+	if s.kek == nil {
+		return nil, ErrInvalidKeyEncryptionKey
+	}
+
+	keys, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var signingKeys []auth.SigningKey
+	states := make(map[string]auth.KeyState)
+	activeKid := ""
+	for _, key := range keys {
+		if key.State == auth.KeyStateRemoved {
+			continue
+		}
+
+		der, err := s.kek.Open(key.KID, key.EncryptedPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", key.KID, err)
+		}
+		priv, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", key.KID, err)
+		}
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("signing key %s: unsupported key type %T", key.KID, priv)
+		}
+
+		signingKey, err := auth.NewSigningKey(key.KID, signer, key.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", key.KID, err)
+		}
+		signingKeys = append(signingKeys, signingKey)
+		states[fmt.Sprintf("key-%s", key.KID)] = key.State
+
+		if key.State == auth.KeyStateActive {
+			activeKid = key.KID
+		}
+	}
+
+	if activeKid == "" {
+		return nil, ErrNoActiveKey
+	}
+	return auth.NewKeyStore(activeKid, signingKeys, states, s)
+}
+
+// Fingerprint changes whenever a key is added or changes state
+func (s *service) Fingerprint() (string, error) {
+	return s.repo.Fingerprint()
+}
@@ -0,0 +1,143 @@
+package signingkey
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+	"github.com/lestrrat-go/jwx/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type memoryRepository struct {
+	keys    []*SigningKey
+	changes int
+}
+
+func (r *memoryRepository) Create(key *SigningKey) error {
+	key.CreatedAt = time.Now()
+	r.keys = append(r.keys, key)
+	r.changes++
+	return nil
+}
+
+func (r *memoryRepository) FindByKID(kid string) (*SigningKey, error) {
+	for _, key := range r.keys {
+		if key.KID == kid {
+			return key, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) FindAll() ([]*SigningKey, error) {
+	return r.keys, nil
+}
+
+func (r *memoryRepository) Activate(kid string, now time.Time) error {
+	for _, key := range r.keys {
+		switch {
+		case key.KID == kid:
+			key.State = auth.KeyStateActive
+		case key.State == auth.KeyStateActive:
+			key.State = auth.KeyStateRetired
+		}
+	}
+	r.changes++
+	return nil
+}
+
+func (r *memoryRepository) Retire(kid string, now time.Time) error {
+	key, _ := r.FindByKID(kid)
+	key.State = auth.KeyStateRetired
+	r.changes++
+	return nil
+}
+
+func (r *memoryRepository) Remove(kid string, now time.Time) error {
+	key, _ := r.FindByKID(kid)
+	key.State = auth.KeyStateRemoved
+	key.EncryptedPrivateKey = nil
+	r.changes++
+	return nil
+}
+
+func (r *memoryRepository) Fingerprint() (string, error) {
+	return fmt.Sprint(r.changes), nil
+}
+
+func newKEK(t *testing.T) *KeyEncryptionKey {
+	raw := make([]byte, 32)
+	_, err := rand.Read(raw)
+	require.NoError(t, err)
+
+	kek, err := ParseKeyEncryptionKey(base64.StdEncoding.EncodeToString(raw))
+	require.NoError(t, err)
+	return kek
+}
+
+func TestDatabaseKeys(t *testing.T) {
+	repo := &memoryRepository{}
+	keys := NewService(repo, newKEK(t))
+
+	first, err := keys.Generate("first", "ES256", 0)
+	require.NoError(t, err)
+	assert.Equal(t, auth.KeyStateActive, first.State, "the first key becomes active")
+	assert.NotContains(t, string(first.EncryptedPrivateKey), "PRIVATE KEY")
+
+	// Keys from the keys directory can be imported
+	dir := t.TempDir()
+	require.NoError(t, auth.GenerateKeyPair(dir, "legacy", "PS256", 2048))
+	pemData, err := os.ReadFile(filepath.Join(dir, "private-legacy.pem"))
+	require.NoError(t, err)
+	legacy, err := keys.Import("legacy", pemData)
+	require.NoError(t, err)
+	assert.Equal(t, "PS256", legacy.Algorithm)
+	assert.Equal(t, auth.KeyStateNext, legacy.State)
+
+	_, err = keys.Import("legacy", pemData)
+	assert.ErrorIs(t, err, ErrKeyExists)
+
+	ks, err := keys.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "first", ks.ActiveKeyID())
+	assert.Equal(t, 2, ks.JWKS().Len())
+
+	exported, err := NewService(repo, nil).ExportJWKS()
+	require.NoError(t, err)
+	assert.Equal(t, 2, exported.Len(), "the public keys are exported without the key-encryption key")
+
+	t.Run("activation is picked up on reload", func(t *testing.T) {
+		reloader := auth.NewKeyReloader(ks, nil)
+		require.NoError(t, keys.Activate("legacy"))
+		reloader.Reload(t.Context(), "test", false)
+		assert.Equal(t, "legacy", ks.ActiveKeyID())
+
+		token, err := jwt.NewBuilder().Subject("user-1").Build()
+		require.NoError(t, err)
+		signed, err := ks.SignToken(token)
+		require.NoError(t, err)
+		_, err = ks.VerifySignature(signed)
+		assert.NoError(t, err)
+	})
+
+	t.Run("a wrong key-encryption key cannot load the keys", func(t *testing.T) {
+		_, err := NewService(repo, newKEK(t)).Load()
+		assert.ErrorIs(t, err, ErrDecryptionFailed)
+	})
+
+	t.Run("removed keys are unpublished", func(t *testing.T) {
+		assert.ErrorIs(t, keys.Retire("legacy", true), auth.ErrCannotRetireActiveKey)
+		require.NoError(t, keys.Retire("first", true))
+
+		require.NoError(t, ks.Reload())
+		assert.Equal(t, 1, ks.JWKS().Len())
+	})
+}
@@ -0,0 +1,19 @@
+package subject
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PairwiseSubject maps a pairwise subject identifier back to the user it was derived for
+type PairwiseSubject struct {
+	Subject          string    `gorm:"column:subject;type:varchar(64);primaryKey"`
+	SectorIdentifier string    `gorm:"column:sector_identifier;type:varchar(255);not null"`
+	UserID           uuid.UUID `gorm:"column:user_id;type:uuid;not null;index"`
+	CreatedAt        time.Time `gorm:"column:created_at;not null"`
+}
+
+func (PairwiseSubject) TableName() string {
+	return "pairwise_subjects"
+}
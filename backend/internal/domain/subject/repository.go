@@ -0,0 +1,36 @@
+package subject
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository interface for pairwise subject operations
+type Repository interface {
+	Save(subject *PairwiseSubject) error
+	FindBySubject(sub string) (*PairwiseSubject, error)
+}
+
+// repository struct for pairwise subject operations
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository returns a Repository backed by the provided GORM DB handle.
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+// Save records the mapping. Subjects are deterministic, so an existing row is left as is.
+func (r *repository) Save(subject *PairwiseSubject) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(subject).Error
+}
+
+// FindBySubject returns the mapping of a pairwise subject
+func (r *repository) FindBySubject(sub string) (*PairwiseSubject, error) {
+	var subject PairwiseSubject
+	if err := r.db.Where("subject = ?", sub).First(&subject).Error; err != nil {
+		return nil, err
+	}
+	return &subject, nil
+}
@@ -0,0 +1,104 @@
+package subject
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrPairwiseDisabled is returned when a pairwise service is served without a configured secret
+	ErrPairwiseDisabled = errors.New("pairwise subjects require auth.pairwise_secret")
+
+	// ErrUnknownSubject is returned when a subject identifier maps to no user
+	ErrUnknownSubject = errors.New("unknown subject identifier")
+)
+
+// Service derives the subject identifier ("sub") a service sees for a user and maps it back.
+// Public services see the user ID. Pairwise services see an HMAC of their sector and the user ID,
+// so services in different sectors cannot correlate users (OIDC Core Section 8.1).
+type Service interface {
+	For(userID string, client *svc.Service) (string, error)
+	Resolve(sub string) (string, error)
+}
+
+type service struct {
+	repo   Repository
+	secret []byte
+	// users caches subject to user ID mappings; they never change once derived
+	users sync.Map
+}
+
+// NewService creates a subject Service. Pairwise subjects are keyed with secret, which must
+// stay the same for subjects to remain stable; an empty secret disables pairwise services.
+func NewService(repo Repository, secret string) Service {
+	return &service{repo: repo, secret: []byte(secret)}
+}
+
+// For returns the subject identifier of the user as seen by client
+func (s *service) For(userID string, client *svc.Service) (string, error) {
+	if client == nil || !client.IsPairwise() {
+		return userID, nil
+	}
+	if len(s.secret) == 0 {
+		return "", ErrPairwiseDisabled
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return "", fmt.Errorf("invalid user id: %w", err)
+	}
+
+	sector := client.Sector()
+	sub := pairwise(s.secret, sector, userID)
+	if _, ok := s.users.Load(sub); ok {
+		return sub, nil
+	}
+
+	if err := s.repo.Save(&PairwiseSubject{Subject: sub, SectorIdentifier: sector, UserID: uid}); err != nil {
+		return "", fmt.Errorf("failed to save pairwise subject: %w", err)
+	}
+	s.users.Store(sub, userID)
+
+	return sub, nil
+}
+
+// Resolve returns the user ID behind a subject identifier. Public subjects are user (or service)
+// IDs already and are returned unchanged.
+func (s *service) Resolve(sub string) (string, error) {
+	if _, err := uuid.Parse(sub); err == nil {
+		return sub, nil
+	}
+
+	if userID, ok := s.users.Load(sub); ok {
+		return userID.(string), nil
+	}
+
+	mapping, err := s.repo.FindBySubject(sub)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrUnknownSubject
+		}
+		return "", fmt.Errorf("failed to find pairwise subject: %w", err)
+	}
+
+	userID := mapping.UserID.String()
+	s.users.Store(sub, userID)
+	return userID, nil
+}
+
+// pairwise derives the pairwise subject identifier of a user within a sector
+func pairwise(secret []byte, sector, userID string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(sector))
+	mac.Write([]byte{0})
+	mac.Write([]byte(userID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,80 @@
+package subject
+
+import (
+	"testing"
+
+	svc "github.com/Anvoria/authly/internal/domain/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type memoryRepository map[string]*PairwiseSubject
+
+func (r memoryRepository) Save(subject *PairwiseSubject) error {
+	if _, ok := r[subject.Subject]; !ok {
+		r[subject.Subject] = subject
+	}
+	return nil
+}
+
+func (r memoryRepository) FindBySubject(sub string) (*PairwiseSubject, error) {
+	subject, ok := r[sub]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return subject, nil
+}
+
+func TestSubjects(t *testing.T) {
+	const userID = "5f0c6a52-3d1b-4c8e-9a53-5d8e2f6c7b10"
+
+	repo := memoryRepository{}
+	subjects := NewService(repo, "secret")
+
+	public := &svc.Service{ClientID: "public", SubjectType: svc.SubjectTypePublic}
+	appA := &svc.Service{ClientID: "a", SubjectType: svc.SubjectTypePairwise, RedirectURIs: []string{"https://app.example.com/cb"}}
+	appB := &svc.Service{ClientID: "b", SubjectType: svc.SubjectTypePairwise, RedirectURIs: []string{"https://app.example.com/other"}}
+	other := &svc.Service{ClientID: "c", SubjectType: svc.SubjectTypePairwise, RedirectURIs: []string{"https://other.example.com/cb"}}
+
+	t.Run("public clients see the user id", func(t *testing.T) {
+		sub, err := subjects.For(userID, public)
+		require.NoError(t, err)
+		assert.Equal(t, userID, sub)
+	})
+
+	t.Run("pairwise subjects are per sector", func(t *testing.T) {
+		subA, err := subjects.For(userID, appA)
+		require.NoError(t, err)
+		subB, err := subjects.For(userID, appB)
+		require.NoError(t, err)
+		subOther, err := subjects.For(userID, other)
+		require.NoError(t, err)
+
+		assert.NotEqual(t, userID, subA)
+		assert.Equal(t, subA, subB)
+		assert.NotEqual(t, subA, subOther)
+	})
+
+	t.Run("resolve maps back to the user", func(t *testing.T) {
+		sub, err := subjects.For(userID, other)
+		require.NoError(t, err)
+
+		// A fresh service has to go through the repository
+		resolved, err := NewService(repo, "secret").Resolve(sub)
+		require.NoError(t, err)
+		assert.Equal(t, userID, resolved)
+
+		resolved, err = subjects.Resolve(userID)
+		require.NoError(t, err)
+		assert.Equal(t, userID, resolved)
+
+		_, err = subjects.Resolve("unknown")
+		assert.ErrorIs(t, err, ErrUnknownSubject)
+	})
+
+	t.Run("pairwise needs a secret", func(t *testing.T) {
+		_, err := NewService(repo, "").For(userID, appA)
+		assert.ErrorIs(t, err, ErrPairwiseDisabled)
+	})
+}
@@ -18,6 +18,7 @@ type Service interface {
 	Register(req RegisterRequest) (*User, error)
 	GetUserInfo(userID string) (*User, error)
 	FindByUsername(username string) (*User, error)
+	FindByEmail(email string) (*User, error)
 	VerifyPassword(u *User, password string) bool
 }
 
@@ -77,6 +78,10 @@ func (s *service) FindByUsername(username string) (*User, error) {
 	return s.repo.FindByUsername(username)
 }
 
+func (s *service) FindByEmail(email string) (*User, error) {
+	return s.repo.FindByEmail(email)
+}
+
 func (s *service) VerifyPassword(u *User, password string) bool {
 	return s.repo.VerifyPassword(u, password)
 }
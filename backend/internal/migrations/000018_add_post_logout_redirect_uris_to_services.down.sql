@@ -0,0 +1 @@
+ALTER TABLE services DROP COLUMN IF EXISTS post_logout_redirect_uris;
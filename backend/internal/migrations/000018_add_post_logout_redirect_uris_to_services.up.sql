@@ -0,0 +1 @@
+ALTER TABLE services ADD COLUMN IF NOT EXISTS post_logout_redirect_uris TEXT[] DEFAULT '{}';
@@ -0,0 +1 @@
+ALTER TABLE services ADD COLUMN IF NOT EXISTS backchannel_logout_uri TEXT;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS session_clients (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    session_id UUID NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    CONSTRAINT fk_session_clients_session FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_session_clients_session_client ON session_clients(session_id, client_id);
+CREATE INDEX IF NOT EXISTS idx_session_clients_deleted_at ON session_clients(deleted_at);
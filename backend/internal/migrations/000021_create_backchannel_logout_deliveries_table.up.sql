@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS backchannel_logout_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    session_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    logout_uri TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_backchannel_logout_deliveries_deleted_at ON backchannel_logout_deliveries(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_backchannel_logout_deliveries_session_id ON backchannel_logout_deliveries(session_id);
+CREATE INDEX IF NOT EXISTS idx_backchannel_logout_deliveries_due ON backchannel_logout_deliveries(status, next_attempt_at);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS device_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    device_code VARCHAR(255) UNIQUE NOT NULL,
+    user_code VARCHAR(16) UNIQUE NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    scopes TEXT NOT NULL,
+    user_id UUID,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    poll_interval INTEGER NOT NULL DEFAULT 5,
+    last_polled_at TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_device_codes_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_codes_deleted_at ON device_codes(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_device_codes_client_id ON device_codes(client_id);
+CREATE INDEX IF NOT EXISTS idx_device_codes_expires_at ON device_codes(expires_at);
@@ -0,0 +1,3 @@
+ALTER TABLE services DROP COLUMN IF EXISTS jwks;
+ALTER TABLE services DROP COLUMN IF EXISTS jwks_uri;
+ALTER TABLE services DROP COLUMN IF EXISTS token_endpoint_auth_method;
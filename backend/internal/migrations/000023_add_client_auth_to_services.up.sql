@@ -0,0 +1,11 @@
+ALTER TABLE services ADD COLUMN IF NOT EXISTS token_endpoint_auth_method VARCHAR(50);
+ALTER TABLE services ADD COLUMN IF NOT EXISTS jwks_uri TEXT;
+ALTER TABLE services ADD COLUMN IF NOT EXISTS jwks TEXT;
+
+-- Existing clients keep working the way they authenticate today: public clients send no secret
+-- and confidential clients may still post theirs until an administrator tightens the method.
+UPDATE services SET token_endpoint_auth_method = 'none' WHERE token_endpoint_auth_method IS NULL AND (client_secret IS NULL OR client_secret = '');
+UPDATE services SET token_endpoint_auth_method = 'client_secret_post' WHERE token_endpoint_auth_method IS NULL;
+
+ALTER TABLE services ALTER COLUMN token_endpoint_auth_method SET DEFAULT 'client_secret_basic';
+ALTER TABLE services ALTER COLUMN token_endpoint_auth_method SET NOT NULL;
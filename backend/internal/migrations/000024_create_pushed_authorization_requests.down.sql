@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS pushed_authorization_requests;
+ALTER TABLE services DROP COLUMN IF EXISTS require_pushed_authorization_requests;
@@ -0,0 +1,16 @@
+ALTER TABLE services ADD COLUMN IF NOT EXISTS require_pushed_authorization_requests BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS pushed_authorization_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    request_uri VARCHAR(255) UNIQUE NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    parameters TEXT NOT NULL,
+    used BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_pushed_authorization_requests_deleted_at ON pushed_authorization_requests(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_pushed_authorization_requests_expires_at ON pushed_authorization_requests(expires_at);
@@ -0,0 +1,3 @@
+ALTER TABLE services DROP COLUMN IF EXISTS registration_access_token_hash;
+ALTER TABLE services DROP COLUMN IF EXISTS logo_uri;
+ALTER TABLE services DROP COLUMN IF EXISTS grant_types;
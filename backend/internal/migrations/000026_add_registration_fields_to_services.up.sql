@@ -0,0 +1,3 @@
+ALTER TABLE services ADD COLUMN IF NOT EXISTS grant_types TEXT[] DEFAULT '{}';
+ALTER TABLE services ADD COLUMN IF NOT EXISTS logo_uri TEXT;
+ALTER TABLE services ADD COLUMN IF NOT EXISTS registration_access_token_hash VARCHAR(64);
@@ -0,0 +1,2 @@
+ALTER TABLE services DROP COLUMN IF EXISTS sector_identifier;
+ALTER TABLE services DROP COLUMN IF EXISTS subject_type;
@@ -0,0 +1,2 @@
+ALTER TABLE services ADD COLUMN IF NOT EXISTS subject_type VARCHAR(20) NOT NULL DEFAULT 'public';
+ALTER TABLE services ADD COLUMN IF NOT EXISTS sector_identifier VARCHAR(255);
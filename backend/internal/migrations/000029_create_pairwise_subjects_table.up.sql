@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS pairwise_subjects (
+    subject VARCHAR(64) PRIMARY KEY,
+    sector_identifier VARCHAR(255) NOT NULL,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_pairwise_subjects_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_pairwise_subjects_user_id ON pairwise_subjects(user_id);
@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN IF EXISTS requested_claims;
+ALTER TABLE authorization_codes DROP COLUMN IF EXISTS claims;
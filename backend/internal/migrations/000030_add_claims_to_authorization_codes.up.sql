@@ -0,0 +1,2 @@
+ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS claims TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS requested_claims TEXT;
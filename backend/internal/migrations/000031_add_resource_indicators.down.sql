@@ -0,0 +1,3 @@
+ALTER TABLE sessions DROP COLUMN IF EXISTS resources;
+ALTER TABLE authorization_codes DROP COLUMN IF EXISTS resources;
+ALTER TABLE services DROP COLUMN IF EXISTS allowed_resources;
@@ -0,0 +1,3 @@
+ALTER TABLE services ADD COLUMN IF NOT EXISTS allowed_resources TEXT[];
+ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS resources TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS resources TEXT;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS backchannel_authentications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    auth_req_id VARCHAR(255) UNIQUE NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    user_id UUID NOT NULL,
+    scopes TEXT NOT NULL,
+    resources TEXT,
+    binding_message TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    poll_interval INTEGER NOT NULL DEFAULT 5,
+    last_polled_at TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_backchannel_authentications_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_backchannel_authentications_deleted_at ON backchannel_authentications(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_backchannel_authentications_client_id ON backchannel_authentications(client_id);
+CREATE INDEX IF NOT EXISTS idx_backchannel_authentications_user_id ON backchannel_authentications(user_id);
+CREATE INDEX IF NOT EXISTS idx_backchannel_authentications_expires_at ON backchannel_authentications(expires_at);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_sessions_parent_id;
+ALTER TABLE sessions DROP COLUMN IF EXISTS parent_id;
+
+ALTER TABLE authorization_codes DROP COLUMN IF EXISTS auth_time;
+ALTER TABLE authorization_codes DROP COLUMN IF EXISTS session_id;
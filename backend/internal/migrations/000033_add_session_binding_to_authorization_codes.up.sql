@@ -0,0 +1,5 @@
+ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS session_id UUID;
+ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS auth_time TIMESTAMP;
+
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES sessions(id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS idx_sessions_parent_id ON sessions(parent_id);
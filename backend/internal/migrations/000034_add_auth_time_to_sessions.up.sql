@@ -0,0 +1,4 @@
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS auth_time TIMESTAMP;
+
+UPDATE sessions SET auth_time = created_at WHERE auth_time IS NULL AND parent_id IS NULL;
+UPDATE sessions SET auth_time = parent.auth_time FROM sessions parent WHERE sessions.parent_id = parent.id AND sessions.auth_time IS NULL;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS session_refresh_hashes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    session_id UUID NOT NULL,
+    refresh_hash VARCHAR(255) NOT NULL,
+    retired_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_session_refresh_hashes_session FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_session_refresh_hashes_session_hash ON session_refresh_hashes(session_id, refresh_hash);
+CREATE INDEX IF NOT EXISTS idx_session_refresh_hashes_deleted_at ON session_refresh_hashes(deleted_at);
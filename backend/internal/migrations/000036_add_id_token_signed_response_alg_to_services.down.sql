@@ -0,0 +1 @@
+ALTER TABLE services DROP COLUMN IF EXISTS id_token_signed_response_alg;
@@ -0,0 +1 @@
+ALTER TABLE services ADD COLUMN IF NOT EXISTS id_token_signed_response_alg VARCHAR(10);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kid VARCHAR(64) NOT NULL,
+    algorithm VARCHAR(10) NOT NULL,
+    state VARCHAR(10) NOT NULL,
+    encrypted_private_key BYTEA,
+    public_key TEXT NOT NULL,
+    activated_at TIMESTAMP,
+    retired_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_signing_keys_kid ON signing_keys(kid);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_signing_keys_active ON signing_keys(state) WHERE state = 'active';
+CREATE INDEX IF NOT EXISTS idx_signing_keys_deleted_at ON signing_keys(deleted_at);
@@ -0,0 +1 @@
+ALTER TABLE pushed_authorization_requests DROP COLUMN IF EXISTS interactive;
@@ -0,0 +1 @@
+ALTER TABLE pushed_authorization_requests ADD COLUMN IF NOT EXISTS interactive BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,71 @@
+// Package safehttp provides an HTTP client for URLs that clients register themselves, such as
+// jwks_uri and backchannel_logout_uri, which must not reach the server's own networks.
+package safehttp
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// MaxRedirects bounds the redirects followed from a registered URL
+const MaxRedirects = 3
+
+// ErrForbiddenAddress is returned when a URL resolves to an address on the server's own networks
+var ErrForbiddenAddress = errors.New("address is not public")
+
+// NewClient returns a client that only connects to public addresses. Without the check, clients,
+// self-registered ones included, could make the server request its loopback, private and
+// link-local networks. The address is checked when connecting, after DNS resolution, so neither
+// redirects nor DNS rebinding get around it.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !IsPublicAddr(addrPort.Addr()) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		// No proxy: the address check has to see the real destination
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= MaxRedirects {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "https" {
+				return errors.New("redirect away from https")
+			}
+			return nil
+		},
+	}
+}
+
+// IsPublicAddr reports whether addr is a globally routable unicast address
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!addr.IsLoopback() &&
+		!addr.IsLinkLocalUnicast() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which is not routable from the internet either
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
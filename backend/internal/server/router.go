@@ -1,22 +1,33 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/Anvoria/authly/internal/cache"
 	"github.com/Anvoria/authly/internal/config"
 	"github.com/Anvoria/authly/internal/database"
 	"github.com/Anvoria/authly/internal/domain/auth"
+	"github.com/Anvoria/authly/internal/domain/backchannel"
+	"github.com/Anvoria/authly/internal/domain/consent"
 	"github.com/Anvoria/authly/internal/domain/oidc"
 	perm "github.com/Anvoria/authly/internal/domain/permission"
+	"github.com/Anvoria/authly/internal/domain/registration"
 	"github.com/Anvoria/authly/internal/domain/role"
 	svc "github.com/Anvoria/authly/internal/domain/service"
 	"github.com/Anvoria/authly/internal/domain/session"
+	"github.com/Anvoria/authly/internal/domain/signingkey"
+	"github.com/Anvoria/authly/internal/domain/subject"
 	"github.com/Anvoria/authly/internal/domain/user"
+	"github.com/Anvoria/authly/internal/transit"
 	"github.com/gofiber/fiber/v2"
 )
 
+// defaultKeysReloadInterval is how often the keys are checked for changes
+const defaultKeysReloadInterval = 10 * time.Second
+
 // SetupRoutes configures HTTP routes, repositories, services, authentication, and middleware on the provided Fiber app.
 //
 // It mounts the API under "/v1", registers authentication endpoints ("/auth/login", "/auth/register"),
@@ -47,33 +58,101 @@ func SetupRoutes(app *fiber.App, envConfig *config.Environment, cfg *config.Conf
 	// Initialize cache
 	serviceCache := cache.NewServiceCache(serviceRepo)
 	tokenRevocationCache := cache.NewTokenRevocationCache()
+	replayCache := cache.NewReplayCache()
 
 	// Initialize services
 	sessionService := session.NewServiceWithCache(sessionRepo, tokenRevocationCache)
+	if cfg.Auth.RefreshTokenReuseGrace > 0 {
+		sessionService.SetReuseGracePeriod(time.Duration(cfg.Auth.RefreshTokenReuseGrace) * time.Second)
+	}
 	serviceRepoAdapter := perm.NewServiceRepositoryAdapter(serviceRepo)
 	permissionService := perm.NewService(permissionRepo, serviceRepoAdapter)
 	userService := user.NewService(userRepo)
+
+	// Tell users when a stolen refresh token revoked one of their sessions
+	switch notification := cfg.Auth.ReuseNotification; notification.Channel {
+	case config.ReuseNotificationLog:
+		sessionService.AddReuseListener(session.NewLogReuseNotifier())
+	case config.ReuseNotificationEmail:
+		sessionService.AddReuseListener(session.NewEmailReuseNotifier(userService, session.SMTPSettings{
+			Host:     notification.SMTP.Host,
+			Port:     notification.SMTP.Port,
+			Username: notification.SMTP.Username,
+			Password: notification.SMTP.PasswordFor(envConfig),
+			From:     notification.SMTP.From,
+		}))
+	}
 	roleService := role.NewService(database.DB, roleRepo, permissionRepo)
 
-	keyStore, err := auth.LoadKeys(cfg.Auth.KeysPath, cfg.Auth.ActiveKID)
+	var keyStore *auth.KeyStore
+	var err error
+	switch {
+	case cfg.Auth.UsesDatabaseKeys():
+		kek, kekErr := signingkey.ParseKeyEncryptionKey(cfg.Auth.KeyEncryptionKeyFor(envConfig))
+		if kekErr != nil {
+			return fmt.Errorf("failed to load keys: %w", kekErr)
+		}
+		keyStore, err = signingkey.NewService(signingkey.NewRepository(database.DB), kek).Load()
+	case cfg.Auth.UsesTransitKeys():
+		// The private keys stay in the transit service; only the public keys are loaded
+		client := transit.NewClient(cfg.Auth.Transit.URL, cfg.Auth.Transit.TokenFor(envConfig), cfg.Auth.Transit.Timeout())
+		keyStore, err = transit.NewKeySource(client, cfg.Auth.ActiveKID).Load()
+	default:
+		keyStore, err = auth.LoadKeys(cfg.Auth.KeysPath, cfg.Auth.ActiveKID)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to load keys: %w", err)
 	}
 
 	activeKey, err := keyStore.GetActiveKey()
 	if err != nil {
-		return fmt.Errorf("active key with KID %s not found in key store: %w", cfg.Auth.ActiveKID, err)
+		return fmt.Errorf("active key with KID %s not found in key store: %w", keyStore.ActiveKeyID(), err)
 	}
 
 	keyID, _ := activeKey.KeyID()
-	slog.Info("Active key loaded", "key", cfg.Auth.ActiveKID, "key_id", keyID)
+	slog.Info("Active key loaded", "key", keyStore.ActiveKeyID(), "key_id", keyID)
+
+	// Reload the keys when they change, on SIGHUP, or when another instance announces a change
+	reloadInterval := defaultKeysReloadInterval
+	if cfg.Auth.KeysReloadInterval > 0 {
+		reloadInterval = time.Duration(cfg.Auth.KeysReloadInterval) * time.Second
+	}
+	keyReloader := auth.NewKeyReloader(keyStore, cache.NewKeyReloadBroadcaster())
+	go keyReloader.Run(context.Background(), reloadInterval)
+
+	// Scheduled signing key rotation
+	if rotation := cfg.Auth.KeyRotation; rotation.Enabled {
+		policy := auth.RotationPolicy{
+			Interval:       rotation.Interval(),
+			PrePublication: rotation.PrePublication(),
+			Retirement:     rotation.Retirement(),
+			Algorithm:      rotation.Algorithm,
+		}
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("invalid key rotation policy: %w", err)
+		}
+		rotator := auth.NewRotator(cfg.Auth.KeysPath, cfg.Auth.ActiveKID, policy)
+		go rotator.Run(context.Background(), 5*time.Minute, keyReloader)
+	}
 
 	issuer := cfg.Server.Domain
 
+	// Pairwise subject identifiers (OIDC Core Section 8.1)
+	if cfg.Auth.PairwiseSecret == "" {
+		slog.Warn("auth.pairwise_secret is not set; services with the pairwise subject type cannot be served")
+	}
+	subjectService := subject.NewService(subject.NewRepository(database.DB), cfg.Auth.PairwiseSecret)
+
 	// Initialize auth service
-	authService := auth.NewService(database.DB, userRepo, sessionService, permissionService, roleService, keyStore, issuer, tokenRevocationCache)
+	authService := auth.NewService(database.DB, userRepo, sessionService, permissionService, roleService, keyStore, subjectService, issuer, tokenRevocationCache, replayCache)
 	authHandler := auth.NewHandler(authService, userService, permissionService)
 
+	// Notify relying parties of revoked sessions (OIDC Back-Channel Logout)
+	backchannelRepo := backchannel.NewRepository(database.DB)
+	backchannelService := backchannel.NewService(backchannelRepo, sessionService, serviceRepo, keyStore, subjectService, issuer)
+	sessionService.AddRevocationListener(backchannelService)
+	go backchannelService.Run(context.Background(), 30*time.Second)
+
 	// Setup auth routes
 	authGroup := api.Group("/auth")
 	authGroup.Post("/login", authHandler.Login)
@@ -83,11 +162,17 @@ func SetupRoutes(app *fiber.App, envConfig *config.Environment, cfg *config.Conf
 	authSessionGroup.Use(oidc.SessionMiddleware(sessionService, permissionService))
 	authSessionGroup.Get("/me", authHandler.Me)
 
+	consentService := consent.NewService(consent.NewRepository(database.DB), serviceRepo, sessionService)
+	consentHandler := consent.NewHandler(consentService)
+	authSessionGroup.Get("/me/consents", consentHandler.List)
+	authSessionGroup.Delete("/me/consents/:client_id", consentHandler.Revoke)
+
 	authServiceRepoAdapter := auth.NewServiceRepositoryAdapter(serviceCache)
 
 	// Initialize OIDC repositories and services
 	authCodeRepo := oidc.NewRepository(database.DB)
-	oidcService := oidc.NewService(serviceRepo, authCodeRepo, authService, sessionService, permissionService, userService)
+	// Backchannel authentication requests only reach users through the log until a push channel is configured
+	oidcService := oidc.NewService(serviceRepo, authCodeRepo, authService, sessionService, permissionService, userService, consentService, replayCache, oidc.NewLogNotifier())
 	oidcHandler := oidc.NewHandler(oidcService)
 
 	oauthGroup := api.Group("/oauth")
@@ -95,15 +180,35 @@ func SetupRoutes(app *fiber.App, envConfig *config.Environment, cfg *config.Conf
 	oauthGroup.Get("/authorize", oidcHandler.Authorize)
 	oauthGroup.Post("/authorize/confirm", oidcHandler.ConfirmAuthorization)
 	oauthGroup.Post("/token", oidcHandler.Token)
+	oauthGroup.Post("/par", oidcHandler.PushAuthorization)
+	oauthGroup.Post("/introspect", oidcHandler.Introspect)
+	oauthGroup.Post("/revoke", oidcHandler.Revoke)
+	oauthGroup.Get("/logout", oidcHandler.Logout)
+	oauthGroup.Post("/logout", oidcHandler.Logout)
+	oauthGroup.Post("/logout/confirm", oidcHandler.ConfirmLogout)
+	oauthGroup.Post("/device_authorization", oidcHandler.DeviceAuthorization)
+	oauthGroup.Get("/device/validate", oidcHandler.ValidateDevice)
+	oauthGroup.Post("/device/confirm", oidcHandler.ConfirmDevice)
+	oauthGroup.Post("/bc-authorize", oidcHandler.BackchannelAuthentication)
+	oauthGroup.Get("/bc-authorize/pending", oidcHandler.ListBackchannelAuthentications)
+	oauthGroup.Post("/bc-authorize/confirm", oidcHandler.ConfirmBackchannelAuthentication)
 
 	oauthGroup.Get("/authorize/validate", oidcHandler.ValidateAuthorization)
 
+	// Dynamic client registration (RFC 7591) and client configuration (RFC 7592)
+	registrationService := registration.NewService(svc.NewService(serviceRepo, serviceCache, keyStore), cfg.Auth.RegistrationTokens, issuer)
+	registrationHandler := registration.NewHandler(registrationService)
+	oauthGroup.Post("/register", registrationHandler.Register)
+	oauthGroup.Get("/register/:client_id", registrationHandler.Read)
+	oauthGroup.Put("/register/:client_id", registrationHandler.Update)
+	oauthGroup.Delete("/register/:client_id", registrationHandler.Delete)
+
 	oauthGroupProtected := api.Group("/oauth")
 	oauthGroupProtected.Use(auth.AuthMiddleware(keyStore, authService, issuer, authServiceRepoAdapter))
 	oauthGroupProtected.Get("/userinfo", oidcHandler.UserInfo)
 
 	// Setup well-known endpoints
 	app.Get("/.well-known/jwks.json", auth.JWKSHandler(keyStore))
-	app.Get("/.well-known/openid-configuration", oidc.OpenIDConfigurationHandler(issuer))
+	app.Get("/.well-known/openid-configuration", oidc.OpenIDConfigurationHandler(issuer, keyStore))
 	return nil
 }
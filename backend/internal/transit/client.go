@@ -0,0 +1,126 @@
+// Package transit signs tokens with keys held by a transit signing service, such as a gateway in
+// front of an HSM or KMS. The private keys never leave the service: it publishes the public keys
+// and returns signatures over digests.
+//
+// The protocol is small enough to put in front of any HSM:
+//
+//	GET  /keys              {"keys": [{"name": "main", "algorithm": "ES256", "public_key": "<PKIX PEM>"}]}
+//	POST /keys/{name}/sign  {"input": "<base64 digest>", "hash": "SHA-256", "pss": false} -> {"signature": "<base64>"}
+//
+// Ed25519 keys sign the whole message, sent as input with an empty hash. ECDSA signatures are
+// ASN.1 encoded, as crypto.Signer returns them. Requests carry the token as a bearer token.
+package transit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds a request to the transit service, so that a hung service fails token issuance quickly
+const DefaultTimeout = 5 * time.Second
+
+// KeyInfo describes a key held by the transit service
+type KeyInfo struct {
+	Name      string `json:"name"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"`
+}
+
+type keysResponse struct {
+	Keys []KeyInfo `json:"keys"`
+}
+
+type signRequest struct {
+	Input []byte `json:"input"`
+	Hash  string `json:"hash"`
+	PSS   bool   `json:"pss"`
+}
+
+type signResponse struct {
+	Signature []byte `json:"signature"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Client talks to a transit signing service
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the transit service at baseURL
+func NewClient(baseURL, token string, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Keys lists the keys held by the service
+func (c *Client) Keys(ctx context.Context) ([]KeyInfo, error) {
+	var resp keysResponse
+	if err := c.do(ctx, http.MethodGet, "/keys", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Keys, nil
+}
+
+// sign asks the service to sign input with the named key
+func (c *Client) sign(ctx context.Context, name string, req signRequest) ([]byte, error) {
+	var resp signResponse
+	if err := c.do(ctx, http.MethodPost, "/keys/"+url.PathEscape(name)+"/sign", req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Signature) == 0 {
+		return nil, fmt.Errorf("transit service returned no signature for key %s", name)
+	}
+	return resp.Signature, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("transit service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp errorResponse
+		_ = json.NewDecoder(resp.Body).Decode(&errResp)
+		return fmt.Errorf("transit service %s %s: %s: %s", method, path, resp.Status, errResp.Error)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid transit service response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package transit
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+)
+
+// Server is a local stand-in for a transit service. It holds the private keys in memory, so it is
+// meant for development and tests; production deployments put the real service in front of an HSM.
+type Server struct {
+	token string
+	keys  map[string]serverKey
+	mux   *http.ServeMux
+}
+
+type serverKey struct {
+	signer crypto.Signer
+	info   KeyInfo
+}
+
+// NewServer creates a stand-in that requires token as bearer token, unless it is empty
+func NewServer(token string) *Server {
+	s := &Server{token: token, keys: make(map[string]serverKey), mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /keys", s.listKeys)
+	s.mux.HandleFunc("POST /keys/{name}/sign", s.sign)
+	return s
+}
+
+// NewServerFromDir creates a stand-in serving the private keys of a keys directory
+func NewServerFromDir(path, token string) (*Server, error) {
+	files, err := filepath.Glob(filepath.Join(path, "private-*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewServer(token)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		signer, alg, err := auth.ParsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "private-"), ".pem")
+		if err := s.AddKey(name, signer, alg); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// AddKey makes the key available under name
+func (s *Server) AddKey(name string, signer crypto.Signer, alg string) error {
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	s.keys[name] = serverKey{
+		signer: signer,
+		info: KeyInfo{
+			Name:      name,
+			Algorithm: alg,
+			PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes})),
+		},
+	}
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid token"})
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) listKeys(w http.ResponseWriter, _ *http.Request) {
+	resp := keysResponse{Keys: []KeyInfo{}}
+	for _, key := range s.keys {
+		resp.Keys = append(resp.Keys, key.info)
+	}
+	slices.SortFunc(resp.Keys, func(a, b KeyInfo) int { return strings.Compare(a.Name, b.Name) })
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) sign(w http.ResponseWriter, r *http.Request) {
+	key, ok := s.keys[r.PathValue("name")]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "unknown key"})
+		return
+	}
+
+	var req signRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	var opts crypto.SignerOpts = crypto.Hash(0)
+	if req.Hash != "" {
+		hash, ok := hashes[req.Hash]
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "unsupported hash"})
+			return
+		}
+		opts = hash
+		if req.PSS {
+			opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+		}
+	}
+
+	signature, err := key.signer.Sign(rand.Reader, req.Input, opts)
+	if err != nil {
+		slog.Error("Transit stand-in failed to sign", "key", key.info.Name, "error", err)
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "signing failed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, signResponse{Signature: signature})
+}
+
+// hashes are the digests signing algorithms use, by crypto.Hash name
+var hashes = map[string]crypto.Hash{
+	crypto.SHA256.String(): crypto.SHA256,
+	crypto.SHA384.String(): crypto.SHA384,
+	crypto.SHA512.String(): crypto.SHA512,
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,58 @@
+package transit
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Signer is a crypto.Signer whose private key stays in the transit service. It can be handed to
+// auth.NewSigningKey like a parsed private key.
+type Signer struct {
+	client *Client
+	name   string
+	public crypto.PublicKey
+}
+
+// NewSigner creates a signer for a key listed by the service
+func NewSigner(client *Client, key KeyInfo) (*Signer, error) {
+	block, _ := pem.Decode([]byte(key.PublicKey))
+	if block == nil {
+		return nil, fmt.Errorf("transit key %s: no PEM block found", key.Name)
+	}
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("transit key %s: %w", key.Name, err)
+	}
+
+	return &Signer{client: client, name: key.Name, public: public}, nil
+}
+
+// Public returns the public half of the key
+func (s *Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign has the transit service sign the digest; rand is unused because the service supplies its own randomness
+func (s *Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	req := signRequest{Input: digest}
+	if hash := opts.HashFunc(); hash != 0 {
+		req.Hash = hash.String()
+	}
+	if pss, ok := opts.(*rsa.PSSOptions); ok {
+		if pss.SaltLength != rsa.PSSSaltLengthEqualsHash {
+			return nil, errors.New("transit signer only supports PSS salts as long as the hash")
+		}
+		req.PSS = true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.httpClient.Timeout)
+	defer cancel()
+
+	return s.client.sign(ctx, s.name, req)
+}
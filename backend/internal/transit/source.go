@@ -0,0 +1,71 @@
+package transit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+)
+
+// KeySource loads the keys of a transit service into an auth.KeyStore. Only the public keys are
+// read; every signature is made by the service.
+type KeySource struct {
+	client    *Client
+	activeKid string
+}
+
+// NewKeySource creates a key source that signs with the key named activeKid
+func NewKeySource(client *Client, activeKid string) *KeySource {
+	return &KeySource{client: client, activeKid: activeKid}
+}
+
+// Load publishes every key the service holds and signs through it
+func (s *KeySource) Load() (*auth.KeyStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.httpClient.Timeout)
+	defer cancel()
+
+	infos, err := s.client.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []auth.SigningKey
+	for _, info := range infos {
+		signer, err := NewSigner(s.client, info)
+		if err != nil {
+			return nil, err
+		}
+		key, err := auth.NewSigningKey(info.Name, signer, info.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("transit key %s: %w", info.Name, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return auth.NewKeyStore(s.activeKid, keys, nil, s)
+}
+
+// Fingerprint hashes the keys listed by the service
+func (s *KeySource) Fingerprint() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.httpClient.Timeout)
+	defer cancel()
+
+	infos, err := s.client.Keys(ctx)
+	if err != nil {
+		return "", err
+	}
+	slices.SortFunc(infos, func(a, b KeyInfo) int { return strings.Compare(a.Name, b.Name) })
+
+	hash := sha256.New()
+	for _, info := range infos {
+		for _, field := range []string{info.Name, info.Algorithm, info.PublicKey} {
+			hash.Write([]byte(field))
+			hash.Write([]byte{0})
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,51 @@
+package transit
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Anvoria/authly/internal/domain/auth"
+	"github.com/lestrrat-go/jwx/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitSigning(t *testing.T) {
+	standIn := NewServer("secret")
+	for alg, bits := range map[string]int{"ES256": 0, "PS256": 2048, "EdDSA": 0} {
+		signer, err := auth.GenerateSigningKey(alg, bits)
+		require.NoError(t, err)
+		require.NoError(t, standIn.AddKey(alg, signer, alg))
+	}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	ks, err := NewKeySource(NewClient(server.URL, "secret", 0), "ES256").Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ES256", "EdDSA", "PS256"}, ks.Algorithms())
+
+	t.Run("only public keys are held in memory", func(t *testing.T) {
+		assert.Equal(t, 3, ks.JWKS().Len())
+		for i := 0; i < ks.KeySet.Len(); i++ {
+			key, _ := ks.KeySet.Key(i)
+			assert.False(t, key.Has("d"))
+		}
+	})
+
+	for _, alg := range []string{"ES256", "PS256", "EdDSA"} {
+		t.Run(alg+" tokens are signed by the service", func(t *testing.T) {
+			token, err := jwt.NewBuilder().Subject("user-1").Build()
+			require.NoError(t, err)
+
+			signed, err := ks.SignTokenWithAlgorithm(token, alg)
+			require.NoError(t, err)
+			_, err = ks.VerifySignature(signed)
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("the service rejects other tokens", func(t *testing.T) {
+		_, err := NewKeySource(NewClient(server.URL, "wrong", 0), "ES256").Load()
+		assert.ErrorContains(t, err, "401")
+	})
+}
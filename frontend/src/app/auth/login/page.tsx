@@ -20,7 +20,8 @@ type LoginFormData = {
  * Render the login UI, manage form state and validation, perform authentication, and handle post-auth redirect behavior.
  *
  * If an IdP session exists or sign-in succeeds and an `oidc_params` query parameter is present, redirects to `/authorize`
- * (adding PKCE parameters when missing); otherwise navigates to the application root.
+ * (adding PKCE parameters when missing); otherwise navigates to the local path in `return_to`, or the application root. With `reauthenticate=true` an
+ * existing session is not enough and the user has to sign in again.
  *
  * @returns The login page content as a React element
  */
@@ -71,13 +72,15 @@ function LoginPageContent() {
             };
             await handleOidcRedirect();
         } else {
-            router.push("/");
+            // Only paths on this site are followed, so the parameter cannot send users elsewhere
+            const returnTo = searchParams.get("return_to");
+            router.push(returnTo && /^\/(?![/\\])/.test(returnTo) ? returnTo : "/");
         }
     }, [searchParams, router]);
 
     useEffect(() => {
         const checkSession = async () => {
-            const hasSession = await checkIdPSession();
+            const hasSession = searchParams.get("reauthenticate") !== "true" && (await checkIdPSession());
             if (hasSession) {
                 await performRedirect();
             } else {
@@ -85,7 +88,7 @@ function LoginPageContent() {
             }
         };
         checkSession();
-    }, [performRedirect]);
+    }, [performRedirect, searchParams]);
 
     const handleSubmit = async (e: React.FormEvent) => {
         e.preventDefault();
@@ -1,10 +1,10 @@
 "use client";
 
 import { useSearchParams, useRouter } from "next/navigation";
-import { Suspense, useEffect, useMemo, useState } from "react";
+import { Suspense, useEffect, useMemo, useRef, useState } from "react";
 import AuthorizeLayout from "@/authly/components/authorize/AuthorizeLayout";
 import ConsentScreen from "@/authly/components/authorize/ConsentScreen";
-import { validateAuthorizationParams, buildErrorRedirect } from "@/authly/lib/oidc";
+import { validateAuthorizationParams, buildErrorRedirect, submitFormPost } from "@/authly/lib/oidc";
 import { isApiError, checkIdPSession } from "@/authly/lib/api";
 import { useValidateAuthorization, useConfirmAuthorization } from "@/authly/lib/hooks/useOidc";
 
@@ -18,14 +18,22 @@ type AuthPageState =
     | { type: "validating" }
     | { type: "error"; error: ErrorState }
     | { type: "consent"; client: { name: string; logo_url?: string }; scopes: string[] }
-    | { type: "redirecting" };
+    | { type: "redirecting" }
+    | { type: "approving" }
+    | { type: "returning"; redirect: string };
+
+/**
+ * Errors of a request that asked for no interaction (prompt=none), which the client learns about on its redirect URI.
+ */
+const INTERACTION_ERRORS = ["login_required", "consent_required", "interaction_required", "account_selection_required"];
 
 /**
  * Render the OIDC authorization page and manage validation, consent, and redirect flows.
  *
  * Parses OIDC query parameters, validates the client and requested scopes, checks the user's session,
  * and presents a validating message, an error view (optionally with a return-to-app redirect), a consent screen,
- * or redirects to the login flow as appropriate.
+ * or redirects to the login flow as appropriate. Requests the user already consented to are confirmed without
+ * showing the consent screen.
  *
  * @returns The JSX for the authorization page, or `null` when nothing should be rendered
  */
@@ -77,6 +85,23 @@ function AuthorizePageContent() {
         }
 
         if (clientValidation) {
+            const redirectUri = validationParams.params!.redirect_uri;
+            if (
+                !clientValidation.valid &&
+                clientValidation.error &&
+                INTERACTION_ERRORS.includes(clientValidation.error) &&
+                clientValidation.client?.redirect_uris.includes(redirectUri)
+            ) {
+                return {
+                    type: "returning",
+                    redirect: buildErrorRedirect(redirectUri, {
+                        error: clientValidation.error,
+                        error_description: clientValidation.error_description || "User interaction is required",
+                        state: validationParams.params!.state,
+                    }),
+                };
+            }
+
             if (!clientValidation.valid || !clientValidation.client) {
                 return {
                     type: "error",
@@ -119,7 +144,12 @@ function AuthorizePageContent() {
                 };
             }
 
-            if (hasSession) {
+            // A stale session, or one older than a prompt=login or max_age request, has to sign in again
+            if (hasSession && !clientValidation.login_required) {
+                // The user already approved these scopes for this application, so there is nothing to ask
+                if (clientValidation.consent_granted) {
+                    return { type: "approving" };
+                }
                 return {
                     type: "consent",
                     client: {
@@ -138,6 +168,10 @@ function AuthorizePageContent() {
 
     // Handle redirect to login in an effect
     useEffect(() => {
+        if (state.type === "returning") {
+            window.location.href = state.redirect;
+            return;
+        }
         if (state.type === "redirecting" && validationParams.params) {
             const params = validationParams.params;
             const oidcParams = new URLSearchParams();
@@ -148,30 +182,39 @@ function AuthorizePageContent() {
             oidcParams.set("state", params.state);
             oidcParams.set("code_challenge", params.code_challenge);
             oidcParams.set("code_challenge_method", params.code_challenge_method);
+            if (params.response_mode) {
+                oidcParams.set("response_mode", params.response_mode);
+            }
+            if (params.claims) {
+                oidcParams.set("claims", params.claims);
+            }
+            params.resource?.forEach((resource) => oidcParams.append("resource", resource));
+            // Coming back with the stored request keeps the time it began, which a prompt=login login must follow
+            if (clientValidation?.request_uri) {
+                oidcParams.set("request_uri", clientValidation.request_uri);
+            }
 
             const encodedParams = encodeURIComponent(oidcParams.toString());
-            router.push(`/auth/login?oidc_params=${encodedParams}`);
+            const reauthenticate = hasSession ? "&reauthenticate=true" : "";
+            router.push(`/auth/login?oidc_params=${encodedParams}${reauthenticate}`);
         }
-    }, [state.type, validationParams.params, router]);
+    }, [state, validationParams.params, clientValidation?.request_uri, hasSession, router]);
 
     const handleApprove = async () => {
-        if (!validationParams.params) return;
+        if (!validationParams.params || !clientValidation?.request_uri) return;
 
         const params = validationParams.params;
 
         confirmMutation.mutate(
             {
                 client_id: params.client_id,
-                redirect_uri: params.redirect_uri,
-                response_type: "code",
-                scope: params.scope,
-                state: params.state,
-                code_challenge: params.code_challenge,
-                code_challenge_method: params.code_challenge_method,
+                request_uri: clientValidation.request_uri,
             },
             {
                 onSuccess: (response) => {
-                    if (response.success && response.redirect_uri) {
+                    if (response.success && response.form_post && response.parameters) {
+                        submitFormPost(response.redirect_uri, response.parameters);
+                    } else if (response.success && response.redirect_uri) {
                         window.location.href = response.redirect_uri;
                     } else {
                         const errorRedirect = buildErrorRedirect(params.redirect_uri, {
@@ -206,6 +249,16 @@ function AuthorizePageContent() {
         );
     };
 
+    // Confirm right away when consent was already given; the ref keeps a re-render from confirming twice
+    const autoApprovedRef = useRef(false);
+    useEffect(() => {
+        if (state.type === "approving" && !autoApprovedRef.current) {
+            autoApprovedRef.current = true;
+            handleApprove();
+        }
+        // eslint-disable-next-line react-hooks/exhaustive-deps
+    }, [state.type]);
+
     const handleDeny = () => {
         if (!validationParams.params) return;
         const params = validationParams.params;
@@ -218,12 +271,23 @@ function AuthorizePageContent() {
         window.location.href = errorRedirect;
     };
 
-    if (state.type === "validating" || state.type === "redirecting") {
+    if (
+        state.type === "validating" ||
+        state.type === "redirecting" ||
+        state.type === "approving" ||
+        state.type === "returning"
+    ) {
         return (
             <AuthorizeLayout>
                 <div className="flex items-center justify-center py-12">
                     <div className="text-white/60">
-                        {state.type === "validating" ? "Validating request..." : "Redirecting to login..."}
+                        {state.type === "validating"
+                            ? "Validating request..."
+                            : state.type === "redirecting"
+                              ? "Redirecting to login..."
+                              : state.type === "approving"
+                                ? "Authorizing..."
+                                : "Returning to application..."}
                     </div>
                 </div>
             </AuthorizeLayout>
@@ -0,0 +1,187 @@
+"use client";
+
+import { useEffect, useRef, useState, Suspense } from "react";
+import { useRouter, useSearchParams } from "next/navigation";
+import { useValidateDeviceCode, useConfirmDevice } from "@/authly/lib/hooks/useOidc";
+import AuthorizeLayout from "@/authly/components/authorize/AuthorizeLayout";
+import ConsentScreen from "@/authly/components/authorize/ConsentScreen";
+import Button from "@/authly/components/ui/Button";
+import Input from "@/authly/components/ui/Input";
+
+type DevicePageState =
+    | { type: "entering" }
+    | { type: "consent"; userCode: string; client: { name: string; logo_url?: string }; scopes: string[] }
+    | { type: "done"; approved: boolean };
+
+/**
+ * Render the device verification page (RFC 8628 verification_uri) where users approve a device authorization.
+ *
+ * The user enters the code shown on their device, or arrives with it in the `user_code` query parameter from
+ * verification_uri_complete. The page looks up the requesting application, shows the consent screen, and records the
+ * decision. Users without a session are sent to log in first and brought back with their code.
+ *
+ * @returns The JSX for the device verification page
+ */
+function DevicePageContent() {
+    const router = useRouter();
+    const searchParams = useSearchParams();
+    const validateMutation = useValidateDeviceCode();
+    const confirmMutation = useConfirmDevice();
+
+    const [userCode, setUserCode] = useState(searchParams.get("user_code") ?? "");
+    const [error, setError] = useState<string | null>(null);
+    const [state, setState] = useState<DevicePageState>({ type: "entering" });
+    const submittedRef = useRef(false);
+
+    const lookUp = async (code: string) => {
+        setError(null);
+        const trimmed = code.trim().toUpperCase();
+        if (!trimmed) {
+            setError("Enter the code shown on your device");
+            return;
+        }
+
+        try {
+            const result = await validateMutation.mutateAsync(trimmed);
+            if (result.error === "login_required") {
+                const returnTo = `/device?user_code=${encodeURIComponent(trimmed)}`;
+                router.push(`/auth/login?return_to=${encodeURIComponent(returnTo)}`);
+                return;
+            }
+            if (!result.valid || !result.client) {
+                setError(result.error_description || "The code is invalid or has expired");
+                return;
+            }
+
+            setState({
+                type: "consent",
+                userCode: trimmed,
+                client: { name: result.client.name, logo_url: result.client.logo_url },
+                scopes: result.scopes ?? [],
+            });
+        } catch (err) {
+            console.error("Device code lookup failed", err);
+            setError("An unexpected error occurred. Please try again.");
+        }
+    };
+
+    // A code from verification_uri_complete is looked up right away
+    useEffect(() => {
+        if (submittedRef.current) return;
+        submittedRef.current = true;
+
+        const code = searchParams.get("user_code");
+        if (code) {
+            lookUp(code);
+        }
+        // eslint-disable-next-line react-hooks/exhaustive-deps
+    }, []);
+
+    const decide = async (approve: boolean) => {
+        if (state.type !== "consent") return;
+
+        try {
+            const result = await confirmMutation.mutateAsync({ user_code: state.userCode, approve });
+            if (!result.success) {
+                setState({ type: "entering" });
+                setError(result.error_description || result.error || "The code is invalid or has expired");
+                return;
+            }
+            setState({ type: "done", approved: approve });
+        } catch (err) {
+            console.error("Device confirmation failed", err);
+            setState({ type: "entering" });
+            setError("An unexpected error occurred. Please try again.");
+        }
+    };
+
+    if (state.type === "consent") {
+        return (
+            <AuthorizeLayout>
+                <ConsentScreen
+                    clientName={state.client.name}
+                    clientLogoUrl={state.client.logo_url}
+                    scopes={state.scopes}
+                    onApprove={() => decide(true)}
+                    onDeny={() => decide(false)}
+                    isLoading={confirmMutation.isPending}
+                />
+            </AuthorizeLayout>
+        );
+    }
+
+    if (state.type === "done") {
+        return (
+            <AuthorizeLayout>
+                <div className="space-y-1">
+                    <h2 className="text-xl font-semibold text-white">
+                        {state.approved ? "Device Connected" : "Request Denied"}
+                    </h2>
+                    <p className="text-sm text-white/60">
+                        {state.approved
+                            ? "You can return to your device to continue."
+                            : "The device was not given access to your account."}
+                    </p>
+                </div>
+            </AuthorizeLayout>
+        );
+    }
+
+    return (
+        <AuthorizeLayout>
+            <div className="space-y-6">
+                <div className="space-y-1">
+                    <h2 className="text-xl font-semibold text-white">Connect a Device</h2>
+                    <p className="text-sm text-white/60">Enter the code shown on your device</p>
+                </div>
+
+                <form
+                    onSubmit={(e) => {
+                        e.preventDefault();
+                        lookUp(userCode);
+                    }}
+                    className="space-y-5"
+                >
+                    <Input
+                        label="Code"
+                        type="text"
+                        placeholder="BCDF-GHJK"
+                        value={userCode}
+                        onChange={(e) => setUserCode(e.target.value)}
+                        autoComplete="off"
+                        autoCapitalize="characters"
+                        required
+                        disabled={validateMutation.isPending}
+                    />
+
+                    {error && <p className="text-xs font-medium text-red-500">{error}</p>}
+
+                    <div className="pt-1">
+                        <Button fullWidth variant="primary" type="submit" disabled={validateMutation.isPending}>
+                            {validateMutation.isPending ? "Checking..." : "Continue"}
+                        </Button>
+                    </div>
+                </form>
+            </div>
+        </AuthorizeLayout>
+    );
+}
+
+/**
+ * Renders the device verification page with a Suspense boundary and loading fallback.
+ *
+ * @returns The page's JSX element containing a Suspense wrapper with a loading fallback and the device content.
+ */
+export default function DevicePage() {
+    return (
+        <Suspense
+            fallback={
+                <div className="min-h-screen w-full flex items-center justify-center bg-black">
+                    <div className="text-white/60">Loading...</div>
+                </div>
+            }
+        >
+            <DevicePageContent />
+        </Suspense>
+    );
+}
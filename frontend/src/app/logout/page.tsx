@@ -0,0 +1,135 @@
+"use client";
+
+import { useState, Suspense } from "react";
+import { useRouter, useSearchParams } from "next/navigation";
+import { useConfirmLogout } from "@/authly/lib/hooks/useOidc";
+import LocalStorageTokenService from "@/authly/lib/globals/client/LocalStorageTokenService";
+import AuthorizeLayout from "@/authly/components/authorize/AuthorizeLayout";
+import Button from "@/authly/components/ui/Button";
+
+/**
+ * Asks the user to confirm an RP-Initiated Logout that did not prove it came from one of their applications.
+ *
+ * The original logout parameters are posted back unchanged on confirmation; the backend validates them again,
+ * ends the session, and returns the post-logout redirect URI to follow, if the application asked for one.
+ *
+ * @returns A JSX element with the confirmation prompt, an error screen when the logout was rejected, or a
+ *          signed-out notice when there is nowhere to return to.
+ */
+function LogoutPageContent() {
+    const router = useRouter();
+    const searchParams = useSearchParams();
+    const confirmMutation = useConfirmLogout();
+    const [error, setError] = useState<string | null>(null);
+    const [loggedOut, setLoggedOut] = useState(false);
+
+    const handleConfirm = async () => {
+        try {
+            const result = await confirmMutation.mutateAsync({
+                id_token_hint: searchParams.get("id_token_hint") || undefined,
+                post_logout_redirect_uri: searchParams.get("post_logout_redirect_uri") || undefined,
+                client_id: searchParams.get("client_id") || undefined,
+                state: searchParams.get("state") || undefined,
+            });
+
+            if (!result.success) {
+                setError(result.error_description || result.error);
+                return;
+            }
+
+            LocalStorageTokenService.clear();
+            if (result.redirect_uri) {
+                window.location.href = result.redirect_uri;
+                return;
+            }
+            setLoggedOut(true);
+        } catch (err) {
+            console.error("Logout failed", err);
+            setError("An unexpected error occurred during logout.");
+        }
+    };
+
+    if (error) {
+        return (
+            <AuthorizeLayout>
+                <div className="space-y-6">
+                    <div className="space-y-1">
+                        <h2 className="text-xl font-semibold text-red-500">Logout Failed</h2>
+                        <p className="text-sm text-white/60">{error}</p>
+                    </div>
+
+                    <div className="pt-1">
+                        <Button fullWidth variant="primary" onClick={() => router.push("/dashboard")}>
+                            Back to Dashboard
+                        </Button>
+                    </div>
+                </div>
+            </AuthorizeLayout>
+        );
+    }
+
+    if (loggedOut) {
+        return (
+            <AuthorizeLayout>
+                <div className="space-y-6">
+                    <div className="space-y-1">
+                        <h2 className="text-xl font-semibold text-white">Signed Out</h2>
+                        <p className="text-sm text-white/60">You have been signed out of Authly.</p>
+                    </div>
+
+                    <div className="pt-1">
+                        <Button fullWidth variant="primary" onClick={() => router.push("/auth/login")}>
+                            Sign In Again
+                        </Button>
+                    </div>
+                </div>
+            </AuthorizeLayout>
+        );
+    }
+
+    return (
+        <AuthorizeLayout>
+            <div className="space-y-6">
+                <div className="space-y-1">
+                    <h2 className="text-xl font-semibold text-white">Sign Out</h2>
+                    <p className="text-sm text-white/60">
+                        An application asked to sign you out of Authly. Do you want to sign out?
+                    </p>
+                </div>
+
+                <div className="flex gap-3 pt-2">
+                    <Button
+                        fullWidth
+                        variant="secondary"
+                        onClick={() => router.push("/dashboard")}
+                        disabled={confirmMutation.isPending}
+                    >
+                        Stay Signed In
+                    </Button>
+                    <Button fullWidth variant="primary" onClick={handleConfirm} disabled={confirmMutation.isPending}>
+                        {confirmMutation.isPending ? "Signing out..." : "Sign Out"}
+                    </Button>
+                </div>
+            </div>
+        </AuthorizeLayout>
+    );
+}
+
+/**
+ * Renders the logout confirmation page with a Suspense boundary and loading fallback.
+ *
+ * @returns The page's JSX element containing a Suspense wrapper with a loading fallback and the logout content.
+ */
+export default function LogoutPage() {
+    return (
+        <Suspense
+            fallback={
+                <div className="min-h-screen w-full flex items-center justify-center bg-black">
+                    <div className="text-white/60">Loading...</div>
+                </div>
+            }
+        >
+            <LogoutPageContent />
+        </Suspense>
+    );
+}
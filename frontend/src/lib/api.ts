@@ -14,6 +14,16 @@ import {
     type ValidateAuthorizationRequestResponse,
     type ConfirmAuthorizationRequest,
     type ConfirmAuthorizationResponse,
+    confirmLogoutRequestSchema,
+    confirmLogoutResponseSchema,
+    type ConfirmLogoutRequest,
+    type ConfirmLogoutResponse,
+    deviceVerificationResponseSchema,
+    confirmDeviceRequestSchema,
+    confirmDeviceResponseSchema,
+    type DeviceVerificationResponse,
+    type ConfirmDeviceRequest,
+    type ConfirmDeviceResponse,
     type TokenRequest,
     type TokenResponse,
     tokenRequestSchema,
@@ -288,6 +298,79 @@ export async function confirmAuthorization(
     return validated;
 }
 
+/**
+ * Log out the current session after the user confirmed the RP-Initiated Logout request.
+ *
+ * @param request - The original logout request parameters
+ * @returns A `ConfirmLogoutResponse`. On success it contains the validated `redirect_uri` to return to, if the relying party asked for one; on failure it contains `error` and `error_description`.
+ */
+export async function confirmLogout(request: ConfirmLogoutRequest): Promise<ConfirmLogoutResponse> {
+    const validatedData = confirmLogoutRequestSchema.parse(request);
+
+    const response = await GeneralClient.post<ConfirmLogoutResponse>("/oauth/logout/confirm", validatedData);
+
+    if (!response.success) {
+        if ("error" in response) {
+            return {
+                success: false,
+                error: typeof response.error === "string" ? response.error : "unknown_error",
+                error_description: response.errorDescription,
+            };
+        }
+        return {
+            success: false,
+            error: "unknown_error",
+            error_description: "An unexpected error occurred",
+        };
+    }
+
+    return confirmLogoutResponseSchema.parse(response.data || response.rawResponse.data);
+}
+
+/**
+ * Look up the device authorization a user code belongs to, so the user can check what they are approving.
+ *
+ * @param userCode - The user code shown on the device
+ * @returns The parsed verification result with the requesting client and scopes when the code is valid; otherwise an error and description
+ */
+export async function validateDeviceCode(userCode: string): Promise<DeviceVerificationResponse> {
+    const response = await GeneralClient.get<DeviceVerificationResponse>(
+        `/oauth/device/validate?user_code=${encodeURIComponent(userCode)}`,
+    );
+
+    if (!response.success) {
+        return {
+            valid: false,
+            error: typeof response.error === "string" ? response.error : "server_error",
+            error_description: response.errorDescription ?? "Failed to validate the code",
+        };
+    }
+
+    return deviceVerificationResponseSchema.parse(response.data);
+}
+
+/**
+ * Record the user's approval or denial of a device authorization.
+ *
+ * @param request - The user code and the user's decision
+ * @returns A `ConfirmDeviceResponse`; on failure it contains `error` and `error_description`
+ */
+export async function confirmDevice(request: ConfirmDeviceRequest): Promise<ConfirmDeviceResponse> {
+    const validatedData = confirmDeviceRequestSchema.parse(request);
+
+    const response = await GeneralClient.post<ConfirmDeviceResponse>("/oauth/device/confirm", validatedData);
+
+    if (!response.success) {
+        return {
+            success: false,
+            error: "error" in response && typeof response.error === "string" ? response.error : "unknown_error",
+            error_description: "errorDescription" in response ? response.errorDescription : undefined,
+        };
+    }
+
+    return confirmDeviceResponseSchema.parse(response.data || response.rawResponse.data);
+}
+
 /**
  * Exchanges an authorization code for OAuth 2.0 tokens (access token, refresh token, ID token).
  *
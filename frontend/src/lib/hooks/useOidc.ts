@@ -1,6 +1,12 @@
 import { useMutation, useQuery } from "@tanstack/react-query";
-import { validateAuthorizationRequest, confirmAuthorization } from "@/authly/lib/api";
-import { ConfirmAuthorizationRequest } from "@/authly/lib/schemas/oidc";
+import {
+    validateAuthorizationRequest,
+    confirmAuthorization,
+    confirmLogout,
+    validateDeviceCode,
+    confirmDevice,
+} from "@/authly/lib/api";
+import { ConfirmAuthorizationRequest, ConfirmDeviceRequest, ConfirmLogoutRequest } from "@/authly/lib/schemas/oidc";
 import { ReadonlyURLSearchParams } from "next/navigation";
 
 export const oidcKeys = {
@@ -33,3 +39,36 @@ export function useConfirmAuthorization() {
         mutationFn: (data: ConfirmAuthorizationRequest) => confirmAuthorization(data),
     });
 }
+
+/**
+ * Initiates a mutation to confirm an RP-Initiated Logout request.
+ *
+ * @returns The React Query mutation object used to perform and track a confirm-logout operation with a `ConfirmLogoutRequest` payload.
+ */
+export function useConfirmLogout() {
+    return useMutation({
+        mutationFn: (data: ConfirmLogoutRequest) => confirmLogout(data),
+    });
+}
+
+/**
+ * Initiates a mutation that looks up the device authorization behind a user code.
+ *
+ * @returns The React Query mutation object used to validate a user code.
+ */
+export function useValidateDeviceCode() {
+    return useMutation({
+        mutationFn: (userCode: string) => validateDeviceCode(userCode),
+    });
+}
+
+/**
+ * Initiates a mutation to approve or deny a device authorization.
+ *
+ * @returns The React Query mutation object used to perform and track a confirm-device operation with a `ConfirmDeviceRequest` payload.
+ */
+export function useConfirmDevice() {
+    return useMutation({
+        mutationFn: (data: ConfirmDeviceRequest) => confirmDevice(data),
+    });
+}
@@ -15,6 +15,9 @@ export interface ValidatedParams {
     state: string;
     code_challenge: string;
     code_challenge_method: string;
+    response_mode?: string;
+    claims?: string;
+    resource?: string[];
 }
 
 export interface ValidationResult {
@@ -155,6 +158,9 @@ export function validateAuthorizationParams(searchParams: ReadonlyURLSearchParam
             state,
             code_challenge: codeChallenge,
             code_challenge_method: codeChallengeMethod,
+            response_mode: searchParams.get("response_mode") || undefined,
+            claims: searchParams.get("claims") || undefined,
+            resource: searchParams.getAll("resource"),
         },
     };
 }
@@ -273,3 +279,24 @@ export function redirectToAuthorize(oidcParams: string): void {
         window.location.href = `/authorize?${oidcParams}`;
     }
 }
+
+/**
+ * Send an authorization response to the client with an auto-submitted POST (OAuth 2.0 Form Post Response Mode).
+ *
+ * @param action - The client's redirect URI the form is posted to.
+ * @param parameters - The authorization response parameters to post as hidden fields.
+ */
+export function submitFormPost(action: string, parameters: Record<string, string>): void {
+    const form = document.createElement("form");
+    form.method = "POST";
+    form.action = action;
+    for (const [name, value] of Object.entries(parameters)) {
+        const input = document.createElement("input");
+        input.type = "hidden";
+        input.name = name;
+        input.value = value;
+        form.appendChild(input);
+    }
+    document.body.appendChild(form);
+    form.submit();
+}
@@ -23,6 +23,9 @@ export type ClientInfo = z.infer<typeof clientInfoSchema>;
 export const validateAuthorizationRequestResponseSchema = z.object({
     valid: z.boolean(),
     client: clientInfoSchema.optional(),
+    login_required: z.boolean().optional(),
+    consent_granted: z.boolean().optional(),
+    request_uri: z.string().optional(),
     error: z.string().optional(),
     error_description: z.string().optional(),
 });
@@ -81,15 +84,12 @@ export type TokenResponse = z.infer<typeof tokenResponseSchema>;
 
 /**
  * Schema for confirm authorization request (for approve button)
+ *
+ * The request parameters are not sent again: `request_uri` references the request the backend stored when it was validated.
  */
 export const confirmAuthorizationRequestSchema = z.object({
     client_id: z.string(),
-    redirect_uri: z.string(),
-    response_type: z.string(),
-    scope: z.string(),
-    state: z.string(),
-    code_challenge: z.string().optional(),
-    code_challenge_method: z.string().optional(),
+    request_uri: z.string(),
 });
 
 /**
@@ -103,6 +103,8 @@ export type ConfirmAuthorizationRequest = z.infer<typeof confirmAuthorizationReq
 export const confirmAuthorizationSuccessResponseSchema = z.object({
     success: z.literal(true),
     redirect_uri: z.string(),
+    form_post: z.boolean().optional(),
+    parameters: z.record(z.string(), z.string()).optional(),
 });
 
 /**
@@ -126,3 +128,81 @@ export const confirmAuthorizationResponseSchema = z.union([
  * Type inferred from confirmAuthorizationResponseSchema
  */
 export type ConfirmAuthorizationResponse = z.infer<typeof confirmAuthorizationResponseSchema>;
+
+/**
+ * Schema for confirm logout request (the original RP-Initiated Logout parameters)
+ */
+export const confirmLogoutRequestSchema = z.object({
+    id_token_hint: z.string().optional(),
+    post_logout_redirect_uri: z.string().optional(),
+    client_id: z.string().optional(),
+    state: z.string().optional(),
+});
+
+/**
+ * Type inferred from confirmLogoutRequestSchema
+ */
+export type ConfirmLogoutRequest = z.infer<typeof confirmLogoutRequestSchema>;
+
+/**
+ * Schema for confirm logout response
+ */
+export const confirmLogoutResponseSchema = z.union([
+    z.object({
+        success: z.literal(true),
+        redirect_uri: z.string().optional(),
+    }),
+    z.object({
+        success: z.literal(false),
+        error: z.string(),
+        error_description: z.string().optional(),
+    }),
+]);
+
+/**
+ * Type inferred from confirmLogoutResponseSchema
+ */
+export type ConfirmLogoutResponse = z.infer<typeof confirmLogoutResponseSchema>;
+
+/**
+ * Schema for device verification response (the device authorization behind a user code)
+ */
+export const deviceVerificationResponseSchema = z.object({
+    valid: z.boolean(),
+    client: clientInfoSchema.optional(),
+    scopes: z.array(z.string()).optional(),
+    error: z.string().optional(),
+    error_description: z.string().optional(),
+});
+
+/**
+ * Type inferred from deviceVerificationResponseSchema
+ */
+export type DeviceVerificationResponse = z.infer<typeof deviceVerificationResponseSchema>;
+
+/**
+ * Schema for confirm device request (the user's decision on a device authorization)
+ */
+export const confirmDeviceRequestSchema = z.object({
+    user_code: z.string().min(1),
+    approve: z.boolean(),
+});
+
+/**
+ * Type inferred from confirmDeviceRequestSchema
+ */
+export type ConfirmDeviceRequest = z.infer<typeof confirmDeviceRequestSchema>;
+
+/**
+ * Schema for confirm device response
+ */
+export const confirmDeviceResponseSchema = z.object({
+    success: z.boolean(),
+    error: z.string().optional(),
+    error_description: z.string().optional(),
+});
+
+/**
+ * Type inferred from confirmDeviceResponseSchema
+ */
+export type ConfirmDeviceResponse = z.infer<typeof confirmDeviceResponseSchema>;